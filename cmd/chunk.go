@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"tilemap-generator/internal/generator"
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/tileutils"
+)
+
+var (
+	chunkTileset  string
+	chunkSeed     int64
+	chunkX        int
+	chunkY        int
+	chunkSize     int
+	chunkAttempts int
+	chunkPNG      bool
+	chunkOutput   string
+	chunkDiagnose bool
+)
+
+var chunkCmd = &cobra.Command{
+	Use:   "chunk",
+	Short: "Generate one chunk of an infinite world with seamless borders",
+	Run: func(cmd *cobra.Command, args []string) {
+		tilesetDir := filepath.Join("tileset", chunkTileset)
+		meta, err := maputils.LoadTileset(tilesetDir)
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+
+		outputDir := chunkOutput
+		if outputDir == "" {
+			outputDir = filepath.Join("chunks", chunkTileset)
+		}
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			fmt.Println("❌ Failed to create output directory:", err)
+			return
+		}
+		base := filepath.Join(outputDir, fmt.Sprintf("chunk_%d_%d", chunkX, chunkY))
+
+		fmt.Printf("🌍 Generating chunk (%d, %d) of %dx%d tiles (world seed %d)...\n", chunkX, chunkY, chunkSize, chunkSize, chunkSeed)
+		result, err := generator.GenerateChunk(meta, generator.ChunkOptions{
+			WorldSeed: chunkSeed,
+			CX:        chunkX,
+			CY:        chunkY,
+			Size:      chunkSize,
+			Attempts:  chunkAttempts,
+			Diagnose:  chunkDiagnose,
+		})
+		if err != nil {
+			fmt.Println("❌", err)
+			var fail *generator.FailureError
+			if errors.As(err, &fail) && fail.Diagnosis != nil {
+				saveDiagnosis(tilesetDir, meta, fail.Diagnosis, base+"_contradiction")
+			}
+			return
+		}
+
+		err = maputils.SaveMapping(base+".json", maputils.MappingFile{
+			Tileset: chunkTileset,
+			Seed:    chunkSeed,
+			Width:   chunkSize,
+			Height:  chunkSize,
+			Mapping: result.Mapping,
+			Chunk:   &maputils.ChunkCoord{X: chunkX, Y: chunkY, Size: chunkSize},
+		})
+		if err != nil {
+			fmt.Println("❌ Failed to save mapping:", err)
+			return
+		}
+		fmt.Printf("💾 Saved %s.json\n", base)
+
+		if !chunkPNG {
+			return
+		}
+		img, err := tileutils.RenderMapping(tilesetDir, meta, result.Mapping)
+		if err != nil {
+			fmt.Println("❌ Failed to render chunk:", err)
+			return
+		}
+		if err := tileutils.SavePNG(img, base+".png"); err != nil {
+			fmt.Println("❌ Failed to save image:", err)
+			return
+		}
+		fmt.Printf("💾 Saved %s.png\n", base)
+	},
+}
+
+func init() {
+	chunkCmd.Flags().StringVarP(&chunkTileset, "tileset", "t", "", "Name of trained tileset in tileset/")
+	chunkCmd.MarkFlagRequired("tileset")
+	chunkCmd.Flags().Int64Var(&chunkSeed, "seed", 0, "World seed shared by every chunk")
+	chunkCmd.Flags().IntVar(&chunkX, "cx", 0, "Chunk column")
+	chunkCmd.Flags().IntVar(&chunkY, "cy", 0, "Chunk row")
+	chunkCmd.Flags().IntVar(&chunkSize, "size", 32, "Chunk width and height in tiles")
+	chunkCmd.Flags().IntVar(&chunkAttempts, "attempts", 50, "Maximum restarts for the world frame, each seam and the chunk")
+	chunkCmd.Flags().BoolVar(&chunkPNG, "png", false, "Also render the chunk to a PNG")
+	chunkCmd.Flags().StringVarP(&chunkOutput, "output", "o", "", "Output directory (default chunks/<tileset>)")
+	chunkCmd.Flags().BoolVar(&chunkDiagnose, "diagnose", false, "On failure, write a contradiction report and overlay image")
+	rootCmd.AddCommand(chunkCmd)
+}
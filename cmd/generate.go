@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"tilemap-generator/internal/generator"
+	"tilemap-generator/internal/imagehelpers"
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/tileutils"
+)
+
+var (
+	genTileset  string
+	genWidth    int
+	genHeight   int
+	genSeed     int64
+	genAttempts int
+	genPeriodic bool
+	genOutput   string
+
+	genMode          string
+	genN             int
+	genPeriodicInput bool
+	genAugment       int
+	genInpaint       string
+	genDiagnose      bool
+	genConstraints   string
+	genBlock         int
+	genGuide         string
+	genLegend        string
+	genSymmetry      string
+	genStrictSym     bool
+	genAnimate       string
+	genAnimateEvery  int
+	genCount         int
+	genDupThreshold  float64
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a new map from a trained tileset using Wave Function Collapse",
+	Run: func(cmd *cobra.Command, args []string) {
+		tilesetDir := filepath.Join("tileset", genTileset)
+		meta, err := maputils.LoadTileset(tilesetDir)
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+
+		if !cmd.Flags().Changed("seed") {
+			genSeed = time.Now().UnixNano()
+		}
+
+		outputDir := genOutput
+		if outputDir == "" {
+			outputDir = filepath.Join("generated", genTileset)
+		}
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			fmt.Println("❌ Failed to create output directory:", err)
+			return
+		}
+
+		opts := generator.Options{
+			Width:    genWidth,
+			Height:   genHeight,
+			Seed:     genSeed,
+			Attempts: genAttempts,
+			Periodic: genPeriodic,
+
+			Mode:          genMode,
+			N:             genN,
+			PeriodicInput: genPeriodicInput,
+			Augment:       genAugment,
+			Block:         genBlock,
+
+			Diagnose: genDiagnose,
+		}
+
+		if genConstraints != "" {
+			opts.Constraints, err = generator.LoadConstraints(genConstraints)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+		}
+
+		if genAugment > 1 {
+			images, err := tileutils.LoadTileImages(tilesetDir, meta)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			opts.Counterparts = generator.PatternCounterparts(images)
+		}
+
+		if genSymmetry != "" {
+			images, err := tileutils.LoadTileImages(tilesetDir, meta)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			opts.Symmetry, err = generator.NewSymmetry(genSymmetry, images)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			if missing := opts.Symmetry.Missing; len(missing) > 0 {
+				if genStrictSym {
+					fmt.Printf("❌ %d tile(s) have no %s counterpart: %v\n", len(missing), genSymmetry, missing)
+					return
+				}
+				fmt.Printf("⚠️  %d tile(s) have no %s counterpart and will not be used: %v\n", len(missing), genSymmetry, missing)
+			}
+		}
+
+		switch genAnimate {
+		case "":
+		case "gif", "frames":
+			opts.Record = &generator.Recorder{Every: genAnimateEvery}
+		default:
+			fmt.Printf("❌ Error: unknown animation format %q, expected gif or frames\n", genAnimate)
+			return
+		}
+
+		var run func(generator.Options) (*generator.Result, error)
+		var describe string
+		if genInpaint != "" && (genGuide != "" || genLegend != "") {
+			fmt.Println("❌ Error: --inpaint cannot be combined with --guide or --legend")
+			return
+		}
+		if genInpaint != "" {
+			if genMode != generator.ModeSimple {
+				fmt.Printf("❌ Error: --inpaint supports only --mode=%s, not %s\n", generator.ModeSimple, genMode)
+				return
+			}
+			var sketch *maputils.MappingFile
+			sketch, err = maputils.LoadMapping(genInpaint)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			describe = fmt.Sprintf("🖌️  Inpainting %dx%d sketch '%s' from '%s'", sketch.Width, sketch.Height, genInpaint, tilesetDir)
+			run = func(o generator.Options) (*generator.Result, error) {
+				return generator.Inpaint(meta, sketch.Mapping, o)
+			}
+		} else if genGuide != "" {
+			if genMode != generator.ModeSimple {
+				fmt.Printf("❌ Error: --guide supports only --mode=%s, not %s\n", generator.ModeSimple, genMode)
+				return
+			}
+			if genLegend == "" {
+				fmt.Println("❌ Error: --guide requires --legend")
+				return
+			}
+			var legend *generator.Legend
+			legend, err = generator.LoadLegend(genLegend)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			var guide image.Image
+			guide, err = imagehelpers.LoadImage(genGuide)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			if !cmd.Flags().Changed("width") && !cmd.Flags().Changed("height") {
+				opts.Width, opts.Height = 0, 0
+			}
+			describe = fmt.Sprintf("🗺️  Generating from guide '%s' with '%s'", genGuide, tilesetDir)
+			run = func(o generator.Options) (*generator.Result, error) {
+				return generator.GenerateFromGuide(meta, guide, legend, o)
+			}
+		} else {
+			describe = fmt.Sprintf("🎲 Generating %dx%d map from '%s' with the %s model", genWidth, genHeight, tilesetDir, genMode)
+			run = func(o generator.Options) (*generator.Result, error) { return generator.Generate(meta, o) }
+		}
+
+		if genCount > 1 {
+			if opts.Record != nil {
+				fmt.Println("❌ Error: --animate cannot be combined with --count")
+				return
+			}
+			runBatch(tilesetDir, meta, opts, run, describe, outputDir)
+			return
+		}
+
+		fmt.Printf("%s (seed %d)...\n", describe, genSeed)
+		result, err := run(opts)
+		if opts.Record != nil {
+			saveAnimation(tilesetDir, meta, opts.Record.Frames, genAnimate, filepath.Join(outputDir, fmt.Sprintf("anim_%d", genSeed)))
+		}
+		if err != nil {
+			fmt.Println("❌", err)
+			var fail *generator.FailureError
+			if errors.As(err, &fail) && fail.Diagnosis != nil {
+				saveDiagnosis(tilesetDir, meta, fail.Diagnosis, filepath.Join(outputDir, fmt.Sprintf("contradiction_%d", genSeed)))
+			}
+			var violated *generator.ConstraintError
+			if errors.As(err, &violated) {
+				printConstraintReport(violated.Report)
+			}
+			return
+		}
+		fmt.Printf("✅ Solved in %d attempt(s)\n", result.Attempts)
+		printConstraintReport(result.Constraints)
+
+		saveGeneratedMap(tilesetDir, meta, result.Mapping, result.Seed, filepath.Join(outputDir, fmt.Sprintf("map_%d", result.Seed)))
+	},
+}
+
+// runBatch generates genCount maps, seeding map i with the base seed plus i
+// times the attempt limit so no two maps share an attempt seed. Near
+// duplicates are dropped and the rest are saved alongside a ranked summary.
+func runBatch(tilesetDir string, meta *maputils.TilesetMetadata, opts generator.Options, run func(generator.Options) (*generator.Result, error), describe, outputDir string) {
+	fmt.Printf("%s, %d maps from seed %d...\n", describe, genCount, genSeed)
+	stride := int64(genAttempts)
+	if stride < 1 {
+		stride = 1
+	}
+	var entries []generator.BatchEntry
+	failed := 0
+	for i := 0; i < genCount; i++ {
+		o := opts
+		o.Seed = genSeed + int64(i)*stride
+		result, err := run(o)
+		if err != nil {
+			fmt.Printf("❌ Seed %d: %v\n", o.Seed, err)
+			failed++
+			continue
+		}
+		entries = append(entries, generator.BatchEntry{Seed: o.Seed, Attempts: result.Attempts, Mapping: result.Mapping})
+	}
+	if len(entries) == 0 {
+		fmt.Println("❌ No map in the batch could be generated")
+		return
+	}
+
+	report := generator.ScoreBatch(meta.Mapping, entries, genDupThreshold)
+	for i := range report.Entries {
+		e := &report.Entries[i]
+		if e.DuplicateOf != nil {
+			continue
+		}
+		base := filepath.Join(outputDir, fmt.Sprintf("map_%d", e.Seed))
+		e.File = filepath.Base(base) + ".json"
+		saveGeneratedMap(tilesetDir, meta, e.Mapping, e.Seed, base)
+	}
+
+	summary := filepath.Join(outputDir, fmt.Sprintf("batch_%d", genSeed))
+	if err := report.SaveJSON(summary + ".json"); err != nil {
+		fmt.Println("❌ Failed to save batch summary:", err)
+		return
+	}
+	if err := report.SaveCSV(summary + ".csv"); err != nil {
+		fmt.Println("❌", err)
+		return
+	}
+
+	fmt.Printf("\n📦 %d generated, %d failed, %d near-duplicate(s) dropped (similarity > %.2f)\n",
+		len(entries), failed, report.Dropped, genDupThreshold)
+	fmt.Println("\nSeed                 | Diversity (rank) | Source match (rank)")
+	fmt.Println("---------------------|------------------|--------------------")
+	for _, e := range report.Entries {
+		if e.DuplicateOf == nil {
+			fmt.Printf("%-20d | %10.3f (%2d) | %13.3f (%2d)\n", e.Seed, e.Diversity, e.DiversityRank, e.Source.Combined, e.SourceRank)
+		}
+	}
+	fmt.Printf("💾 Saved %s.json and %s.csv\n", summary, summary)
+}
+
+// saveGeneratedMap writes mapping as <base>.json and renders it to <base>.png.
+func saveGeneratedMap(tilesetDir string, meta *maputils.TilesetMetadata, mapping [][]int, seed int64, base string) {
+	height := len(mapping)
+	width := 0
+	if height > 0 {
+		width = len(mapping[0])
+	}
+	err := maputils.SaveMapping(base+".json", maputils.MappingFile{
+		Tileset: filepath.Base(tilesetDir),
+		Seed:    seed,
+		Width:   width,
+		Height:  height,
+		Mapping: mapping,
+	})
+	if err != nil {
+		fmt.Println("❌ Failed to save mapping:", err)
+		return
+	}
+
+	img, err := tileutils.RenderMapping(tilesetDir, meta, mapping)
+	if err != nil {
+		fmt.Println("❌ Failed to render map:", err)
+		return
+	}
+	if err := tileutils.SavePNG(img, base+".png"); err != nil {
+		fmt.Println("❌ Failed to save image:", err)
+		return
+	}
+	fmt.Printf("💾 Saved %s.json and %s.png\n", base, base)
+}
+
+// printConstraintReport lists whether each global constraint held.
+func printConstraintReport(report []generator.ConstraintResult) {
+	if len(report) == 0 {
+		return
+	}
+	fmt.Println("\nConstraint                     | OK  | Detail")
+	fmt.Println("-------------------------------|-----|-------")
+	for _, r := range report {
+		ok := "yes"
+		if !r.Satisfied {
+			ok = "no"
+		}
+		fmt.Printf("%-30s | %-3s | %s\n", r.Name, ok, r.Detail)
+	}
+	fmt.Println()
+}
+
+// saveAnimation writes recorded frames as <base>.gif, or as numbered PNGs in
+// the <base>/ directory when format is "frames".
+func saveAnimation(tilesetDir string, meta *maputils.TilesetMetadata, frames []generator.Frame, format, base string) {
+	if len(frames) == 0 {
+		return
+	}
+	images, err := tileutils.LoadTileImages(tilesetDir, meta)
+	if err != nil {
+		fmt.Println("❌ Failed to load tiles:", err)
+		return
+	}
+	tw, th := meta.TileDims()
+	if format == "frames" {
+		if err := os.MkdirAll(base, os.ModePerm); err != nil {
+			fmt.Println("❌ Failed to create frame directory:", err)
+			return
+		}
+		for i, f := range frames {
+			path := filepath.Join(base, fmt.Sprintf("frame_%04d.png", i))
+			if err := tileutils.SavePNG(f.Render(images, tw, th), path); err != nil {
+				fmt.Println("❌ Failed to save frame:", err)
+				return
+			}
+		}
+		fmt.Printf("🎞️  Saved %d frames to %s/\n", len(frames), base)
+		return
+	}
+	rendered := make([]image.Image, len(frames))
+	for i, f := range frames {
+		rendered[i] = f.Render(images, tw, th)
+	}
+	if err := tileutils.SaveGIF(rendered, 8, base+".gif"); err != nil {
+		fmt.Println("❌ Failed to save animation:", err)
+		return
+	}
+	fmt.Printf("🎞️  Saved %d frames to %s.gif\n", len(frames), base)
+}
+
+// saveDiagnosis writes a contradiction report to <base>.txt and an overlay
+// image to <base>.png.
+func saveDiagnosis(tilesetDir string, meta *maputils.TilesetMetadata, d *generator.Diagnosis, base string) {
+	report := d.Report()
+	fmt.Println()
+	fmt.Print(report)
+	if err := os.WriteFile(base+".txt", []byte(report), 0644); err != nil {
+		fmt.Println("❌ Failed to save report:", err)
+		return
+	}
+
+	images, err := tileutils.LoadTileImages(tilesetDir, meta)
+	if err != nil {
+		fmt.Println("❌ Failed to load tiles:", err)
+		return
+	}
+	tw, th := meta.TileDims()
+	if err := tileutils.SavePNG(d.RenderOverlay(images, tw, th), base+".png"); err != nil {
+		fmt.Println("❌ Failed to save overlay:", err)
+		return
+	}
+	fmt.Printf("🩺 Saved %s.txt and %s.png\n", base, base)
+}
+
+func init() {
+	generateCmd.Flags().StringVarP(&genTileset, "tileset", "t", "", "Name of trained tileset in tileset/")
+	generateCmd.MarkFlagRequired("tileset")
+	generateCmd.Flags().IntVar(&genWidth, "width", 32, "Map width in tiles")
+	generateCmd.Flags().IntVar(&genHeight, "height", 32, "Map height in tiles")
+	generateCmd.Flags().Int64Var(&genSeed, "seed", 0, "Random seed for reproducible output (default: time based)")
+	generateCmd.Flags().IntVar(&genAttempts, "attempts", 10, "Maximum restarts when a contradiction is reached")
+	generateCmd.Flags().BoolVar(&genPeriodic, "periodic", false, "Wrap the output so opposite edges tile seamlessly")
+	generateCmd.Flags().StringVarP(&genOutput, "output", "o", "", "Output directory (default generated/<tileset>)")
+	generateCmd.Flags().StringVarP(&genMode, "mode", "m", generator.ModeSimple, "Generation model: simple, overlapping or hierarchical")
+	generateCmd.Flags().IntVarP(&genN, "n", "n", 3, "Pattern size for the overlapping model (2, 3 or 4)")
+	generateCmd.Flags().BoolVar(&genPeriodicInput, "periodic-input", false, "Let overlapping patterns wrap around the source mapping")
+	generateCmd.Flags().IntVar(&genAugment, "augment", 1, "Rotated/reflected variants of each pattern to include (1-8)")
+	generateCmd.Flags().StringVar(&genInpaint, "inpaint", "", "Mapping file whose -1 cells are filled around the pinned tiles")
+	generateCmd.Flags().BoolVar(&genDiagnose, "diagnose", false, "On failure, write a contradiction report and overlay image")
+	generateCmd.Flags().StringVar(&genConstraints, "constraints", "", "JSON or YAML (.yaml, .yml) spec of tile counts, required tiles, borders and connectivity")
+	generateCmd.Flags().StringVar(&genGuide, "guide", "", "Guide image whose colours paint the regions of the map")
+	generateCmd.Flags().StringVar(&genLegend, "legend", "", "JSON legend mapping guide colours to tile IDs or classes")
+	generateCmd.Flags().StringVar(&genSymmetry, "symmetry", "", "Make the map symmetric: mirror-h, mirror-v, mirror-hv, rot2 or rot4")
+	generateCmd.Flags().BoolVar(&genStrictSym, "strict-symmetry", false, "Refuse to generate when a tile has no symmetric counterpart")
+	generateCmd.Flags().StringVar(&genAnimate, "animate", "", "Record the last attempt as an animated gif or numbered png frames")
+	generateCmd.Flags().IntVar(&genAnimateEvery, "animate-every", 0, "Solver steps per animation frame (default: about 120 frames)")
+	generateCmd.Flags().IntVar(&genCount, "count", 1, "Generate a batch of maps from consecutive seeds and rank them")
+	generateCmd.Flags().Float64Var(&genDupThreshold, "duplicate-threshold", 0.9, "Drop batch maps whose similarity (0-1) to a kept map exceeds this")
+	generateCmd.Flags().IntVar(&genBlock, "block", 4, "Source tiles per coarse cell side for the hierarchical model")
+	rootCmd.AddCommand(generateCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"tilemap-generator/internal/analyser"
+	"tilemap-generator/internal/iohelpers"
+)
+
+var (
+	inspectName string
+	inspectJSON bool
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Report the palette and colour statistics of a map",
+	Run: func(cmd *cobra.Command, args []string) {
+		resolvedPath, err := iohelpers.ResolveMapPath(inspectName)
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		analysis, err := analyser.InspectMap(resolvedPath)
+		if err != nil {
+			fmt.Println("❌ Failed to inspect image:", err)
+			return
+		}
+
+		if inspectJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(analysis); err != nil {
+				fmt.Println("❌ Failed to encode analysis:", err)
+			}
+			return
+		}
+		printMapAnalysis(analysis)
+	},
+}
+
+// printMapAnalysis prints the summary shown before training.
+func printMapAnalysis(analysis *analyser.MapAnalysisResult) {
+	fmt.Printf("\n🗺️  Map Analysis:\n")
+	fmt.Printf("- Resolution: %dx%d\n", analysis.Width, analysis.Height)
+	fmt.Printf("- Colour Model: %s\n", analysis.ColorModel)
+	fmt.Printf("- Unique Colours: %d\n", analysis.UniqueColors)
+	fmt.Printf("- Uses Alpha Channel: %v\n", analysis.UsesAlpha)
+	fmt.Printf("- Avg Brightness: %.1f\n", analysis.AvgBrightness)
+	fmt.Printf("- Brightness Spread: %s\n", analysis.BrightnessSpread)
+
+	kind := "exact"
+	if !analysis.PaletteExact {
+		kind = "median cut"
+	}
+	fmt.Printf("- Palette: %d colours (%s)\n", len(analysis.Palette), kind)
+	fmt.Println("- Dominant Colours:")
+	for _, c := range analysis.Dominant {
+		fmt.Printf("    %-9s %6.2f%%\n", c.Hex, c.Coverage)
+	}
+}
+
+func init() {
+	inspectCmd.Flags().StringVarP(&inspectName, "input", "i", "", "Name of map to inspect (without extension)")
+	inspectCmd.MarkFlagRequired("input")
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "Print the full analysis, including palette and histograms, as JSON")
+	rootCmd.AddCommand(inspectCmd)
+}
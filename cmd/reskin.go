@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"tilemap-generator/internal/maputils"
+)
+
+var (
+	reskinFrom    string
+	reskinTo      string
+	reskinTable   string
+	reskinMap     string
+	reskinSuggest bool
+	reskinForce   bool
+	reskinOutput  string
+)
+
+var reskinCmd = &cobra.Command{
+	Use:   "reskin",
+	Short: "Render a layout made with one tileset using the tiles of another",
+	Run: func(cmd *cobra.Command, args []string) {
+		fromDir := filepath.Join("tileset", reskinFrom)
+		toDir := filepath.Join("tileset", reskinTo)
+		from, err := maputils.LoadTileset(fromDir)
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		to, err := maputils.LoadTileset(toDir)
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		if from.Isometric() || to.Isometric() {
+			fmt.Println("❌ Error: isometric tilesets cannot be reskinned yet")
+			return
+		}
+
+		tablePath := reskinTable
+		if tablePath == "" {
+			tablePath = filepath.Join(toDir, fmt.Sprintf("equivalence_%s.json", reskinFrom))
+		}
+
+		// A table on disk may carry hand edits, so it is only replaced with
+		// --suggest --force.
+		var table *maputils.Equivalence
+		if !reskinSuggest || !reskinForce {
+			table, err = maputils.LoadEquivalence(tablePath)
+			if errors.Is(err, fs.ErrNotExist) {
+				table = nil
+			} else if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			} else if err := table.Validate(to); err != nil {
+				fmt.Printf("❌ Error: %s: %v\n", tablePath, err)
+				return
+			}
+		}
+
+		if reskinSuggest || table == nil {
+			fmt.Printf("🔗 Suggesting tile equivalences from the aligned mappings of '%s' and '%s'...\n", reskinFrom, reskinTo)
+			suggested, err := maputils.SuggestEquivalence(from, to, reskinFrom, reskinTo)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			if table != nil {
+				added := table.Merge(suggested)
+				fmt.Printf("🔀 Kept the %d existing pair(s) of %s and added %d suggestion(s) (--force overwrites it)\n",
+					len(table.Tiles)-len(added), tablePath, len(added))
+			} else {
+				table = suggested
+			}
+			printEquivalence(table)
+			if err := maputils.SaveEquivalence(tablePath, table); err != nil {
+				fmt.Println("❌ Failed to save equivalence table:", err)
+				return
+			}
+			fmt.Printf("💾 Saved %s (edit it and rerun to correct any pair)\n", tablePath)
+			if reskinSuggest {
+				return
+			}
+		}
+
+		layout := from.Mapping
+		name := "source"
+		if reskinMap != "" {
+			m, err := maputils.LoadMapping(reskinMap)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			layout = m.Mapping
+			name = strings.TrimSuffix(filepath.Base(reskinMap), filepath.Ext(reskinMap))
+		}
+		if len(layout) == 0 {
+			fmt.Println("❌ Error: no layout to reskin; pass --map")
+			return
+		}
+
+		mapping, missing := table.Apply(layout)
+		if len(missing) > 0 {
+			fmt.Printf("⚠️  %d tile(s) of '%s' have no equivalent and are left empty: %v\n", len(missing), reskinFrom, missing)
+		}
+
+		outputDir := reskinOutput
+		if outputDir == "" {
+			outputDir = filepath.Join("generated", reskinTo)
+		}
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			fmt.Println("❌ Failed to create output directory:", err)
+			return
+		}
+		base := filepath.Join(outputDir, fmt.Sprintf("%s_%s", name, reskinFrom))
+		fmt.Printf("🎨 Reskinning %dx%d layout from '%s' to '%s'...\n", len(mapping[0]), len(mapping), reskinFrom, reskinTo)
+		saveGeneratedMap(toDir, to, mapping, 0, base)
+	},
+}
+
+// printEquivalence lists each pair with how consistently the two tiles
+// lined up. Pairs kept from an edited table may have no candidates or
+// differ from the most frequent one.
+func printEquivalence(e *maputils.Equivalence) {
+	fmt.Println("\nFrom | To   | Agreement | Alternatives")
+	fmt.Println("-----|------|-----------|-------------")
+	for a := 0; a <= maxKey(e.Tiles); a++ {
+		b, ok := e.Tiles[a]
+		if !ok {
+			continue
+		}
+		agreement := "        -"
+		var alt []string
+		for _, c := range e.Candidates[a] {
+			if c.ID == b {
+				agreement = fmt.Sprintf("%8.0f%%", c.Share*100)
+				continue
+			}
+			alt = append(alt, fmt.Sprintf("%d (%.0f%%)", c.ID, c.Share*100))
+		}
+		fmt.Printf("%4d | %4d | %s | %s\n", a, b, agreement, strings.Join(alt, ", "))
+	}
+	fmt.Println()
+}
+
+func maxKey(m map[int]int) int {
+	max := -1
+	for k := range m {
+		if k > max {
+			max = k
+		}
+	}
+	return max
+}
+
+func init() {
+	reskinCmd.Flags().StringVar(&reskinFrom, "from", "", "Tileset the layout was made with")
+	reskinCmd.MarkFlagRequired("from")
+	reskinCmd.Flags().StringVar(&reskinTo, "to", "", "Tileset to render the layout with")
+	reskinCmd.MarkFlagRequired("to")
+	reskinCmd.Flags().StringVar(&reskinTable, "table", "", "Equivalence table (default tileset/<to>/equivalence_<from>.json, suggested if missing)")
+	reskinCmd.Flags().StringVar(&reskinMap, "map", "", "Mapping file to reskin (default the source mapping of --from)")
+	reskinCmd.Flags().BoolVar(&reskinSuggest, "suggest", false, "Only write the suggested equivalence table, merged into an existing one")
+	reskinCmd.Flags().BoolVar(&reskinForce, "force", false, "With --suggest, overwrite an existing equivalence table instead of merging")
+	reskinCmd.Flags().StringVarP(&reskinOutput, "output", "o", "", "Output directory (default generated/<to>)")
+	rootCmd.AddCommand(reskinCmd)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"image"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -11,13 +12,29 @@ import (
 	"github.com/spf13/cobra"
 	"tilemap-generator/internal/analyser"
 	"tilemap-generator/internal/iohelpers"
+	"tilemap-generator/internal/maputils"
 	"tilemap-generator/internal/tiletrainer"
 )
 
+// Candidate tile sizes used when no period is detected, and the pitch range
+// searched by period detection.
+const (
+	defaultTileSizes    = "16,32,64,128,256"
+	defaultIsoTileSizes = "32x16,64x32,128x64,256x128"
+	minPitch            = 8
+	maxPitch            = 256
+)
+
 var (
-	inputName  string
-	tileSize   int
-	diagnostic bool
+	inputName   string
+	tileSizes   string
+	edgePolicy  string
+	orientation string
+	diagnostic  bool
+	diagonals   bool
+	native      bool
+	maxDeltaE   float64
+	heatmap     bool
 )
 
 var trainTilesCmd = &cobra.Command{
@@ -29,6 +46,17 @@ var trainTilesCmd = &cobra.Command{
 			fmt.Println("❌ Error:", err)
 			return
 		}
+		edge, err := maputils.ParseEdgePolicy(edgePolicy)
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		orient, err := maputils.ParseOrientation(orientation)
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		iso := orient == maputils.Isometric
 
 		fmt.Println("🔍 Inspecting image...")
 		analysis, err := analyser.InspectMap(resolvedPath)
@@ -37,50 +65,117 @@ var trainTilesCmd = &cobra.Command{
 			return
 		}
 
-		fmt.Printf("\n🗺️  Map Analysis:\n")
-		fmt.Printf("- Resolution: %dx%d\n", analysis.Width, analysis.Height)
-		fmt.Printf("- Colour Model: %s\n", analysis.ColorModel)
-		fmt.Printf("- Unique Colours: %d\n", analysis.UniqueColors)
-		fmt.Printf("- Uses Alpha Channel: %v\n", analysis.UsesAlpha)
-		fmt.Printf("- Avg Brightness: %.1f\n", analysis.AvgBrightness)
-		fmt.Printf("- Brightness Spread: %s\n", analysis.BrightnessSpread)
+		printMapAnalysis(analysis)
 
-		fmt.Println("📊 Analysing image for optimal tile sizes...")
-		candidateSizes := []int{16, 32, 64, 128, 256}
-		results, err := analyser.AnalyseTileSizesFuzzy(resolvedPath, candidateSizes)
+		// Load and clean the image
+		img, err := imaging.Open(resolvedPath)
 		if err != nil {
-			fmt.Println("❌ Analysis failed:", err)
+			fmt.Println("❌ Failed to load image:", err)
 			return
 		}
+		scale := analyser.DetectPixelScale(img)
+		var normalised analyser.PixelScale
+		var source image.Image
+		if scale.Scale > 1 {
+			fmt.Printf("🔎 Upscaled pixel art detected: %dx (confidence %.2f)\n", scale.Scale, scale.Confidence)
+			if native {
+				source = img
+				img = scale.Normalise(img)
+				normalised = scale
+				b := img.Bounds()
+				fmt.Printf("- Normalised to native resolution %dx%d\n", b.Dx(), b.Dy())
+			} else {
+				fmt.Println("- Use --native to slice at native resolution.")
+			}
+		}
+		cleaned := analyser.PreprocessForTraining(img)
 
-		fmt.Println("\nTile Size | Total Tiles | Unique Tiles | Reuse Ratio")
-		fmt.Println("----------|-------------|---------------|-------------")
+		fmt.Println("📊 Analysing image for optimal tile sizes...")
+		var results []analyser.TileSizeResult
+		var hypotheses []analyser.PeriodHypothesis
+		sheet, isSheet := maputils.Grid{}, false
+		if tileSizes == "" {
+			// Spritesheets are laid out in rectangles, so isometric maps
+			// only look for a period.
+			if !iso {
+				sheet, isSheet = analyser.DetectSpritesheet(img)
+			}
+			if isSheet {
+				fmt.Printf("🧩 Spritesheet detected: %dx%dpx tiles, margin %dpx, spacing %dpx\n",
+					sheet.TileWidth, sheet.TileHeight, sheet.Margin, sheet.Spacing)
+			} else if hypotheses = analyser.DetectPeriods(img, minPitch, maxPitch); len(hypotheses) == 0 {
+				fmt.Println("⚠️  No tile period detected, trying the default sizes.")
+			}
+		}
+		if isSheet {
+			results = analyser.AnalyseGridsFuzzy(cleaned, []maputils.Grid{sheet})
+		} else if len(hypotheses) > 0 && iso {
+			results = analyser.AnalyseIsometricPeriodsFuzzy(img, cleaned, hypotheses)
+		} else if len(hypotheses) > 0 {
+			results = analyser.AnalysePeriodsFuzzy(img, cleaned, hypotheses)
+		} else {
+			sizes := tileSizes
+			if sizes == "" && iso {
+				sizes = defaultIsoTileSizes
+			} else if sizes == "" {
+				sizes = defaultTileSizes
+			}
+			var candidateSizes []image.Point
+			if candidateSizes, err = parseTileSizes(sizes); err != nil {
+				fmt.Println("❌ Invalid --sizes:", err)
+				return
+			}
+			if iso {
+				results = analyser.AnalyseIsometricTileSizesFuzzy(img, cleaned, candidateSizes)
+			} else {
+				results = analyser.AnalyseImageTileSizesFuzzy(img, cleaned, candidateSizes)
+			}
+		}
+
+		fmt.Println("\nTile Size | Offset  | Confidence | Total Tiles | Unique Tiles | Reuse Ratio | Cropped px")
+		fmt.Println("----------|---------|------------|-------------|---------------|-------------|-----------")
 		for _, r := range results {
-			fmt.Printf("%9d | %11d | %13d | %10.1f%%\n", r.TileSize, r.TotalTiles, r.UniqueTiles, r.ReuseRatio*100)
+			offset := fmt.Sprintf("%d,%d", r.OffsetX, r.OffsetY)
+			confidence := "-"
+			if hypotheses != nil {
+				confidence = fmt.Sprintf("%.2f", r.Confidence)
+			}
+			fmt.Printf("%9s | %7s | %10s | %11d | %13d | %10.1f%% | %10d\n", r.Size(), offset, confidence, r.TotalTiles, r.UniqueTiles, r.ReuseRatio*100, r.CroppedPixels)
+		}
+
+		ranking := analyser.RankTileSizes(img, cleaned, results)
+		fmt.Println("\nRank | Tile Size | Score | Reuse | Edge Alignment | Compactness | Reconstruction")
+		fmt.Println("-----|-----------|-------|-------|----------------|-------------|---------------")
+		for i, sc := range ranking.Scores {
+			fmt.Printf("%4d | %9s | %5.2f | %5.2f | %14.2f | %11.2f | %14.2f\n",
+				i+1, sc.Result.Size(), sc.Score, sc.Reuse, sc.EdgeAlignment, sc.Compactness, sc.Reconstruction)
 		}
 
-		suggestedSize, ok := analyser.PickSuggestedTileSize(results, 0.3) // 30%+ reuse
+		suggested, ok := ranking.Best()
 		if !ok {
-			fmt.Println("⚠️  No tile size offers sufficient reuse. Defaulting to 64.")
-			suggestedSize = 64
+			fmt.Println("⚠️  No tile size could be analysed. Defaulting to 64.")
+			suggested = analyser.TileSizeResult{TileWidth: 64, TileHeight: 64}
+		} else {
+			fmt.Printf("Confidence: %.2f (%s)\n", ranking.Confidence, ranking.Level())
 		}
 
-		fmt.Printf("\nSuggested tile size: %dpx — Proceed? (Y/n): ", suggestedSize)
+		fmt.Printf("\nSuggested tile size: %spx — Proceed? (Y/n): ", suggested.Size())
 		var answer string
 		fmt.Scanln(&answer)
 		answer = strings.TrimSpace(strings.ToLower(answer))
+		size := image.Pt(suggested.TileWidth, suggested.TileHeight)
 		if answer == "n" {
-			fmt.Print("Enter custom tile size: ")
+			fmt.Print("Enter custom tile size (W or WxH): ")
 			var custom string
 			fmt.Scanln(&custom)
-			if i, err := strconv.Atoi(custom); err == nil {
-				tileSize = i
-			} else {
-				fmt.Println("❌ Invalid number, aborting.")
+			if size, err = parseTileSize(custom); err != nil {
+				fmt.Println("❌ Invalid tile size, aborting.")
 				return
 			}
-		} else {
-			tileSize = suggestedSize
+		}
+		if iso && (size.X%2 != 0 || size.Y%2 != 0) {
+			fmt.Println("❌ Isometric tile sizes must be even, aborting.")
+			return
 		}
 
 		baseName := strings.TrimSuffix(filepath.Base(resolvedPath), filepath.Ext(resolvedPath))
@@ -90,24 +185,91 @@ var trainTilesCmd = &cobra.Command{
 			return
 		}
 
-		fmt.Printf("\n🧠 Training tileset with %dpx tiles into '%s'...\n", tileSize, outputDir)
-		// Load and clean the image
-		img, err := imaging.Open(resolvedPath)
-		if err != nil {
-			fmt.Println("❌ Failed to load image:", err)
-			return
+		grid := maputils.Grid{TileWidth: size.X, TileHeight: size.Y, Orientation: orient}
+		found := false
+		for _, r := range results {
+			if r.TileWidth == size.X && r.TileHeight == size.Y {
+				grid, found = r.Grid(), true
+			}
 		}
-		cleaned := analyser.PreprocessForTraining(img)
-		if err := tiletrainer.TrainFromImages(img, cleaned, tileSize, outputDir, diagnostic); err != nil {
+		if !found && iso {
+			grid.OffsetX, grid.OffsetY = analyser.FindIsometricGridOffset(img, size.X, size.Y)
+		} else if !found {
+			grid.OffsetX, grid.OffsetY = analyser.FindGridOffset(img, size.X, size.Y)
+		}
+		grid.Edge = edge
+
+		layout := fmt.Sprintf("grid offset %d,%d", grid.OffsetX, grid.OffsetY)
+		if grid.Margin > 0 || grid.Spacing > 0 {
+			layout = fmt.Sprintf("margin %dpx, spacing %dpx", grid.Margin, grid.Spacing)
+		}
+		if iso {
+			layout = "isometric, " + layout
+		}
+		fmt.Printf("\n🧠 Training tileset with %dx%dpx tiles (%s) into '%s'...\n", size.X, size.Y, layout, outputDir)
+		if cropped := normalised.SourcePixels(grid.Cropped(img.Bounds())); cropped > 0 {
+			fmt.Printf("✂️  %d source pixels fall outside the tile grid (edge policy %s)\n", cropped, edge)
+		}
+		if err := tiletrainer.TrainFromImages(img, cleaned, outputDir, tiletrainer.Options{
+			Grid:       grid,
+			Diagnostic: diagnostic,
+			Diagonals:  diagonals,
+			Scale:      scale,
+			Source:     source,
+			MaxDeltaE:  maxDeltaE,
+			Heatmap:    heatmap,
+		}); err != nil {
 			fmt.Println("❌ Failed to train tiles:", err)
 			return
 		}
 	},
 }
 
+// parseTileSizes parses a comma-separated list of tile sizes.
+func parseTileSizes(s string) ([]image.Point, error) {
+	var sizes []image.Point
+	for _, part := range strings.Split(s, ",") {
+		size, err := parseTileSize(part)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// parseTileSize parses "W" as a square tile size or "WxH" as a rectangular
+// one.
+func parseTileSize(s string) (image.Point, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	ws, hs, rect := strings.Cut(s, "x")
+	if !rect {
+		hs = ws
+	}
+	w, err := strconv.Atoi(ws)
+	if err != nil {
+		return image.Point{}, fmt.Errorf("invalid tile size %q", s)
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil {
+		return image.Point{}, fmt.Errorf("invalid tile size %q", s)
+	}
+	if w <= 0 || h <= 0 {
+		return image.Point{}, fmt.Errorf("tile size %q must be positive", s)
+	}
+	return image.Pt(w, h), nil
+}
+
 func init() {
 	trainTilesCmd.Flags().StringVarP(&inputName, "input", "i", "", "Name of map to train on (without extension)")
 	trainTilesCmd.MarkFlagRequired("input")
+	trainTilesCmd.Flags().StringVar(&tileSizes, "sizes", "", "Comma-separated candidate tile sizes, W for square or WxH (default: detect the tile period)")
+	trainTilesCmd.Flags().StringVar(&edgePolicy, "edge", "drop", "Partial edge tiles: drop, pad-transparent, pad-edge or partial")
+	trainTilesCmd.Flags().StringVar(&orientation, "orientation", "orthogonal", "Grid orientation: orthogonal, or isometric for W x H diamond tiles")
 	trainTilesCmd.Flags().BoolVarP(&diagnostic, "diagnostic", "d", false, "Save diagnostic grid of detected tiles")
+	trainTilesCmd.Flags().BoolVar(&diagonals, "diagonals", false, "Record diagonal neighbours so generation respects corners")
+	trainTilesCmd.Flags().BoolVar(&native, "native", false, "Downscale detected upscaled pixel art to native resolution before slicing")
+	trainTilesCmd.Flags().Float64Var(&maxDeltaE, "delta-e", 0, "Group tiles whose mean CIEDE2000 colour difference is at most this, for JPEG sources (default: exact hashes)")
+	trainTilesCmd.Flags().BoolVar(&heatmap, "heatmap", false, "Save heatmap.png showing how often each cell's tile is reused")
 	rootCmd.AddCommand(trainTilesCmd)
 }
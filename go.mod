@@ -5,12 +5,13 @@ go 1.24
 require (
 	github.com/corona10/goimagehash v1.1.0
 	github.com/disintegration/gift v1.2.1
+	github.com/disintegration/imaging v1.6.2
 	github.com/spf13/cobra v1.9.1
 	golang.org/x/image v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/disintegration/imaging v1.6.2 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
 	github.com/spf13/pflag v1.0.7 // indirect
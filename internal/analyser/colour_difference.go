@@ -0,0 +1,109 @@
+package analyser
+
+import (
+	"math"
+)
+
+// lab is a colour in CIE L*a*b* (D65).
+type lab struct {
+	L, A, B float64
+}
+
+// srgbLinear maps 8-bit sRGB levels to linear light.
+var srgbLinear = func() [256]float64 {
+	var t [256]float64
+	for i := range t {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			t[i] = v / 12.92
+		} else {
+			t[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return t
+}()
+
+// toLab converts a non-premultiplied 8-bit sRGB colour to L*a*b*.
+func toLab(r, g, b uint8) lab {
+	lr, lg, lb := srgbLinear[r], srgbLinear[g], srgbLinear[b]
+	x := (0.4124*lr + 0.3576*lg + 0.1805*lb) / 0.95047
+	y := 0.2126*lr + 0.7152*lg + 0.0722*lb
+	z := (0.0193*lr + 0.1192*lg + 0.9505*lb) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// hueSlop absorbs the rounding error of opposite hues, which can come out
+// a hair more than 180° apart; the formula treats them as exactly 180°.
+const hueSlop = 1e-12
+
+// ciede2000 returns the CIEDE2000 colour difference between two colours.
+// A difference around 1 is just noticeable; 2-10 is noticeable at a
+// glance.
+func ciede2000(c1, c2 lab) float64 {
+	const deg = math.Pi / 180
+	const pow25to7 = 6103515625.0
+
+	cab := (math.Hypot(c1.A, c1.B) + math.Hypot(c2.A, c2.B)) / 2
+	cab7 := math.Pow(cab, 7)
+	g := 0.5 * (1 - math.Sqrt(cab7/(cab7+pow25to7)))
+	a1, a2 := (1+g)*c1.A, (1+g)*c2.A
+	cp1, cp2 := math.Hypot(a1, c1.B), math.Hypot(a2, c2.B)
+	hue := func(b, a float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a)
+		if h < 0 {
+			h += 2 * math.Pi
+		}
+		return h
+	}
+	hp1, hp2 := hue(c1.B, a1), hue(c2.B, a2)
+
+	dL := c2.L - c1.L
+	dC := cp2 - cp1
+	var dh float64
+	if cp1*cp2 != 0 {
+		dh = hp2 - hp1
+		if dh > math.Pi+hueSlop {
+			dh -= 2 * math.Pi
+		} else if dh < -math.Pi-hueSlop {
+			dh += 2 * math.Pi
+		}
+	}
+	dH := 2 * math.Sqrt(cp1*cp2) * math.Sin(dh/2)
+
+	lp := (c1.L + c2.L) / 2
+	cp := (cp1 + cp2) / 2
+	hp := hp1 + hp2
+	if cp1*cp2 != 0 {
+		if math.Abs(hp1-hp2) > math.Pi+hueSlop {
+			if hp < 2*math.Pi {
+				hp += 2 * math.Pi
+			} else {
+				hp -= 2 * math.Pi
+			}
+		}
+		hp /= 2
+	}
+
+	t := 1 - 0.17*math.Cos(hp-30*deg) + 0.24*math.Cos(2*hp) +
+		0.32*math.Cos(3*hp+6*deg) - 0.20*math.Cos(4*hp-63*deg)
+	dTheta := 30 * deg * math.Exp(-math.Pow((hp/deg-275)/25, 2))
+	cp7 := math.Pow(cp, 7)
+	rc := 2 * math.Sqrt(cp7/(cp7+pow25to7))
+	l50 := (lp - 50) * (lp - 50)
+	sl := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sc := 1 + 0.045*cp
+	sh := 1 + 0.015*cp*t
+	rt := -math.Sin(2*dTheta) * rc
+
+	return math.Sqrt((dL/sl)*(dL/sl) + (dC/sc)*(dC/sc) + (dH/sh)*(dH/sh) + rt*(dC/sc)*(dH/sh))
+}
@@ -0,0 +1,170 @@
+package analyser
+
+import (
+	"hash/fnv"
+	"image"
+	"image/draw"
+
+	"tilemap-generator/internal/maputils"
+)
+
+// offsetSamples is the number of sample points per tile axis used when
+// searching for the grid offset. Sampling keeps the search cost independent
+// of the tile size.
+const offsetSamples = 8
+
+// FindGridOffset searches every offset (dx, dy) with dx in [0, tileWidth)
+// and dy in [0, tileHeight) for the one whose grid gives the fewest distinct
+// tiles, i.e. the highest reuse, so screenshots cropped off the tile grid
+// still slice cleanly. Tiles are compared on a lattice of sample pixels;
+// ties keep the smallest offset, so an image already on the grid stays at
+// (0, 0).
+func FindGridOffset(img image.Image, tileWidth, tileHeight int) (dx, dy int) {
+	return RefineGridOffset(img, tileWidth, tileHeight, 0, 0)
+}
+
+// RefineGridOffset is FindGridOffset starting from an estimated offset,
+// such as one from DetectPeriods. Ties keep the offset closest after the
+// estimate, so the estimate decides between equally good offsets.
+func RefineGridOffset(img image.Image, tileWidth, tileHeight, hintX, hintY int) (dx, dy int) {
+	if tileWidth <= 0 || tileHeight <= 0 || tileWidth*tileHeight == 1 {
+		return 0, 0
+	}
+	rgba := asRGBA(img)
+	b := rgba.Bounds()
+
+	xs := samplePoints(tileWidth)
+	ys := samplePoints(tileHeight)
+
+	// Every offset is scored on the same number of tiles, the count that
+	// fits whatever the offset, so offsets are not rewarded for dropping a
+	// row or column of tiles.
+	cols := (b.Dx() - tileWidth + 1) / tileWidth
+	rows := (b.Dy() - tileHeight + 1) / tileHeight
+	if cols <= 0 || rows <= 0 {
+		return hintX % tileWidth, hintY % tileHeight
+	}
+
+	best := -1
+	seen := make(map[uint64]struct{})
+	sample := make([]byte, 0, len(xs)*len(ys)*4)
+	for iy := 0; iy < tileHeight; iy++ {
+		oy := (hintY + iy) % tileHeight
+		for ix := 0; ix < tileWidth; ix++ {
+			ox := (hintX + ix) % tileWidth
+			clear(seen)
+			for ty := 0; ty < rows; ty++ {
+				for tx := 0; tx < cols; tx++ {
+					sample = sample[:0]
+					for _, py := range ys {
+						for _, px := range xs {
+							o := rgba.PixOffset(b.Min.X+ox+tx*tileWidth+px, b.Min.Y+oy+ty*tileHeight+py)
+							sample = append(sample, rgba.Pix[o:o+4]...)
+						}
+					}
+					h := fnv.New64a()
+					h.Write(sample)
+					seen[h.Sum64()] = struct{}{}
+				}
+			}
+			if best < 0 || len(seen) < best {
+				best, dx, dy = len(seen), ox, oy
+			}
+		}
+	}
+	return dx, dy
+}
+
+// samplePoints spreads up to offsetSamples points over [0, n). The first and
+// last pixel are always included so that an offset one pixel off the true
+// grid picks up a neighbouring tile.
+func samplePoints(n int) []int {
+	k := min(n, offsetSamples)
+	if k == 1 {
+		return []int{0}
+	}
+	points := make([]int, k)
+	for i := range points {
+		points[i] = i * (n - 1) / (k - 1)
+	}
+	return points
+}
+
+// asRGBA returns img as an *image.RGBA, converting it if needed.
+func asRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// FindIsometricGridOffset is FindGridOffset for an isometric grid of
+// tileWidth x tileHeight diamonds. The diamond lattice repeats every
+// (tileWidth, 0) and (tileWidth/2, tileHeight/2), so offsets in
+// [0, tileWidth) x [0, tileHeight/2) are searched. Only sample pixels inside
+// each diamond are compared, and because the number of whole diamonds
+// varies with the offset, offsets are ranked by the share of distinct
+// diamonds rather than their count.
+func FindIsometricGridOffset(img image.Image, tileWidth, tileHeight int) (dx, dy int) {
+	hw, hh := tileWidth/2, tileHeight/2
+	if hw == 0 || hh == 0 {
+		return 0, 0
+	}
+	rgba := asRGBA(img)
+	b := rgba.Bounds()
+
+	// As with samplePoints, each sampled row includes its outermost pixels
+	// inside the diamond so that an offset one pixel off the true grid
+	// picks up a neighbouring diamond.
+	var points []image.Point
+	for _, py := range samplePoints(tileHeight) {
+		left := 0
+		for left < hw && !maputils.InDiamond(left, py, tileWidth, tileHeight) {
+			left++
+		}
+		points = append(points, image.Pt(left, py))
+		for _, px := range samplePoints(tileWidth) {
+			if px > left && px < tileWidth-1-left && maputils.InDiamond(px, py, tileWidth, tileHeight) {
+				points = append(points, image.Pt(px, py))
+			}
+		}
+		if tileWidth-1-left > left {
+			points = append(points, image.Pt(tileWidth-1-left, py))
+		}
+	}
+
+	best := 2.0
+	seen := make(map[uint64]struct{})
+	sample := make([]byte, 0, len(points)*4)
+	for oy := 0; oy < hh; oy++ {
+		for ox := 0; ox < tileWidth; ox++ {
+			clear(seen)
+			total := 0
+			// Row s of diamond boxes starts at y = oy + s*hh and alternate
+			// rows are shifted by half a diamond.
+			for y := oy; y+tileHeight <= b.Dy(); y += hh {
+				x0 := (ox + ((y-oy)/hh%2)*hw) % tileWidth
+				for x := x0; x+tileWidth <= b.Dx(); x += tileWidth {
+					sample = sample[:0]
+					for _, p := range points {
+						o := rgba.PixOffset(b.Min.X+x+p.X, b.Min.Y+y+p.Y)
+						sample = append(sample, rgba.Pix[o:o+4]...)
+					}
+					h := fnv.New64a()
+					h.Write(sample)
+					seen[h.Sum64()] = struct{}{}
+					total++
+				}
+			}
+			if total == 0 {
+				continue
+			}
+			if share := float64(len(seen)) / float64(total); share < best {
+				best, dx, dy = share, ox, oy
+			}
+		}
+	}
+	return dx, dy
+}
@@ -0,0 +1,52 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// isoMap paints a w x h map of tileWidth x tileHeight diamonds starting at
+// (ox, oy), choosing one of four textured designs per diamond.
+func isoMap(w, h, tileWidth, tileHeight, ox, oy int) *image.RGBA {
+	hw, hh := float64(tileWidth)/2, float64(tileHeight)/2
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			u := (float64(x-ox)+0.5)/hw - 1
+			v := (float64(y-oy)+0.5)/hh - 1
+			i, j := int(math.Round((u+v)/2)), int(math.Round((v-u)/2))
+			lx := x - ox - (i-j)*tileWidth/2
+			ly := y - oy - (i+j)*tileHeight/2
+			design := ((i*i*7+j*3+i*j)%4 + 4) % 4
+			img.SetRGBA(x, y, color.RGBA{
+				uint8(60 * design),
+				uint8(lx * 255 / tileWidth),
+				uint8(ly * 255 / tileHeight),
+				255,
+			})
+		}
+	}
+	return img
+}
+
+func TestFindIsometricGridOffset(t *testing.T) {
+	tests := []struct {
+		tileWidth, tileHeight int
+		ox, oy                int
+	}{
+		{16, 8, 0, 0},
+		{16, 8, 5, 3},
+		{16, 8, 15, 1},
+		{32, 16, 9, 7},
+		{20, 10, 13, 4},
+	}
+	for _, tc := range tests {
+		img := isoMap(200, 120, tc.tileWidth, tc.tileHeight, tc.ox, tc.oy)
+		dx, dy := FindIsometricGridOffset(img, tc.tileWidth, tc.tileHeight)
+		if dx != tc.ox || dy != tc.oy {
+			t.Errorf("%dx%d tiles at %d,%d: got offset %d,%d", tc.tileWidth, tc.tileHeight, tc.ox, tc.oy, dx, dy)
+		}
+	}
+}
@@ -4,20 +4,59 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"os"
-	"sort"
 )
 
+const (
+	// maxExactPalette is the most colours reported as an exact palette;
+	// images with more are reduced by median cut.
+	maxExactPalette = 256
+	// quantisedPaletteSize is the number of colours median cut produces.
+	quantisedPaletteSize = 16
+	// dominantColours is how many palette colours are listed as dominant.
+	dominantColours = 8
+)
+
+// MapAnalysisResult describes the colours of a map as stored on disk.
 type MapAnalysisResult struct {
-	Width            int
-	Height           int
-	ColorModel       string
-	UniqueColors     int
-	UsesAlpha        bool
-	AvgBrightness    float64
-	BrightnessSpread string
+	Width            int     `json:"width"`
+	Height           int     `json:"height"`
+	ColorModel       string  `json:"colorModel"`
+	UniqueColors     int     `json:"uniqueColors"`
+	UsesAlpha        bool    `json:"usesAlpha"`
+	AvgBrightness    float64 `json:"avgBrightness"`
+	BrightnessSpread string  `json:"brightnessSpread"`
+	// Palette lists every colour when there are at most 256 (PaletteExact)
+	// and a 16 colour median-cut reduction otherwise, most common first.
+	Palette      []PaletteColour `json:"palette"`
+	PaletteExact bool            `json:"paletteExact"`
+	// Dominant is the head of Palette: the colours covering most pixels.
+	Dominant   []PaletteColour   `json:"dominant"`
+	Histograms ChannelHistograms `json:"histograms"`
+}
+
+// PaletteColour is a colour and the share of the image it covers. For a
+// reduced palette the colour is the mean of the colours it stands for.
+type PaletteColour struct {
+	Colour color.NRGBA `json:"-"`
+	// Hex is "#rrggbb", or "#rrggbbaa" for translucent colours.
+	Hex    string `json:"hex"`
+	Pixels int    `json:"pixels"`
+	// Coverage is the percentage of pixels with this colour.
+	Coverage float64 `json:"coverage"`
+}
+
+// ChannelHistograms counts the pixels at each of the 256 levels of every
+// channel. Colour levels are not premultiplied by alpha.
+type ChannelHistograms struct {
+	Red   [256]int `json:"red"`
+	Green [256]int `json:"green"`
+	Blue  [256]int `json:"blue"`
+	Alpha [256]int `json:"alpha"`
 }
 
+// InspectMap decodes the image at imgPath and reports on its colours.
 func InspectMap(imgPath string) (*MapAnalysisResult, error) {
 	file, err := os.Open(imgPath)
 	if err != nil {
@@ -29,48 +68,97 @@ func InspectMap(imgPath string) (*MapAnalysisResult, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
+	return InspectImage(decoded), nil
+}
 
-	// Clean the image before collecting statistics
-	decoded = PreprocessForTraining(decoded)
-
-	bounds := decoded.Bounds()
-	uniqueColors := make(map[color.Color]struct{})
-	brightnessValues := []float64{}
-	usesAlpha := false
-	var totalBrightness float64
+// InspectImage gathers colour statistics from img itself, not from the
+// preprocessed copy used to compare tiles, so the palette and brightness
+// describe the map as drawn.
+func InspectImage(img image.Image) *MapAnalysisResult {
+	bounds := img.Bounds()
+	analysis := &MapAnalysisResult{
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+		ColorModel: colourModelName(img.ColorModel()),
+	}
+	pixels := bounds.Dx() * bounds.Dy()
+	if pixels == 0 {
+		return analysis
+	}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			c := decoded.At(x, y)
-			r, g, b, a := c.RGBA()
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok {
+		nrgba = image.NewNRGBA(bounds)
+		draw.Draw(nrgba, bounds, img, bounds.Min, draw.Src)
+	}
 
-			if a < 0xffff {
-				usesAlpha = true
-			}
+	// Colours are counted by their packed RGBA value, which is much
+	// cheaper than a map keyed by color.Color.
+	counts := make(map[uint32]int)
+	hist := &analysis.Histograms
+	var totalBrightness float64
+	minBrightness, maxBrightness := 255.0, 0.0
+	for y := 0; y < bounds.Dy(); y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+bounds.Dx()*4]
+		for i := 0; i < len(row); i += 4 {
+			r, g, b, a := row[i], row[i+1], row[i+2], row[i+3]
+			hist.Red[r]++
+			hist.Green[g]++
+			hist.Blue[b]++
+			hist.Alpha[a]++
+			counts[uint32(r)<<24|uint32(g)<<16|uint32(b)<<8|uint32(a)]++
 
-			norm := func(v uint32) float64 { return float64(v) / 65535.0 }
-			brightness := (norm(r) + norm(g) + norm(b)) / 3
-			brightnessValues = append(brightnessValues, brightness)
+			brightness := (float64(r) + float64(g) + float64(b)) / 3
 			totalBrightness += brightness
-
-			uniqueColors[c] = struct{}{}
+			minBrightness = min(minBrightness, brightness)
+			maxBrightness = max(maxBrightness, brightness)
 		}
 	}
 
-	sort.Float64s(brightnessValues)
-	min := brightnessValues[0]
-	max := brightnessValues[len(brightnessValues)-1]
-	avg := totalBrightness / float64(len(brightnessValues))
+	analysis.UniqueColors = len(counts)
+	analysis.UsesAlpha = hist.Alpha[255] < pixels
+	analysis.AvgBrightness = totalBrightness / float64(pixels)
+	analysis.BrightnessSpread = fmt.Sprintf("%.0f–%.0f", minBrightness, maxBrightness)
 
-	analysis := &MapAnalysisResult{
-		Width:            bounds.Dx(),
-		Height:           bounds.Dy(),
-		ColorModel:       fmt.Sprintf("%T", decoded.ColorModel()),
-		UniqueColors:     len(uniqueColors),
-		UsesAlpha:        usesAlpha,
-		AvgBrightness:    avg * 255,
-		BrightnessSpread: fmt.Sprintf("%.0f–%.0f", min*255, max*255),
+	if len(counts) <= maxExactPalette {
+		analysis.Palette = exactPalette(counts, pixels)
+		analysis.PaletteExact = true
+	} else {
+		analysis.Palette = medianCut(counts, pixels, quantisedPaletteSize)
 	}
+	analysis.Dominant = analysis.Palette[:min(dominantColours, len(analysis.Palette))]
+	return analysis
+}
 
-	return analysis, nil
+// colourModelName names the standard colour models; others are reported by
+// type.
+func colourModelName(m color.Model) string {
+	switch m {
+	case color.RGBAModel:
+		return "RGBA"
+	case color.RGBA64Model:
+		return "RGBA64"
+	case color.NRGBAModel:
+		return "NRGBA"
+	case color.NRGBA64Model:
+		return "NRGBA64"
+	case color.AlphaModel:
+		return "Alpha"
+	case color.Alpha16Model:
+		return "Alpha16"
+	case color.GrayModel:
+		return "Gray"
+	case color.Gray16Model:
+		return "Gray16"
+	case color.CMYKModel:
+		return "CMYK"
+	case color.YCbCrModel:
+		return "YCbCr"
+	case color.NYCbCrAModel:
+		return "NYCbCrA"
+	}
+	if _, ok := m.(color.Palette); ok {
+		return "Paletted"
+	}
+	return fmt.Sprintf("%T", m)
 }
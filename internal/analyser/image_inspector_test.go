@@ -0,0 +1,81 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestInspectImageExactPalette(t *testing.T) {
+	grass := color.NRGBA{40, 160, 60, 255}
+	water := color.NRGBA{30, 60, 200, 255}
+	glass := color.NRGBA{200, 220, 240, 128}
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for i, c := range []color.NRGBA{grass, grass, grass, grass, grass, water, water, glass} {
+		img.SetNRGBA(i%4, i/4, c)
+	}
+	a := InspectImage(img)
+	if !a.PaletteExact || a.UniqueColors != 3 || !a.UsesAlpha {
+		t.Fatalf("exact %v, %d colours, alpha %v; want exact, 3 colours, alpha", a.PaletteExact, a.UniqueColors, a.UsesAlpha)
+	}
+	want := []PaletteColour{
+		{Colour: grass, Hex: "#28a03c", Pixels: 5, Coverage: 62.5},
+		{Colour: water, Hex: "#1e3cc8", Pixels: 2, Coverage: 25},
+		{Colour: glass, Hex: "#c8dcf080", Pixels: 1, Coverage: 12.5},
+	}
+	if !reflect.DeepEqual(a.Palette, want) {
+		t.Errorf("palette = %+v, want %+v", a.Palette, want)
+	}
+	// Histograms hold the stored levels, not ones premultiplied by alpha.
+	if h := a.Histograms; h.Red[40] != 5 || h.Blue[240] != 1 || h.Alpha[128] != 1 || h.Alpha[255] != 7 {
+		t.Errorf("histograms miscount the levels: red[40]=%d blue[240]=%d alpha[128]=%d alpha[255]=%d",
+			h.Red[40], h.Blue[240], h.Alpha[128], h.Alpha[255])
+	}
+}
+
+func TestInspectImageMedianCut(t *testing.T) {
+	// Sixteen well separated colours, each dithered by a little noise into
+	// far more than 256 distinct ones.
+	var centres []color.NRGBA
+	for i := 0; i < 16; i++ {
+		centres = append(centres, color.NRGBA{uint8(20 + 70*(i%4)), uint8(20 + 70*(i/4)), uint8(40 + 10*i), 255})
+	}
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := centres[(y/16)*4+x/16]
+			jitter := func(v uint8) uint8 { return v + uint8(rng.Intn(7)) - 3 }
+			img.SetNRGBA(x, y, color.NRGBA{jitter(c.R), jitter(c.G), jitter(c.B), 255})
+		}
+	}
+	a := InspectImage(img)
+	if a.PaletteExact || a.UniqueColors <= maxExactPalette {
+		t.Fatalf("exact %v with %d colours, want a reduced palette", a.PaletteExact, a.UniqueColors)
+	}
+	if len(a.Palette) != quantisedPaletteSize || len(a.Dominant) != dominantColours {
+		t.Fatalf("%d palette and %d dominant colours, want %d and %d", len(a.Palette), len(a.Dominant), quantisedPaletteSize, dominantColours)
+	}
+	// Every reduced colour is the mean of one dithered square.
+	found := make(map[color.NRGBA]bool)
+	for _, p := range a.Palette {
+		if p.Pixels != 256 || p.Coverage != 100.0/16 {
+			t.Errorf("%s covers %d pixels (%.2f%%), want one 16x16 square", p.Hex, p.Pixels, p.Coverage)
+		}
+		for _, c := range centres {
+			if near(p.Colour.R, c.R) && near(p.Colour.G, c.G) && near(p.Colour.B, c.B) {
+				found[c] = true
+			}
+		}
+	}
+	if len(found) != len(centres) {
+		t.Errorf("palette %+v matches %d of the %d colours", a.Palette, len(found), len(centres))
+	}
+}
+
+// near reports whether two levels differ by at most 1.
+func near(a, b uint8) bool {
+	return a-b <= 1 || b-a <= 1
+}
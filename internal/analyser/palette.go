@@ -0,0 +1,135 @@
+package analyser
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+)
+
+// paletteEntry is one distinct colour and how many pixels have it.
+type paletteEntry struct {
+	c     [4]uint8
+	count int
+}
+
+// exactPalette lists every colour in counts, most common first.
+func exactPalette(counts map[uint32]int, pixels int) []PaletteColour {
+	entries := unpackColours(counts)
+	palette := make([]PaletteColour, len(entries))
+	for i, e := range entries {
+		palette[i] = newPaletteColour(e.c, e.count, pixels)
+	}
+	sortPalette(palette)
+	return palette
+}
+
+// medianCut reduces counts to at most n colours. Starting from one box
+// holding every colour, it repeatedly splits the box with the widest
+// channel range at the pixel-weighted median of that channel. Each box
+// becomes the pixel-weighted mean of its colours.
+func medianCut(counts map[uint32]int, pixels, n int) []PaletteColour {
+	boxes := [][]paletteEntry{unpackColours(counts)}
+	for len(boxes) < n {
+		best, channel, widest := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, r := widestChannel(box); r > widest {
+				best, channel, widest = i, ch, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].c[channel] < box[j].c[channel] })
+		total := 0
+		for _, e := range box {
+			total += e.count
+		}
+		// Split after the entry that takes the running count past half,
+		// keeping at least one entry on each side.
+		split, seen := 1, 0
+		for i, e := range box[:len(box)-1] {
+			seen += e.count
+			if seen*2 >= total {
+				split = i + 1
+				break
+			}
+		}
+		boxes = append(boxes, box[split:])
+		boxes[best] = box[:split]
+	}
+
+	palette := make([]PaletteColour, len(boxes))
+	for i, box := range boxes {
+		var sum [4]int
+		count := 0
+		for _, e := range box {
+			for ch := range sum {
+				sum[ch] += int(e.c[ch]) * e.count
+			}
+			count += e.count
+		}
+		var mean [4]uint8
+		for ch := range sum {
+			mean[ch] = uint8((sum[ch] + count/2) / count)
+		}
+		palette[i] = newPaletteColour(mean, count, pixels)
+	}
+	sortPalette(palette)
+	return palette
+}
+
+// widestChannel returns the channel with the largest value range in box and
+// that range.
+func widestChannel(box []paletteEntry) (channel, width int) {
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := box[0].c[ch], box[0].c[ch]
+		for _, e := range box[1:] {
+			lo, hi = min(lo, e.c[ch]), max(hi, e.c[ch])
+		}
+		if int(hi-lo) > width {
+			channel, width = ch, int(hi-lo)
+		}
+	}
+	return channel, width
+}
+
+// unpackColours turns colour counts keyed by packed RGBA into entries,
+// ordered by colour so results do not depend on map iteration.
+func unpackColours(counts map[uint32]int) []paletteEntry {
+	keys := make([]uint32, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	entries := make([]paletteEntry, len(keys))
+	for i, k := range keys {
+		entries[i] = paletteEntry{
+			c:     [4]uint8{uint8(k >> 24), uint8(k >> 16), uint8(k >> 8), uint8(k)},
+			count: counts[k],
+		}
+	}
+	return entries
+}
+
+func newPaletteColour(c [4]uint8, count, pixels int) PaletteColour {
+	hex := fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+	if c[3] < 255 {
+		hex += fmt.Sprintf("%02x", c[3])
+	}
+	return PaletteColour{
+		Colour:   color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]},
+		Hex:      hex,
+		Pixels:   count,
+		Coverage: 100 * float64(count) / float64(pixels),
+	}
+}
+
+// sortPalette orders colours by coverage, most common first.
+func sortPalette(palette []PaletteColour) {
+	sort.SliceStable(palette, func(i, j int) bool { return palette[i].Pixels > palette[j].Pixels })
+}
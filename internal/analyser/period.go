@@ -0,0 +1,211 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"sort"
+
+	"tilemap-generator/internal/maputils"
+)
+
+const (
+	// periodTolerance is the largest luma difference at which two pixels
+	// still count as equal when measuring repetition.
+	periodTolerance = 2
+	// maxAxisPeriods is how many pitches are kept per axis.
+	maxAxisPeriods = 4
+	// maxPeriodHypotheses is how many grid hypotheses DetectPeriods returns.
+	maxPeriodHypotheses = 8
+	// harmonicShare is how prominent, relative to a multiple of it, a
+	// pitch must be to count as the tile size behind that multiple.
+	harmonicShare = 0.5
+	// minProminence is the share of the most prominent peak of an axis
+	// below which a peak is taken for noise.
+	minProminence = 0.25
+	// periodRows bounds how many rows (or columns) are scanned per shift.
+	periodRows = 256
+)
+
+// PeriodHypothesis is a tile grid estimated from how the image repeats,
+// before any tiles are compared.
+type PeriodHypothesis struct {
+	TileWidth  int
+	TileHeight int
+	OffsetX    int
+	OffsetY    int
+	// Confidence in [0, 1] is how much more the image repeats at this pitch
+	// than at a typical shift.
+	Confidence float64
+}
+
+// Grid returns the tile grid described by the hypothesis.
+func (h PeriodHypothesis) Grid() maputils.Grid {
+	return maputils.Grid{TileWidth: h.TileWidth, TileHeight: h.TileHeight, OffsetX: h.OffsetX, OffsetY: h.OffsetY}
+}
+
+// axisPeriod is a pitch and offset along one axis.
+type axisPeriod struct {
+	pitch      int
+	offset     int
+	confidence float64
+}
+
+// DetectPeriods estimates the tile grid of img from its autocorrelation.
+// For every horizontal and vertical shift between minPitch and maxPitch it
+// measures the share of pixels equal to the pixel that far away; tile maps
+// repeat at multiples of the tile size, so these shifts stand out as
+// peaks. The offset along each axis is the phase at which the edge
+// projection profile, folded by the pitch, is strongest, since tile
+// boundaries tend to coincide with colour changes. Peaks that barely rise
+// above their neighbouring shifts are dropped as noise, and a pitch whose
+// multiple repeats about as well ranks ahead of the multiple. Hypotheses
+// combine the best pitches of both axes and are ranked by confidence.
+func DetectPeriods(img image.Image, minPitch, maxPitch int) []PeriodHypothesis {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if minPitch < 2 {
+		minPitch = 2
+	}
+	luma := make([]int16, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			luma[y*w+x] = int16(c.Y)
+		}
+	}
+
+	horizontal := detectAxis(func(i, j int) int16 { return luma[j*w+i] }, w, h, minPitch, maxPitch)
+	vertical := detectAxis(func(i, j int) int16 { return luma[i*w+j] }, h, w, minPitch, maxPitch)
+
+	var hypotheses []PeriodHypothesis
+	for _, px := range horizontal {
+		for _, py := range vertical {
+			hypotheses = append(hypotheses, PeriodHypothesis{
+				TileWidth:  px.pitch,
+				TileHeight: py.pitch,
+				OffsetX:    px.offset,
+				OffsetY:    py.offset,
+				Confidence: px.confidence * py.confidence,
+			})
+		}
+	}
+	sort.SliceStable(hypotheses, func(i, j int) bool {
+		a, b := hypotheses[i], hypotheses[j]
+		if a.Confidence != b.Confidence {
+			return a.Confidence > b.Confidence
+		}
+		return a.TileWidth*a.TileHeight < b.TileWidth*b.TileHeight
+	})
+	if len(hypotheses) > maxPeriodHypotheses {
+		hypotheses = hypotheses[:maxPeriodHypotheses]
+	}
+	return hypotheses
+}
+
+// detectAxis finds the strongest pitches along an axis of length n, where
+// at(i, j) is the pixel at position i along the axis in line j of m.
+func detectAxis(at func(i, j int) int16, n, m, minPitch, maxPitch int) []axisPeriod {
+	maxPitch = min(maxPitch, n/2)
+	if maxPitch < minPitch {
+		return nil
+	}
+	step := max(1, m/periodRows)
+
+	// match[p] is the share of pixels equal to the pixel p further along.
+	match := make([]float64, maxPitch+2)
+	for p := minPitch - 1; p <= maxPitch+1 && p < n; p++ {
+		same, total := 0, 0
+		for j := 0; j < m; j += step {
+			for i := 0; i+p < n; i++ {
+				d := at(i, j) - at(i+p, j)
+				if d <= periodTolerance && d >= -periodTolerance {
+					same++
+				}
+				total++
+			}
+		}
+		match[p] = float64(same) / float64(total)
+	}
+
+	sorted := append([]float64(nil), match[minPitch:maxPitch+1]...)
+	sort.Float64s(sorted)
+	baseline := sorted[len(sorted)/2]
+	if baseline >= 1 {
+		return nil
+	}
+
+	// edges[i] is the total change between positions i-1 and i.
+	edges := make([]float64, n)
+	for j := 0; j < m; j += step {
+		for i := 1; i < n; i++ {
+			d := at(i, j) - at(i-1, j)
+			if d < 0 {
+				d = -d
+			}
+			edges[i] += float64(d)
+		}
+	}
+
+	// A tile pitch stands out from the shifts next to it, while shifts
+	// within a tile only drift as the overlap shrinks, so each peak's
+	// prominence over its neighbours tells pitches from noise.
+	var peaks []axisPeriod
+	var prominence []float64
+	strongest := 0.0
+	for p := minPitch; p <= maxPitch; p++ {
+		if match[p] <= match[p-1] || match[p] < match[p+1] || match[p] <= baseline {
+			continue
+		}
+		peaks = append(peaks, axisPeriod{
+			pitch:      p,
+			offset:     foldedPhase(edges, p),
+			confidence: (match[p] - baseline) / (1 - baseline),
+		})
+		prominence = append(prominence, match[p]-max(match[p-1], match[p+1]))
+		strongest = max(strongest, prominence[len(prominence)-1])
+	}
+	// Multiples of the tile size repeat as well as the tile size itself,
+	// so a pitch nearly as prominent as a multiple of it takes the
+	// multiple's confidence and ranks first.
+	for i := range peaks {
+		for j := i + 1; j < len(peaks); j++ {
+			if peaks[j].pitch%peaks[i].pitch == 0 && prominence[i] >= harmonicShare*prominence[j] {
+				peaks[i].confidence = max(peaks[i].confidence, peaks[j].confidence)
+			}
+		}
+	}
+	kept := peaks[:0]
+	for i, pk := range peaks {
+		if prominence[i] >= minProminence*strongest {
+			kept = append(kept, pk)
+		}
+	}
+	peaks = kept
+	sort.SliceStable(peaks, func(i, j int) bool {
+		return peaks[i].confidence > peaks[j].confidence
+	})
+	if len(peaks) > maxAxisPeriods {
+		peaks = peaks[:maxAxisPeriods]
+	}
+	return peaks
+}
+
+// foldedPhase returns the phase in [0, pitch) whose positions carry the most
+// edge strength on average.
+func foldedPhase(edges []float64, pitch int) int {
+	best, phase := -1.0, 0
+	for r := 0; r < pitch; r++ {
+		sum, count := 0.0, 0
+		for i := r; i < len(edges); i += pitch {
+			if i == 0 {
+				continue
+			}
+			sum += edges[i]
+			count++
+		}
+		if count > 0 && sum/float64(count) > best {
+			best, phase = sum/float64(count), r
+		}
+	}
+	return phase
+}
@@ -0,0 +1,69 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// scatterMap paints a w x h map of square tiles, each a random one of
+// four designs with a noisy texture, as tiles are placed in real maps.
+func scatterMap(w, h, tile int, seed int64) *image.RGBA {
+	const designs = 4
+	rng := rand.New(rand.NewSource(seed))
+	textures := make([][]uint8, designs)
+	for d := range textures {
+		textures[d] = make([]uint8, tile*tile)
+		for i := range textures[d] {
+			textures[d][i] = uint8(50*d + rng.Intn(24))
+		}
+	}
+	cols := (w + tile - 1) / tile
+	picks := make([]int, cols*((h+tile-1)/tile))
+	for i := range picks {
+		picks[i] = rng.Intn(designs)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := textures[picks[y/tile*cols+x/tile]][y%tile*tile+x%tile]
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestDetectPeriods(t *testing.T) {
+	// Cropping at (11, 29) moves the first grid line off the image origin.
+	crop := image.Rect(11, 29, 630, 470)
+	tests := []struct {
+		name string
+		img  image.Image
+		want PeriodHypothesis
+	}{
+		{"24px off-grid", scatterMap(640, 480, 24, 1).SubImage(crop), PeriodHypothesis{TileWidth: 24, TileHeight: 24, OffsetX: 13, OffsetY: 19}},
+		{"48px off-grid", scatterMap(640, 480, 48, 1).SubImage(crop), PeriodHypothesis{TileWidth: 48, TileHeight: 48, OffsetX: 37, OffsetY: 19}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hypotheses := DetectPeriods(tc.img, 8, 256)
+			if len(hypotheses) == 0 {
+				t.Fatal("no period detected")
+			}
+			got := hypotheses[0]
+			if got.Confidence <= 0 || got.Confidence > 1 {
+				t.Errorf("confidence %v is outside (0, 1]", got.Confidence)
+			}
+			got.Confidence = 0
+			if got != tc.want {
+				t.Errorf("best hypothesis %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	// A flat image repeats at every shift, so no pitch stands out.
+	if got := DetectPeriods(image.NewGray(image.Rect(0, 0, 64, 64)), 8, 32); got != nil {
+		t.Errorf("flat image: DetectPeriods = %+v, want none", got)
+	}
+}
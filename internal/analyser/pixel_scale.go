@@ -0,0 +1,213 @@
+package analyser
+
+import (
+	"image"
+	"image/draw"
+
+	"tilemap-generator/internal/maputils"
+)
+
+const (
+	// maxPixelScale is the largest upscaling factor DetectPixelScale tries.
+	maxPixelScale = 8
+	// pixelScaleTolerance is the largest per-channel difference between
+	// neighbouring pixels that still counts as the same colour, so JPEG
+	// noise inside a block is not mistaken for a change.
+	pixelScaleTolerance = 32
+	// pixelScaleShare is the share of colour change that must fall on
+	// block boundaries for a scale to be accepted.
+	pixelScaleShare = 0.85
+	// minPixelChanges is how many colour changes an axis needs before its
+	// scale is trusted.
+	minPixelChanges = 64
+)
+
+// PixelScale describes pixel art upscaled by nearest-neighbour: every
+// source pixel became a Scale x Scale block, with block boundaries at
+// columns congruent to PhaseX and rows congruent to PhaseY modulo Scale.
+// A Scale of 1 means the image is at native resolution.
+type PixelScale struct {
+	Scale  int
+	PhaseX int
+	PhaseY int
+	// Confidence is the share of colour change that falls on block
+	// boundaries along the weaker axis.
+	Confidence float64
+}
+
+// DetectPixelScale finds the integer factor by which img was upscaled. In
+// upscaled pixel art the colour only changes between NxN blocks, so nearly
+// all change between neighbouring pixels lies on a block boundary; in
+// native art it is spread evenly. Each change is weighted by how far the
+// difference in luma, or in alpha, exceeds pixelScaleTolerance, so JPEG
+// noise inside a block and ringing next to a boundary count for little.
+// Luma is used rather than the colour channels because JPEG usually
+// stores colour at half resolution, which moves colour edges off odd
+// block boundaries. The largest factor up to 8 for which at least 85% of
+// the change on both axes falls on boundaries wins.
+func DetectPixelScale(img image.Image) PixelScale {
+	rgba := asRGBA(img)
+	b := rgba.Bounds()
+	w, h := b.Dx(), b.Dy()
+	change := func(o1, o2 int) int {
+		d := luma(rgba.Pix[o1:]) - luma(rgba.Pix[o2:])
+		d = max(d, -d, int(absDiff(rgba.Pix[o1+3], rgba.Pix[o2+3])))
+		return max(d-pixelScaleTolerance, 0)
+	}
+
+	// cols[x] is the change between columns x-1 and x; rows likewise.
+	cols := make([]int, w)
+	rows := make([]int, h)
+	colChanges, rowChanges := 0, 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			o := rgba.PixOffset(b.Min.X+x, b.Min.Y+y)
+			if x > 0 {
+				if d := change(o, o-4); d > 0 {
+					cols[x] += d
+					colChanges++
+				}
+			}
+			if y > 0 {
+				if d := change(o, o-rgba.Stride); d > 0 {
+					rows[y] += d
+					rowChanges++
+				}
+			}
+		}
+	}
+	if colChanges < minPixelChanges || rowChanges < minPixelChanges {
+		return PixelScale{Scale: 1}
+	}
+
+	for s := maxPixelScale; s >= 2; s-- {
+		px, sx := boundaryPhase(cols, s)
+		py, sy := boundaryPhase(rows, s)
+		if share := min(sx, sy); share >= pixelScaleShare {
+			return PixelScale{Scale: s, PhaseX: px, PhaseY: py, Confidence: share}
+		}
+	}
+	return PixelScale{Scale: 1}
+}
+
+// luma returns the Rec. 601 luma (0-255) of the RGBA pixel at the start of
+// pix.
+func luma(pix []uint8) int {
+	return (299*int(pix[0]) + 587*int(pix[1]) + 114*int(pix[2]) + 500) / 1000
+}
+
+// boundaryPhase returns the phase modulo scale holding the most change and
+// the share of all change it holds.
+func boundaryPhase(changes []int, scale int) (phase int, share float64) {
+	sums := make([]int, scale)
+	total := 0
+	for i, n := range changes {
+		sums[i%scale] += n
+		total += n
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	for r, n := range sums {
+		if n > sums[phase] {
+			phase = r
+		}
+	}
+	return phase, float64(sums[phase]) / float64(total)
+}
+
+// Normalise downscales img to its native resolution, taking the centre
+// pixel of every block. Partial blocks at the edges are kept. An image at
+// scale 1 is returned unchanged.
+func (p PixelScale) Normalise(img image.Image) image.Image {
+	if p.Scale <= 1 {
+		return img
+	}
+	src := asRGBA(img)
+	b := src.Bounds()
+	// Block k along an axis covers [phase+(k-lead)*s, phase+(k-lead+1)*s),
+	// where lead is 1 if a partial block precedes the phase.
+	axis := func(n, phase int) (lead, count int) {
+		if phase > 0 {
+			lead = 1
+		}
+		return lead, lead + (n-phase+p.Scale-1)/p.Scale
+	}
+	leadX, cols := axis(b.Dx(), p.PhaseX)
+	leadY, rows := axis(b.Dy(), p.PhaseY)
+	centre := func(k, lead, phase, n int) int {
+		lo := max(phase+(k-lead)*p.Scale, 0)
+		hi := min(phase+(k-lead+1)*p.Scale, n)
+		return (lo + hi - 1) / 2
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, cols, rows))
+	for y := 0; y < rows; y++ {
+		sy := centre(y, leadY, p.PhaseY, b.Dy())
+		for x := 0; x < cols; x++ {
+			sx := centre(x, leadX, p.PhaseX, b.Dx())
+			so := src.PixOffset(b.Min.X+sx, b.Min.Y+sy)
+			copy(out.Pix[out.PixOffset(x, y):], src.Pix[so:so+4])
+		}
+	}
+	return out
+}
+
+// SourceBounds returns the rectangle of src covered by the blocks behind
+// a native image of the given size, as cut by Normalise. Partial blocks at
+// the edges make it overhang src by less than one block on each side. An
+// image at scale 1 covers src exactly.
+func (p PixelScale) SourceBounds(src image.Rectangle, native image.Point) image.Rectangle {
+	if p.Scale <= 1 {
+		return src
+	}
+	// The first block starts one block before the phase when a partial
+	// block precedes it, as in Normalise.
+	origin := func(phase int) int {
+		if phase > 0 {
+			return phase - p.Scale
+		}
+		return 0
+	}
+	corner := src.Min.Add(image.Pt(origin(p.PhaseX), origin(p.PhaseY)))
+	return image.Rectangle{Min: corner, Max: corner.Add(native.Mul(p.Scale))}
+}
+
+// SourcePixels returns how many source pixels n native pixels stand for,
+// counting every one as a whole block.
+func (p PixelScale) SourcePixels(n int) int {
+	return n * max(p.Scale, 1) * max(p.Scale, 1)
+}
+
+// UpscaleGrid returns g with every length multiplied by the scale, so it
+// cuts the SourceBounds of an image into the same cells as g cuts the
+// native image.
+func (p PixelScale) UpscaleGrid(g maputils.Grid) maputils.Grid {
+	if p.Scale <= 1 {
+		return g
+	}
+	g.TileWidth *= p.Scale
+	g.TileHeight *= p.Scale
+	g.OffsetX *= p.Scale
+	g.OffsetY *= p.Scale
+	g.Margin *= p.Scale
+	g.Spacing *= p.Scale
+	return g
+}
+
+// Upscale enlarges a tile or image by the scale with nearest-neighbour
+// sampling, undoing Normalise.
+func (p PixelScale) Upscale(img image.Image) image.Image {
+	if p.Scale <= 1 {
+		return img
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()*p.Scale, b.Dy()*p.Scale))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r := image.Rect(x*p.Scale, y*p.Scale, (x+1)*p.Scale, (y+1)*p.Scale)
+			draw.Draw(out, r, &image.Uniform{C: img.At(b.Min.X+x, b.Min.Y+y)}, image.Point{}, draw.Src)
+		}
+	}
+	return out
+}
@@ -0,0 +1,156 @@
+package analyser
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+)
+
+// blockSheet returns a w x h image drawn at the given scale and phase, each
+// pixel coloured by the block it belongs to, with partial blocks at the
+// edges.
+func blockSheet(w, h, scale, phaseX, phaseY int) *image.RGBA {
+	block := func(v, phase int) int {
+		return (v - phase + scale) / scale
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(block(x, phaseX)), uint8(block(y, phaseY)), 0, 255})
+		}
+	}
+	return img
+}
+
+func TestPixelScaleSourceLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		p    PixelScale
+		w, h int
+	}{
+		{"aligned", PixelScale{Scale: 3}, 60, 45},
+		{"phase", PixelScale{Scale: 3, PhaseX: 2, PhaseY: 1}, 61, 44},
+		{"trailing partial", PixelScale{Scale: 4, PhaseX: 0, PhaseY: 3}, 58, 50},
+	}
+	grids := []maputils.Grid{
+		{TileWidth: 4, TileHeight: 3, OffsetX: 1, OffsetY: 2},
+		{TileWidth: 4, TileHeight: 3, OffsetX: 1, OffsetY: 2, Edge: maputils.EdgePartial},
+		{TileWidth: 3, TileHeight: 3, Margin: 1, Spacing: 1},
+		{TileWidth: 4, TileHeight: 2, OffsetX: 1, Orientation: maputils.Isometric},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			src := blockSheet(tc.w, tc.h, tc.p.Scale, tc.p.PhaseX, tc.p.PhaseY)
+			native := tc.p.Normalise(src)
+			nb := native.Bounds()
+			layout := tc.p.SourceBounds(src.Bounds(), nb.Size())
+			if !src.Bounds().In(layout) || layout.Dx()-src.Bounds().Dx() >= 2*tc.p.Scale || layout.Dy()-src.Bounds().Dy() >= 2*tc.p.Scale {
+				t.Fatalf("SourceBounds = %v for a %v source", layout, src.Bounds())
+			}
+
+			// Every source pixel lies in the block of the native pixel
+			// sampled from it.
+			for y := 0; y < tc.h; y++ {
+				for x := 0; x < tc.w; x++ {
+					nx, ny := (x-layout.Min.X)/tc.p.Scale, (y-layout.Min.Y)/tc.p.Scale
+					if got, want := native.At(nx, ny), src.At(x, y); got != want {
+						t.Fatalf("source pixel (%d, %d) is %v but falls in native pixel (%d, %d) = %v", x, y, want, nx, ny, got)
+					}
+				}
+			}
+
+			for _, g := range grids {
+				up := tc.p.UpscaleGrid(g)
+				cols, rows := g.Dims(nb)
+				if upCols, upRows := up.Dims(layout); upCols != cols || upRows != rows {
+					t.Fatalf("%+v: upscaled grid is %dx%d, native %dx%d", g, upCols, upRows, cols, rows)
+				}
+				for y := 0; y < rows; y++ {
+					for x := 0; x < cols; x++ {
+						box := g.Cell(nb, x, y)
+						want := image.Rectangle{Min: box.Min.Mul(tc.p.Scale), Max: box.Max.Mul(tc.p.Scale)}.Add(layout.Min)
+						if got := up.Cell(layout, x, y); got != want {
+							t.Fatalf("%+v: cell (%d, %d) = %v, want %v", g, x, y, got, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// pixelArt returns w x h native pixels drawn from a small palette in runs,
+// like hand-drawn pixel art, upscaled by scale with nearest-neighbour.
+func pixelArt(w, h, scale int) *image.RGBA {
+	palette := []color.RGBA{
+		{34, 32, 52, 255}, {69, 40, 60, 255}, {102, 57, 49, 255}, {143, 86, 59, 255},
+		{223, 113, 38, 255}, {217, 160, 102, 255}, {106, 190, 48, 255}, {91, 110, 225, 255},
+	}
+	rng := rand.New(rand.NewSource(int64(w*h + scale)))
+	native := make([]color.RGBA, w*h)
+	for i := range native {
+		switch {
+		case i%w > 0 && rng.Intn(3) > 0:
+			native[i] = native[i-1]
+		case i >= w && rng.Intn(2) > 0:
+			native[i] = native[i-w]
+		default:
+			native[i] = palette[rng.Intn(len(palette))]
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w*scale, h*scale))
+	for y := 0; y < h*scale; y++ {
+		for x := 0; x < w*scale; x++ {
+			img.SetRGBA(x, y, native[y/scale*w+x/scale])
+		}
+	}
+	return img
+}
+
+// jpegRoundTrip encodes img as a JPEG of the given quality and decodes it.
+func jpegRoundTrip(t *testing.T, img image.Image, quality int) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDetectPixelScale(t *testing.T) {
+	for _, scale := range []int{1, 2, 3, 4} {
+		art := pixelArt(48, 36, scale)
+		inputs := []struct {
+			name string
+			img  image.Image
+		}{
+			{"clean", art},
+			{"jpeg q95", jpegRoundTrip(t, art, 95)},
+			{"jpeg q85", jpegRoundTrip(t, art, 85)},
+		}
+		for _, in := range inputs {
+			t.Run(fmt.Sprintf("%dx %s", scale, in.name), func(t *testing.T) {
+				got := DetectPixelScale(in.img)
+				if got.Scale != scale || got.PhaseX != 0 || got.PhaseY != 0 {
+					t.Errorf("DetectPixelScale = %+v, want scale %d at phase 0", got, scale)
+				}
+			})
+		}
+	}
+
+	// Cropping shifts the block boundaries.
+	cropped := pixelArt(48, 36, 3).SubImage(image.Rect(2, 1, 140, 100))
+	if got := DetectPixelScale(cropped); got.Scale != 3 || got.PhaseX != 1 || got.PhaseY != 2 {
+		t.Errorf("cropped: DetectPixelScale = %+v, want scale 3 at phase (1, 2)", got)
+	}
+}
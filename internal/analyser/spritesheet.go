@@ -0,0 +1,264 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+
+	"tilemap-generator/internal/maputils"
+)
+
+const (
+	// separatorTolerance is the largest per-channel difference between
+	// pixels of one separator line.
+	separatorTolerance = 8
+	// minSheetTile is the smallest tile edge looked for inside an atlas
+	// border with no gutters.
+	minSheetTile = 4
+	// boundaryShare is how much of the strongest average colour change a
+	// smaller pitch's tile boundaries need inside an atlas border with no
+	// gutters.
+	boundaryShare = 0.8
+)
+
+// DetectSpritesheet looks for the layout of a tile atlas: tiles separated by
+// constant-colour gutter lines, optionally inside a border of the same
+// colour. Separator lines are full rows and columns of the most common
+// uniform-line colour (transparent pixels count as one colour). The tile
+// size and gutter are read from the runs between and of those lines, and
+// the layout is only accepted if separators repeat at one pitch from border
+// to border on both axes (see layoutAxis), both axes agree on margin and
+// spacing, and the border is thinner than a tile, so a single road or wall
+// of one colour on an ordinary map does not make it an atlas. An atlas with
+// a border but no gutters has no separators between tiles, so its tile size
+// is found from the colour changes inside the border (see
+// borderedTileSize).
+func DetectSpritesheet(img image.Image) (maputils.Grid, bool) {
+	rgba := asRGBA(img)
+	b := rgba.Bounds()
+	w, h := b.Dx(), b.Dy()
+	at := func(x, y int) color.RGBA {
+		o := rgba.PixOffset(b.Min.X+x, b.Min.Y+y)
+		return color.RGBA{rgba.Pix[o], rgba.Pix[o+1], rgba.Pix[o+2], rgba.Pix[o+3]}
+	}
+
+	colColour, colUniform := uniformLines(func(i, j int) color.RGBA { return at(i, j) }, w, h)
+	rowColour, rowUniform := uniformLines(func(i, j int) color.RGBA { return at(j, i) }, h, w)
+
+	counts := make(map[color.RGBA]int)
+	for i, c := range colColour {
+		if colUniform[i] {
+			counts[separatorKey(c)]++
+		}
+	}
+	for i, c := range rowColour {
+		if rowUniform[i] {
+			counts[separatorKey(c)]++
+		}
+	}
+	var sep color.RGBA
+	best := 0
+	for c, n := range counts {
+		if n > best || (n == best && colourLess(c, sep)) {
+			sep, best = c, n
+		}
+	}
+	if best == 0 {
+		return maputils.Grid{}, false
+	}
+
+	isSep := func(colours []color.RGBA, uniform []bool) []bool {
+		out := make([]bool, len(colours))
+		for i, c := range colours {
+			out[i] = uniform[i] && sameColour(separatorKey(c), sep)
+		}
+		return out
+	}
+	mx, tw, sx, okX := layoutAxis(isSep(colColour, colUniform))
+	my, th, sy, okY := layoutAxis(isSep(rowColour, rowUniform))
+	if !okX || !okY || mx != my || sx != sy {
+		return maputils.Grid{}, false
+	}
+	if sx == 0 {
+		tw, th, okX = borderedTileSize(func(x, y int) color.RGBA { return at(mx+x, my+y) }, tw, th)
+		if !okX {
+			return maputils.Grid{}, false
+		}
+	}
+	if mx >= tw || my >= th {
+		return maputils.Grid{}, false
+	}
+	return maputils.Grid{TileWidth: tw, TileHeight: th, Margin: mx, Spacing: sx}, true
+}
+
+// borderedTileSize finds the tile size of the area inside an atlas border
+// with no gutters. Neighbouring tiles of an atlas rarely continue each
+// other, so tile boundaries are where the colour changes most: each axis
+// takes the smallest pitch that splits the area evenly into two or more
+// tiles and whose boundaries change nearly as much, on average, as those of
+// the best such pitch.
+func borderedTileSize(at func(x, y int) color.RGBA, w, h int) (int, int, bool) {
+	tw := boundaryPitch(lineChanges(at, w, h))
+	th := boundaryPitch(lineChanges(func(x, y int) color.RGBA { return at(y, x) }, h, w))
+	return tw, th, tw > 0 && th > 0
+}
+
+// lineChanges returns, for each of n positions along an axis, the total
+// colour change from the previous position over all m lines.
+func lineChanges(at func(i, j int) color.RGBA, n, m int) []float64 {
+	changes := make([]float64, n)
+	for i := 1; i < n; i++ {
+		for j := 0; j < m; j++ {
+			a, b := at(i-1, j), at(i, j)
+			changes[i] += float64(absDiff(a.R, b.R)) + float64(absDiff(a.G, b.G)) +
+				float64(absDiff(a.B, b.B)) + float64(absDiff(a.A, b.A))
+		}
+	}
+	return changes
+}
+
+// boundaryPitch picks the tile pitch along an axis from its colour changes,
+// or returns 0 if no pitch of at least minSheetTile fits.
+func boundaryPitch(changes []float64) int {
+	n := len(changes)
+	scores := make(map[int]float64)
+	best := 0.0
+	for p := minSheetTile; p <= n/2; p++ {
+		if n%p != 0 {
+			continue
+		}
+		sum := 0.0
+		for i := p; i < n; i += p {
+			sum += changes[i]
+		}
+		scores[p] = sum / float64(n/p-1)
+		best = max(best, scores[p])
+	}
+	if best == 0 {
+		return 0
+	}
+	for p := minSheetTile; p <= n/2; p++ {
+		if score, ok := scores[p]; ok && score >= boundaryShare*best {
+			return p
+		}
+	}
+	return 0
+}
+
+// uniformLines reports, for each of n lines of length m, its first pixel and
+// whether every pixel on it matches that one. at(i, j) is pixel j of line i.
+func uniformLines(at func(i, j int) color.RGBA, n, m int) ([]color.RGBA, []bool) {
+	colours := make([]color.RGBA, n)
+	uniform := make([]bool, n)
+	for i := 0; i < n; i++ {
+		first := separatorKey(at(i, 0))
+		colours[i] = first
+		uniform[i] = true
+		for j := 1; j < m; j++ {
+			if !sameColour(separatorKey(at(i, j)), first) {
+				uniform[i] = false
+				break
+			}
+		}
+	}
+	return colours, uniform
+}
+
+// layoutAxis reads margin, tile size and spacing from the separator lines
+// of one axis. Between the leading and trailing separators the axis must
+// alternate between tile runs of one length and gutter runs of another,
+// with at least two tiles, and the separators must repeat: either there
+// are two or more gutters or the tiles have a border. An axis with
+// a border on both ends but no gutters reports spacing 0 and the whole
+// interior as its size, leaving the tile size to the caller.
+func layoutAxis(sep []bool) (margin, size, spacing int, ok bool) {
+	n := len(sep)
+	for margin < n && sep[margin] {
+		margin++
+	}
+	end := n
+	for end > margin && sep[end-1] {
+		end--
+	}
+	if end == margin {
+		return 0, 0, 0, false
+	}
+
+	// Tally run lengths of tile lines and of separator lines between tiles.
+	sizes := make(map[int]int)
+	gaps := make(map[int]int)
+	for i := margin; i < end; {
+		j := i
+		for j < end && sep[j] == sep[i] {
+			j++
+		}
+		if !sep[i] {
+			sizes[j-i]++
+		} else {
+			gaps[j-i]++
+		}
+		i = j
+	}
+	if len(gaps) == 0 {
+		if margin == 0 || end == n {
+			return 0, 0, 0, false
+		}
+		return margin, end - margin, 0, true
+	}
+	if len(sizes) != 1 || len(gaps) != 1 {
+		return 0, 0, 0, false
+	}
+	size, spacing = modeOf(sizes), modeOf(gaps)
+	if tiles := sizes[size]; tiles < 2 || (tiles < 3 && margin == 0 && end == n) {
+		return 0, 0, 0, false
+	}
+	return margin, size, spacing, true
+}
+
+// modeOf returns the most common key of counts, preferring the smaller key
+// on ties, or 0 if counts is empty.
+func modeOf(counts map[int]int) int {
+	best, mode := 0, 0
+	for k, n := range counts {
+		if n > best || (n == best && k < mode) {
+			best, mode = n, k
+		}
+	}
+	return mode
+}
+
+// separatorKey collapses every fully transparent pixel to one colour.
+func separatorKey(c color.RGBA) color.RGBA {
+	if c.A == 0 {
+		return color.RGBA{}
+	}
+	return c
+}
+
+func sameColour(a, b color.RGBA) bool {
+	return absDiff(a.R, b.R) <= separatorTolerance &&
+		absDiff(a.G, b.G) <= separatorTolerance &&
+		absDiff(a.B, b.B) <= separatorTolerance &&
+		absDiff(a.A, b.A) <= separatorTolerance
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// colourLess orders colours so ties between separator candidates are
+// broken the same way on every run.
+func colourLess(a, b color.RGBA) bool {
+	if a.R != b.R {
+		return a.R < b.R
+	}
+	if a.G != b.G {
+		return a.G < b.G
+	}
+	if a.B != b.B {
+		return a.B < b.B
+	}
+	return a.A < b.A
+}
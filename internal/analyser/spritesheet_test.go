@@ -0,0 +1,95 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+)
+
+var (
+	gutterColour = color.RGBA{255, 0, 255, 255}
+	roadColour   = color.RGBA{90, 90, 90, 255}
+)
+
+// atlasSheet lays out cols x rows textured tiles of tileWidth x tileHeight
+// inside a margin, with spacing between them, on a gutter-coloured sheet.
+func atlasSheet(cols, rows, tileWidth, tileHeight, margin, spacing int) *image.RGBA {
+	w := 2*margin + cols*tileWidth + (cols-1)*spacing
+	h := 2*margin + rows*tileHeight + (rows-1)*spacing
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{gutterColour}, image.Point{}, draw.Src)
+	for ty := 0; ty < rows; ty++ {
+		for tx := 0; tx < cols; tx++ {
+			x0 := margin + tx*(tileWidth+spacing)
+			y0 := margin + ty*(tileHeight+spacing)
+			for ly := 0; ly < tileHeight; ly++ {
+				for lx := 0; lx < tileWidth; lx++ {
+					img.SetRGBA(x0+lx, y0+ly, color.RGBA{
+						uint8(40 * ((tx*5 + ty*3) % 6)),
+						uint8(lx * 255 / tileWidth),
+						uint8(ly * 255 / tileHeight),
+						255,
+					})
+				}
+			}
+		}
+	}
+	return img
+}
+
+// withRoads paints full-width road rows and full-height road columns of
+// the given thickness onto img.
+func withRoads(img *image.RGBA, thickness int, rows, cols []int) *image.RGBA {
+	b := img.Bounds()
+	for _, y := range rows {
+		draw.Draw(img, image.Rect(b.Min.X, y, b.Max.X, y+thickness), &image.Uniform{roadColour}, image.Point{}, draw.Src)
+	}
+	for _, x := range cols {
+		draw.Draw(img, image.Rect(x, b.Min.Y, x+thickness, b.Max.Y), &image.Uniform{roadColour}, image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// framed surrounds img with a flat border of the given width.
+func framed(img *image.RGBA, border int) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()+2*border, b.Dy()+2*border))
+	draw.Draw(out, out.Bounds(), &image.Uniform{gutterColour}, image.Point{}, draw.Src)
+	draw.Draw(out, b.Add(image.Pt(border, border)), img, b.Min, draw.Src)
+	return out
+}
+
+func TestDetectSpritesheet(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *image.RGBA
+		want maputils.Grid
+		ok   bool
+	}{
+		{"margin and spacing", atlasSheet(6, 4, 16, 16, 2, 1), maputils.Grid{TileWidth: 16, TileHeight: 16, Margin: 2, Spacing: 1}, true},
+		{"spacing only", atlasSheet(4, 3, 16, 24, 0, 2), maputils.Grid{TileWidth: 16, TileHeight: 24, Spacing: 2}, true},
+		{"margin only", atlasSheet(6, 5, 16, 16, 3, 0), maputils.Grid{TileWidth: 16, TileHeight: 16, Margin: 3}, true},
+		{"rectangular margin only", atlasSheet(4, 6, 32, 16, 1, 0), maputils.Grid{TileWidth: 32, TileHeight: 16, Margin: 1}, true},
+		// One gutter and no border is a single line, not a repeated one.
+		{"two tiles no border", atlasSheet(2, 2, 16, 16, 0, 1), maputils.Grid{}, false},
+		// A crossroads through the middle of a map splits it into two
+		// equal halves per axis.
+		{"crossroads", withRoads(rectMap(200, 150, 16, 16, 0, 0), 4, []int{73}, []int{98}), maputils.Grid{}, false},
+		// Roads that do not repeat at one pitch.
+		{"uneven roads", withRoads(rectMap(200, 150, 16, 16, 0, 0), 4, []int{30, 70, 130}, []int{40, 90, 140}), maputils.Grid{}, false},
+		// A flat frame as wide as a tile surrounds a map, not an atlas.
+		{"thick frame", framed(rectMap(160, 120, 40, 40, 0, 0), 40), maputils.Grid{}, false},
+		{"plain map", rectMap(200, 150, 16, 16, 5, 3), maputils.Grid{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := DetectSpritesheet(tc.img)
+			if ok != tc.ok || got != tc.want {
+				t.Errorf("DetectSpritesheet = %+v, %v, want %+v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
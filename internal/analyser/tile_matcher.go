@@ -49,18 +49,18 @@ func hammingDistance(a, b string) int {
 }
 
 // SaveDiagnosticGrid creates a PNG showing all tiles with coloured borders for their groups.
-func SaveDiagnosticGrid(tiles []image.Image, groups []int, tileSize int, path string) error {
+func SaveDiagnosticGrid(tiles []image.Image, groups []int, tileWidth, tileHeight int, path string) error {
 	if len(tiles) == 0 {
 		return nil
 	}
 	cols := int(math.Ceil(math.Sqrt(float64(len(tiles)))))
 	rows := int(math.Ceil(float64(len(tiles)) / float64(cols)))
 
-	outImg := image.NewRGBA(image.Rect(0, 0, cols*tileSize, rows*tileSize))
+	outImg := image.NewRGBA(image.Rect(0, 0, cols*tileWidth, rows*tileHeight))
 	for idx, t := range tiles {
-		x := (idx % cols) * tileSize
-		y := (idx / cols) * tileSize
-		r := image.Rect(x, y, x+tileSize, y+tileSize)
+		x := (idx % cols) * tileWidth
+		y := (idx / cols) * tileHeight
+		r := image.Rect(x, y, x+tileWidth, y+tileHeight)
 		draw.Draw(outImg, r, t, image.Point{}, draw.Src)
 		drawBorder(outImg, r, colourForGroup(groups[idx]))
 	}
@@ -0,0 +1,176 @@
+package analyser
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// signatureGrid is the side of the coarse grid of mean colours used to
+	// prefilter tile comparisons.
+	signatureGrid = 4
+	// signatureSlack is how far, as a multiple of the threshold, two
+	// signatures may differ before the tiles are not compared pixel by
+	// pixel. Averaging hides small details, so a signature difference is
+	// rarely larger than the tiles' mean difference.
+	signatureSlack = 2
+)
+
+// labTile is a tile converted for comparison: per-pixel L*a*b* colours and
+// alpha, plus a coarse signature of mean colours.
+type labTile struct {
+	size      image.Point
+	pix       []lab
+	alpha     []float64
+	signature []lab
+}
+
+// GroupSimilarTiles groups tiles that look the same despite lossy
+// compression. Two tiles match when their mean per-pixel CIEDE2000
+// difference is at most maxDeltaE; see tileDifference. Each tile joins the
+// first group whose leading tile it matches, or starts a new group.
+//
+// Hashes only prefilter the comparison: tiles with identical pixels join
+// the same group without being compared, and a tile is only compared with
+// a group whose coarse colour signature is close to its own.
+//
+// It returns each tile's group and, per group, the index of the tile that
+// best represents it: the member closest to the group's mean colours.
+func GroupSimilarTiles(tiles []image.Image, maxDeltaE float64) (groups, representatives []int) {
+	groups = make([]int, len(tiles))
+	exact := make(map[string]int)
+	var leaders []*labTile
+
+	for i, t := range tiles {
+		rgba := asRGBA(t)
+		key := string(rgba.Pix)
+		if g, ok := exact[key]; ok {
+			groups[i] = g
+			continue
+		}
+
+		lt := newLabTile(rgba)
+		g := -1
+		for id, leader := range leaders {
+			if leader.size != lt.size || signatureDifference(leader, lt) > signatureSlack*maxDeltaE {
+				continue
+			}
+			if tileDifference(leader, lt) <= maxDeltaE {
+				g = id
+				break
+			}
+		}
+		if g < 0 {
+			g = len(leaders)
+			leaders = append(leaders, lt)
+		}
+		exact[key] = g
+		groups[i] = g
+	}
+
+	return groups, groupRepresentatives(tiles, groups, len(leaders))
+}
+
+// groupRepresentatives picks, for each group, the member whose colours are
+// closest to the group's per-pixel mean. For a group of noisy copies of
+// one tile that is the least noisy copy.
+func groupRepresentatives(tiles []image.Image, groups []int, n int) []int {
+	members := make([][]int, n)
+	for i, g := range groups {
+		members[g] = append(members[g], i)
+	}
+
+	reps := make([]int, n)
+	for g, idx := range members {
+		reps[g] = idx[0]
+		if len(idx) < 3 {
+			continue
+		}
+		pix := make([][]uint8, len(idx))
+		for k, i := range idx {
+			pix[k] = asRGBA(tiles[i]).Pix
+		}
+		mean := make([]float64, len(pix[0]))
+		for _, p := range pix {
+			for j, v := range p {
+				mean[j] += float64(v)
+			}
+		}
+		for j := range mean {
+			mean[j] /= float64(len(pix))
+		}
+		best := math.Inf(1)
+		for k, p := range pix {
+			var d float64
+			for j, v := range p {
+				e := float64(v) - mean[j]
+				d += e * e
+			}
+			if d < best {
+				best, reps[g] = d, idx[k]
+			}
+		}
+	}
+	return reps
+}
+
+func newLabTile(rgba *image.RGBA) *labTile {
+	b := rgba.Bounds()
+	w, h := b.Dx(), b.Dy()
+	lt := &labTile{
+		size:      b.Size(),
+		pix:       make([]lab, w*h),
+		alpha:     make([]float64, w*h),
+		signature: make([]lab, signatureGrid*signatureGrid),
+	}
+	weights := make([]float64, signatureGrid*signatureGrid)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			o := rgba.PixOffset(b.Min.X+x, b.Min.Y+y)
+			a := rgba.Pix[o+3]
+			i := y*w + x
+			lt.alpha[i] = float64(a) / 255
+			if a > 0 {
+				// Undo the premultiplication of image.RGBA.
+				un := func(v uint8) uint8 { return uint8((int(v)*255 + int(a)/2) / int(a)) }
+				lt.pix[i] = toLab(un(rgba.Pix[o]), un(rgba.Pix[o+1]), un(rgba.Pix[o+2]))
+			}
+
+			s := (y*signatureGrid/h)*signatureGrid + x*signatureGrid/w
+			lt.signature[s].L += lt.pix[i].L * lt.alpha[i]
+			lt.signature[s].A += lt.pix[i].A * lt.alpha[i]
+			lt.signature[s].B += lt.pix[i].B * lt.alpha[i]
+			weights[s] += lt.alpha[i]
+		}
+	}
+	for s, wt := range weights {
+		if wt > 0 {
+			lt.signature[s].L /= wt
+			lt.signature[s].A /= wt
+			lt.signature[s].B /= wt
+		}
+	}
+	return lt
+}
+
+// tileDifference is the mean over pixels of the CIEDE2000 difference,
+// scaled by how opaque the less opaque pixel is, plus the alpha difference
+// on a 0-100 scale. Two fully transparent pixels do not differ.
+func tileDifference(a, b *labTile) float64 {
+	var sum float64
+	for i := range a.pix {
+		sum += ciede2000(a.pix[i], b.pix[i])*min(a.alpha[i], b.alpha[i]) +
+			100*math.Abs(a.alpha[i]-b.alpha[i])
+	}
+	return sum / float64(len(a.pix))
+}
+
+// signatureDifference is the mean CIEDE2000 difference between the cells
+// of two signatures.
+func signatureDifference(a, b *labTile) float64 {
+	var sum float64
+	for i := range a.signature {
+		sum += ciede2000(a.signature[i], b.signature[i])
+	}
+	return sum / float64(len(a.signature))
+}
@@ -0,0 +1,124 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCIEDE2000(t *testing.T) {
+	// The reference pairs of Sharma, Wu and Dalal, "The CIEDE2000
+	// color-difference formula" (2005), Table 1.
+	tests := []struct {
+		c1, c2 lab
+		want   float64
+	}{
+		{lab{50, 2.6772, -79.7751}, lab{50, 0, -82.7485}, 2.0425},
+		{lab{50, 3.1571, -77.2803}, lab{50, 0, -82.7485}, 2.8615},
+		{lab{50, 2.8361, -74.0200}, lab{50, 0, -82.7485}, 3.4412},
+		{lab{50, -1.3802, -84.2814}, lab{50, 0, -82.7485}, 1.0000},
+		{lab{50, -1.1848, -84.8006}, lab{50, 0, -82.7485}, 1.0000},
+		{lab{50, -0.9009, -85.5211}, lab{50, 0, -82.7485}, 1.0000},
+		{lab{50, 0, 0}, lab{50, -1, 2}, 2.3669},
+		{lab{50, -1, 2}, lab{50, 0, 0}, 2.3669},
+		{lab{50, 2.4900, -0.0010}, lab{50, -2.4900, 0.0009}, 7.1792},
+		{lab{50, 2.4900, -0.0010}, lab{50, -2.4900, 0.0010}, 7.1792},
+		{lab{50, 2.4900, -0.0010}, lab{50, -2.4900, 0.0011}, 7.2195},
+		{lab{50, 2.4900, -0.0010}, lab{50, -2.4900, 0.0012}, 7.2195},
+		{lab{50, -0.0010, 2.4900}, lab{50, 0.0009, -2.4900}, 4.8045},
+		{lab{50, -0.0010, 2.4900}, lab{50, 0.0010, -2.4900}, 4.8045},
+		{lab{50, -0.0010, 2.4900}, lab{50, 0.0011, -2.4900}, 4.7461},
+		{lab{50, 2.5, 0}, lab{50, 0, -2.5}, 4.3065},
+		{lab{50, 2.5, 0}, lab{73, 25, -18}, 27.1492},
+		{lab{50, 2.5, 0}, lab{61, -5, 29}, 22.8977},
+		{lab{50, 2.5, 0}, lab{56, -27, -3}, 31.9030},
+		{lab{50, 2.5, 0}, lab{58, 24, 15}, 19.4535},
+		{lab{50, 2.5, 0}, lab{50, 3.1736, 0.5854}, 1.0000},
+		{lab{50, 2.5, 0}, lab{50, 3.2972, 0}, 1.0000},
+		{lab{50, 2.5, 0}, lab{50, 1.8634, 0.5757}, 1.0000},
+		{lab{50, 2.5, 0}, lab{50, 3.2592, 0.3350}, 1.0000},
+		{lab{60.2574, -34.0099, 36.2677}, lab{60.4626, -34.1751, 39.4387}, 1.2644},
+		{lab{63.0109, -31.0961, -5.8663}, lab{62.8187, -29.7946, -4.0864}, 1.2630},
+		{lab{61.2901, 3.7196, -5.3901}, lab{61.4292, 2.2480, -4.9620}, 1.8731},
+		{lab{35.0831, -44.1164, 3.7933}, lab{35.0232, -40.0716, 1.5901}, 1.8645},
+		{lab{22.7233, 20.0904, -46.6940}, lab{23.0331, 14.9730, -42.5619}, 2.0373},
+		{lab{36.4612, 47.8580, 18.3852}, lab{36.2715, 50.5065, 21.2231}, 1.4146},
+		{lab{90.8027, -2.0831, 1.4410}, lab{91.1528, -1.6435, 0.0447}, 1.4441},
+		{lab{90.9257, -0.5406, -0.9208}, lab{88.6381, -0.8985, -0.7239}, 1.5381},
+		{lab{6.7747, -0.2908, -2.4247}, lab{5.8714, -0.0985, -2.2286}, 0.6377},
+		{lab{2.0776, 0.0795, -1.1350}, lab{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+	for i, tc := range tests {
+		got := ciede2000(tc.c1, tc.c2)
+		if math.Abs(got-tc.want) > 5e-5 {
+			t.Errorf("pair %d: ciede2000(%v, %v) = %.4f, want %.4f", i+1, tc.c1, tc.c2, got, tc.want)
+		}
+		if back := ciede2000(tc.c2, tc.c1); math.Abs(back-got) > 1e-9 {
+			t.Errorf("pair %d: difference is %.6f one way and %.6f the other", i+1, got, back)
+		}
+	}
+
+	if white := toLab(255, 255, 255); math.Abs(white.L-100) > 0.05 || math.Abs(white.A) > 0.05 || math.Abs(white.B) > 0.05 {
+		t.Errorf("white = %+v, want L* 100 without colour", white)
+	}
+}
+
+// noisyCopy returns img with every channel moved by up to amount levels,
+// as lossy compression does.
+func noisyCopy(img *image.RGBA, amount int, seed int64) *image.RGBA {
+	rng := rand.New(rand.NewSource(seed))
+	out := image.NewRGBA(img.Bounds())
+	for i, v := range img.Pix {
+		if i%4 == 3 {
+			out.Pix[i] = v
+			continue
+		}
+		out.Pix[i] = uint8(min(255, max(0, int(v)+rng.Intn(2*amount+1)-amount)))
+	}
+	return out
+}
+
+func TestGroupSimilarTiles(t *testing.T) {
+	tile := func(base color.RGBA) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				img.SetRGBA(x, y, color.RGBA{base.R + uint8(4*x), base.G + uint8(4*y), base.B, 255})
+			}
+		}
+		return img
+	}
+	grass := tile(color.RGBA{40, 140, 50, 255})
+	water := tile(color.RGBA{30, 60, 190, 255})
+	// A chest on grass differs in a small patch only.
+	chest := tile(color.RGBA{40, 140, 50, 255})
+	for y := 2; y < 5; y++ {
+		for x := 3; x < 6; x++ {
+			chest.SetRGBA(x, y, color.RGBA{150, 90, 20, 255})
+		}
+	}
+	noisy := noisyCopy(grass, 3, 1)
+	tiles := []image.Image{
+		noisy, noisyCopy(grass, 3, 2), grass,
+		water, noisyCopy(water, 3, 3),
+		chest,
+		noisyCopy(grass, 3, 4), noisy,
+	}
+	groups, reps := GroupSimilarTiles(tiles, 3)
+	if want := []int{0, 0, 0, 1, 1, 2, 0, 0}; !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+	// The clean grass tile is closest to the mean of its noisy copies; groups
+	// of two keep their first tile.
+	if want := []int{2, 3, 5}; !reflect.DeepEqual(reps, want) {
+		t.Errorf("representatives = %v, want %v", reps, want)
+	}
+
+	// At a tight threshold the compression noise is a difference.
+	if groups, _ := GroupSimilarTiles(tiles[:3], 0.1); groups[1] == 0 || groups[2] == 0 {
+		t.Errorf("groups at 0.1 = %v, want the noisy copies apart", groups)
+	}
+}
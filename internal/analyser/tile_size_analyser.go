@@ -10,13 +10,61 @@ import (
 )
 
 type TileSizeResult struct {
-	TileSize    int
+	TileWidth  int
+	TileHeight int
+	// OffsetX and OffsetY are where the tile grid starts, as found by
+	// FindGridOffset.
+	OffsetX int
+	OffsetY int
+	// Margin and Spacing are set for spritesheets found by
+	// DetectSpritesheet.
+	Margin      int
+	Spacing     int
 	TotalTiles  int
 	UniqueTiles int
-	ReuseRatio  float64
+	// CroppedPixels counts the image pixels outside every whole tile.
+	CroppedPixels int
+	ReuseRatio    float64
+	// Confidence is the DetectPeriods confidence of the grid, or zero when
+	// the size was not detected.
+	Confidence float64
+	// Orientation is maputils.Isometric for diamond tiles.
+	Orientation maputils.Orientation
 }
 
-func AnalyseTileSizes(imagePath string, candidateSizes []int) ([]TileSizeResult, error) {
+// Grid returns the tile grid described by the result.
+func (r TileSizeResult) Grid() maputils.Grid {
+	return maputils.Grid{
+		TileWidth:   r.TileWidth,
+		TileHeight:  r.TileHeight,
+		OffsetX:     r.OffsetX,
+		OffsetY:     r.OffsetY,
+		Margin:      r.Margin,
+		Spacing:     r.Spacing,
+		Orientation: r.Orientation,
+	}
+}
+
+// Size formats the tile dimensions as "W" for square tiles or "WxH".
+func (r TileSizeResult) Size() string {
+	if r.TileWidth == r.TileHeight {
+		return fmt.Sprint(r.TileWidth)
+	}
+	return fmt.Sprintf("%dx%d", r.TileWidth, r.TileHeight)
+}
+
+// SquareSizes returns square candidate tile sizes for the analysers.
+func SquareSizes(sizes ...int) []image.Point {
+	points := make([]image.Point, len(sizes))
+	for i, s := range sizes {
+		points[i] = image.Pt(s, s)
+	}
+	return points
+}
+
+// AnalyseTileSizes measures exact tile reuse for each candidate tile size,
+// given as width (X) by height (Y).
+func AnalyseTileSizes(imagePath string, candidateSizes []image.Point) ([]TileSizeResult, error) {
 	imgFile, err := os.Open(imagePath)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open image: %v", err)
@@ -29,11 +77,16 @@ func AnalyseTileSizes(imagePath string, candidateSizes []int) ([]TileSizeResult,
 	}
 
 	// Clean the image before analysis
-	img = PreprocessForTraining(img)
+	cleaned := PreprocessForTraining(img)
 
 	var results []TileSizeResult
 	for _, size := range candidateSizes {
-		tiles := maputils.SliceImageIntoTiles(img, size)
+		dx, dy := FindGridOffset(img, size.X, size.Y)
+		grid := maputils.Grid{TileWidth: size.X, TileHeight: size.Y, OffsetX: dx, OffsetY: dy}
+		tiles := maputils.SliceImageWithGrid(cleaned, grid)
+		if len(tiles) == 0 {
+			continue
+		}
 		hashes := maputils.HashTiles(tiles)
 		unique := maputils.DeduplicateTiles(hashes)
 
@@ -42,26 +95,34 @@ func AnalyseTileSizes(imagePath string, candidateSizes []int) ([]TileSizeResult,
 		ratio := float64(total-uniqueCount) / float64(total)
 
 		results = append(results, TileSizeResult{
-			TileSize:    size,
-			TotalTiles:  total,
-			UniqueTiles: uniqueCount,
-			ReuseRatio:  ratio,
+			TileWidth:     size.X,
+			TileHeight:    size.Y,
+			OffsetX:       dx,
+			OffsetY:       dy,
+			TotalTiles:    total,
+			UniqueTiles:   uniqueCount,
+			ReuseRatio:    ratio,
+			CroppedPixels: grid.Cropped(cleaned.Bounds()),
 		})
 	}
 
-	// Sort by TileSize ascending
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].TileSize < results[j].TileSize
+	// Sort by tile area ascending
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].TileWidth*results[i].TileHeight < results[j].TileWidth*results[j].TileHeight
 	})
 
 	return results, nil
 }
 
-func PickSuggestedTileSize(results []TileSizeResult, minReuse float64) (int, bool) {
+// PickSuggestedTileSize returns the first result with at least minReuse.
+//
+// Deprecated: the smallest sizes nearly always reuse well, so this favours
+// them. Use RankTileSizes.
+func PickSuggestedTileSize(results []TileSizeResult, minReuse float64) (TileSizeResult, bool) {
 	for _, result := range results {
 		if result.ReuseRatio >= minReuse {
-			return result.TileSize, true
+			return result, true
 		}
 	}
-	return 0, false
+	return TileSizeResult{}, false
 }
@@ -9,34 +9,139 @@ import (
 	"tilemap-generator/internal/maputils"
 )
 
-func AnalyseTileSizesFuzzy(imgPath string, sizes []int) ([]TileSizeResult, error) {
+// AnalyseTileSizesFuzzy measures tile reuse for each candidate tile size,
+// given as width (X) by height (Y), grouping near-identical tiles together.
+func AnalyseTileSizesFuzzy(imgPath string, sizes []image.Point) ([]TileSizeResult, error) {
+	srcImg, cleaned, err := loadForAnalysis(imgPath)
+	if err != nil {
+		return nil, err
+	}
+	return AnalyseImageTileSizesFuzzy(srcImg, cleaned, sizes), nil
+}
+
+// AnalyseImageTileSizesFuzzy is AnalyseTileSizesFuzzy for an image already
+// loaded, along with its PreprocessForTraining result.
+func AnalyseImageTileSizesFuzzy(srcImg, cleaned image.Image, sizes []image.Point) []TileSizeResult {
+	var results []TileSizeResult
+	for _, size := range sizes {
+		dx, dy := FindGridOffset(srcImg, size.X, size.Y)
+		grid := maputils.Grid{TileWidth: size.X, TileHeight: size.Y, OffsetX: dx, OffsetY: dy}
+		if r, ok := analyseGridFuzzy(cleaned, grid); ok {
+			results = append(results, r)
+		}
+	}
+
+	return results
+}
+
+// AnalysePeriodsFuzzy is AnalyseImageTileSizesFuzzy for the grids estimated
+// by DetectPeriods. Each hypothesis's offset is refined by RefineGridOffset
+// and its confidence is carried into the result.
+func AnalysePeriodsFuzzy(srcImg, cleaned image.Image, hypotheses []PeriodHypothesis) []TileSizeResult {
+	var results []TileSizeResult
+	for _, h := range hypotheses {
+		grid := h.Grid()
+		grid.OffsetX, grid.OffsetY = RefineGridOffset(srcImg, h.TileWidth, h.TileHeight, h.OffsetX, h.OffsetY)
+		if r, ok := analyseGridFuzzy(cleaned, grid); ok {
+			r.Confidence = h.Confidence
+			results = append(results, r)
+		}
+	}
+
+	return results
+}
+
+// AnalyseIsometricTileSizesFuzzy is AnalyseImageTileSizesFuzzy for
+// isometric grids: each size is the box around a diamond and its offset is
+// found by FindIsometricGridOffset. Sizes with an odd side are skipped.
+func AnalyseIsometricTileSizesFuzzy(srcImg, cleaned image.Image, sizes []image.Point) []TileSizeResult {
+	var results []TileSizeResult
+	for _, size := range sizes {
+		if size.X%2 != 0 || size.Y%2 != 0 {
+			continue
+		}
+		dx, dy := FindIsometricGridOffset(srcImg, size.X, size.Y)
+		grid := maputils.Grid{TileWidth: size.X, TileHeight: size.Y, OffsetX: dx, OffsetY: dy, Orientation: maputils.Isometric}
+		if r, ok := analyseGridFuzzy(cleaned, grid); ok {
+			results = append(results, r)
+		}
+	}
+
+	return results
+}
+
+// AnalyseIsometricPeriodsFuzzy is AnalyseIsometricTileSizesFuzzy for the
+// pitches found by DetectPeriods. An isometric map repeats every diamond
+// width across and every diamond height down, so each hypothesis's pitches
+// are taken as the diamond box; its offset is searched again and its
+// confidence carried into the result.
+func AnalyseIsometricPeriodsFuzzy(srcImg, cleaned image.Image, hypotheses []PeriodHypothesis) []TileSizeResult {
+	var results []TileSizeResult
+	for _, h := range hypotheses {
+		r := AnalyseIsometricTileSizesFuzzy(srcImg, cleaned, []image.Point{{X: h.TileWidth, Y: h.TileHeight}})
+		for i := range r {
+			r[i].Confidence = h.Confidence
+		}
+		results = append(results, r...)
+	}
+
+	return results
+}
+
+// AnalyseGridsFuzzy measures fuzzy reuse for grids that are already known,
+// such as a spritesheet layout found by DetectSpritesheet. Offsets are used
+// as given.
+func AnalyseGridsFuzzy(cleaned image.Image, grids []maputils.Grid) []TileSizeResult {
+	var results []TileSizeResult
+	for _, grid := range grids {
+		if r, ok := analyseGridFuzzy(cleaned, grid); ok {
+			results = append(results, r)
+		}
+	}
+
+	return results
+}
+
+// loadForAnalysis decodes the image at imgPath and returns it along with its
+// cleaned version. Grid offsets are searched on the original pixels, which
+// the cleaning would flatten.
+func loadForAnalysis(imgPath string) (image.Image, image.Image, error) {
 	file, err := os.Open(imgPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open image: %w", err)
+		return nil, nil, fmt.Errorf("failed to open image: %w", err)
 	}
 	defer file.Close()
 
 	srcImg, _, err := image.Decode(file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
 	// Clean the image before analysis
-	srcImg = PreprocessForTraining(srcImg)
-
-	var results []TileSizeResult
-	for _, size := range sizes {
-		tiles := maputils.SliceImageIntoTiles(srcImg, size)
-		_, unique := FuzzyMatchTiles(tiles, 5)
+	return srcImg, PreprocessForTraining(srcImg), nil
+}
 
-		reuseRatio := 1.0 - float64(unique)/float64(len(tiles))
-		results = append(results, TileSizeResult{
-			TileSize:    size,
-			TotalTiles:  len(tiles),
-			UniqueTiles: unique,
-			ReuseRatio:  reuseRatio,
-		})
+// analyseGridFuzzy slices cleaned with grid and measures fuzzy tile reuse.
+// It reports false when no whole tile fits.
+func analyseGridFuzzy(cleaned image.Image, grid maputils.Grid) (TileSizeResult, bool) {
+	tiles := maputils.SliceImageWithGrid(cleaned, grid)
+	if len(tiles) == 0 {
+		return TileSizeResult{}, false
 	}
+	_, unique := FuzzyMatchTiles(tiles, 5)
 
-	return results, nil
+	reuseRatio := 1.0 - float64(unique)/float64(len(tiles))
+	return TileSizeResult{
+		TileWidth:     grid.TileWidth,
+		TileHeight:    grid.TileHeight,
+		OffsetX:       grid.OffsetX,
+		OffsetY:       grid.OffsetY,
+		Margin:        grid.Margin,
+		Spacing:       grid.Spacing,
+		TotalTiles:    len(tiles),
+		UniqueTiles:   unique,
+		ReuseRatio:    reuseRatio,
+		CroppedPixels: grid.Cropped(cleaned.Bounds()),
+		Orientation:   grid.Orientation,
+	}, true
 }
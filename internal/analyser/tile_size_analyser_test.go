@@ -0,0 +1,66 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// rectMap paints a w x h map of tileWidth x tileHeight tiles starting at
+// (ox, oy), choosing one of four textured designs per tile.
+func rectMap(w, h, tileWidth, tileHeight, ox, oy int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			tx, ty := (x-ox+tileWidth)/tileWidth, (y-oy+tileHeight)/tileHeight
+			lx, ly := (x-ox+tileWidth)%tileWidth, (y-oy+tileHeight)%tileHeight
+			design := (tx*tx*7 + ty*3 + tx*ty) % 4
+			img.SetRGBA(x, y, color.RGBA{
+				uint8(60 * design),
+				uint8(lx * 255 / tileWidth),
+				uint8(ly * 255 / tileHeight),
+				255,
+			})
+		}
+	}
+	return img
+}
+
+func TestAnalyseRectangularTileSizes(t *testing.T) {
+	tests := []struct {
+		name                  string
+		tileWidth, tileHeight int
+		ox, oy                int
+	}{
+		{"16x24", 16, 24, 5, 3},
+		{"32x16", 32, 16, 9, 11},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img := rectMap(200, 150, tc.tileWidth, tc.tileHeight, tc.ox, tc.oy)
+			size := image.Pt(tc.tileWidth, tc.tileHeight)
+			results := AnalyseImageTileSizesFuzzy(img, img, []image.Point{size, {X: size.Y, Y: size.X}})
+			if len(results) != 2 {
+				t.Fatalf("%d results, want 2", len(results))
+			}
+			r := results[0]
+			if r.TileWidth != tc.tileWidth || r.TileHeight != tc.tileHeight {
+				t.Fatalf("first result is %dx%d, want %dx%d", r.TileWidth, r.TileHeight, tc.tileWidth, tc.tileHeight)
+			}
+			if r.OffsetX != tc.ox || r.OffsetY != tc.oy {
+				t.Errorf("offset %d,%d, want %d,%d", r.OffsetX, r.OffsetY, tc.ox, tc.oy)
+			}
+			cols, rows := (200-tc.ox)/tc.tileWidth, (150-tc.oy)/tc.tileHeight
+			lostX, lostY := 200-cols*tc.tileWidth, 150-rows*tc.tileHeight
+			if want := lostX*150 + lostY*200 - lostX*lostY; r.CroppedPixels != want {
+				t.Errorf("CroppedPixels = %d, want %d", r.CroppedPixels, want)
+			}
+			if r.TotalTiles != cols*rows {
+				t.Errorf("TotalTiles = %d, want %d", r.TotalTiles, cols*rows)
+			}
+			if swapped := results[1]; swapped.ReuseRatio >= r.ReuseRatio {
+				t.Errorf("transposed %s reuses %.2f, not less than %.2f", swapped.Size(), swapped.ReuseRatio, r.ReuseRatio)
+			}
+		})
+	}
+}
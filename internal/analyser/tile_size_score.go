@@ -0,0 +1,258 @@
+package analyser
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"tilemap-generator/internal/maputils"
+)
+
+// Weights of each metric in TileSizeScore.Score.
+const (
+	weightReuse          = 0.3
+	weightEdgeAlignment  = 0.3
+	weightCompactness    = 0.2
+	weightReconstruction = 0.2
+)
+
+const (
+	// reconstructionScale is the RMS pixel error, in 0-255 units, at which
+	// the reconstruction metric reaches zero.
+	reconstructionScale = 32.0
+	// confidenceGap is the score lead over the runner-up that counts as
+	// full confidence.
+	confidenceGap = 0.1
+)
+
+// TileSizeScore breaks down how well one analysed tile size fits the image.
+// Every metric is in [0, 1], higher being better.
+type TileSizeScore struct {
+	Result TileSizeResult
+	// Reuse is the result's reuse ratio.
+	Reuse float64
+	// EdgeAlignment is how much stronger colour edges are on the grid lines
+	// than on an average line: 0 when no stronger, 0.5 when twice as strong.
+	EdgeAlignment float64
+	// Compactness compares the size of the tileset plus its mapping with
+	// the raw image, so many unique tiles for the area scores low.
+	Compactness float64
+	// Reconstruction is how closely the map is rebuilt when every tile is
+	// replaced by the tile representing its group.
+	Reconstruction float64
+	// Score is the weighted sum of the metrics.
+	Score float64
+}
+
+// TileSizeRanking lists tile sizes from best to worst score.
+type TileSizeRanking struct {
+	Scores []TileSizeScore
+	// Confidence in [0, 1] is the best size's lead over the runner-up,
+	// reaching 1 at a lead of 0.1. It is 1 when there is a single size.
+	Confidence float64
+}
+
+// Best returns the best scoring size, or false if the ranking is empty.
+func (r TileSizeRanking) Best() (TileSizeResult, bool) {
+	if len(r.Scores) == 0 {
+		return TileSizeResult{}, false
+	}
+	return r.Scores[0].Result, true
+}
+
+// Level names the confidence as "high", "medium" or "low".
+func (r TileSizeRanking) Level() string {
+	switch {
+	case r.Confidence >= 2.0/3:
+		return "high"
+	case r.Confidence >= 1.0/3:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// RankTileSizes scores each analysed tile size on reuse, grid-line edge
+// alignment, compactness and reconstruction error and ranks them. Unlike
+// PickSuggestedTileSize it does not favour the smallest size: tiny tiles
+// reuse well but their extra grid lines fall inside real tiles, weakening
+// edge alignment, and their mapping costs more. original supplies pixels
+// and edges; cleaned is the preprocessed image used to group tiles, as in
+// training.
+func RankTileSizes(original, cleaned image.Image, results []TileSizeResult) TileSizeRanking {
+	b := original.Bounds()
+	luma := make([]int16, b.Dx()*b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := color.GrayModel.Convert(original.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			luma[y*b.Dx()+x] = int16(c.Y)
+		}
+	}
+	colEdges, rowEdges := edgeProfiles(luma, b.Dx(), b.Dy())
+
+	var ranking TileSizeRanking
+	for _, r := range results {
+		grid := r.Grid()
+		grid.Edge = maputils.EdgeDrop
+		alignment := edgeAlignment(colEdges, rowEdges, grid, b)
+		if grid.Isometric() {
+			alignment = isoEdgeAlignment(luma, grid, b)
+		}
+		s := TileSizeScore{
+			Result:         r,
+			Reuse:          r.ReuseRatio,
+			EdgeAlignment:  alignment,
+			Compactness:    compactness(r, b),
+			Reconstruction: reconstruction(original, cleaned, grid),
+		}
+		s.Score = weightReuse*s.Reuse +
+			weightEdgeAlignment*s.EdgeAlignment +
+			weightCompactness*s.Compactness +
+			weightReconstruction*s.Reconstruction
+		ranking.Scores = append(ranking.Scores, s)
+	}
+	sort.SliceStable(ranking.Scores, func(i, j int) bool {
+		return ranking.Scores[i].Score > ranking.Scores[j].Score
+	})
+
+	switch len(ranking.Scores) {
+	case 0:
+	case 1:
+		ranking.Confidence = 1
+	default:
+		gap := ranking.Scores[0].Score - ranking.Scores[1].Score
+		ranking.Confidence = math.Min(1, gap/confidenceGap)
+	}
+	return ranking
+}
+
+// edgeProfiles sums the luma change across each column boundary (between
+// column i-1 and i) and each row boundary.
+func edgeProfiles(luma []int16, w, h int) (cols, rows []float64) {
+	cols = make([]float64, w)
+	rows = make([]float64, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := luma[y*w+x]
+			if x > 0 {
+				cols[x] += math.Abs(float64(v - luma[y*w+x-1]))
+			}
+			if y > 0 {
+				rows[y] += math.Abs(float64(v - luma[(y-1)*w+x]))
+			}
+		}
+	}
+	return cols, rows
+}
+
+// edgeAlignment compares the mean edge strength on the grid's tile
+// boundaries with the mean over every boundary, averaged over both axes.
+func edgeAlignment(colEdges, rowEdges []float64, grid maputils.Grid, b image.Rectangle) float64 {
+	axis := func(edges []float64, start func(i int) int, count int) float64 {
+		var all, on float64
+		n := 0
+		for i := 1; i < len(edges); i++ {
+			all += edges[i]
+		}
+		for k := 0; k <= count; k++ {
+			if i := start(k); i > 0 && i < len(edges) {
+				on += edges[i]
+				n++
+			}
+		}
+		if n == 0 || on == 0 || len(edges) < 2 {
+			return 0
+		}
+		ratio := (all / float64(len(edges)-1)) / (on / float64(n))
+		return math.Max(0, 1-ratio)
+	}
+	cols, rows := grid.Dims(b)
+	x := axis(colEdges, func(k int) int { return grid.Cell(b, k, 0).Min.X - b.Min.X }, cols)
+	y := axis(rowEdges, func(k int) int { return grid.Cell(b, 0, k).Min.Y - b.Min.Y }, rows)
+	return (x + y) / 2
+}
+
+// isoEdgeAlignment is edgeAlignment for an isometric grid, whose tile
+// boundaries are diagonal: it compares the mean luma change between
+// neighbouring pixels in different diamonds with the mean over all
+// neighbouring pixels.
+func isoEdgeAlignment(luma []int16, grid maputils.Grid, b image.Rectangle) float64 {
+	w, h := b.Dx(), b.Dy()
+	cells := make([]image.Point, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cells[y*w+x] = grid.DiamondAt(b, b.Min.X+x, b.Min.Y+y)
+		}
+	}
+	var all, on float64
+	nAll, nOn := 0, 0
+	pair := func(i, j int) {
+		d := math.Abs(float64(luma[i] - luma[j]))
+		all += d
+		nAll++
+		if cells[i] != cells[j] {
+			on += d
+			nOn++
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x > 0 {
+				pair(y*w+x, y*w+x-1)
+			}
+			if y > 0 {
+				pair(y*w+x, (y-1)*w+x)
+			}
+		}
+	}
+	if nOn == 0 || on == 0 {
+		return 0
+	}
+	ratio := (all / float64(nAll)) / (on / float64(nOn))
+	return math.Max(0, 1-ratio)
+}
+
+// compactness is one minus the description length of the tileset (unique
+// tile pixels at 24 bits each) plus the mapping (log2 of the unique count
+// per cell), relative to the raw image at 24 bits per pixel.
+func compactness(r TileSizeResult, b image.Rectangle) float64 {
+	area := float64(b.Dx() * b.Dy())
+	if area == 0 || r.TotalTiles == 0 {
+		return 0
+	}
+	tileBits := float64(r.UniqueTiles*r.TileWidth*r.TileHeight) * 24
+	mapBits := float64(r.TotalTiles) * math.Log2(math.Max(2, float64(r.UniqueTiles)))
+	return math.Max(0, 1-(tileBits+mapBits)/(area*24))
+}
+
+// reconstruction groups the grid's tiles as training does and measures the
+// RMS error of rebuilding each tile from its group's first tile.
+func reconstruction(original, cleaned image.Image, grid maputils.Grid) float64 {
+	origTiles := maputils.SliceImageWithGrid(original, grid)
+	cleanTiles := maputils.SliceImageWithGrid(cleaned, grid)
+	if len(origTiles) == 0 || len(origTiles) != len(cleanTiles) {
+		return 0
+	}
+	groups, _ := FuzzyMatchTiles(cleanTiles, 5)
+
+	first := make(map[int]*image.RGBA)
+	var sum float64
+	n := 0
+	for i, t := range origTiles {
+		tile := t.(*image.RGBA)
+		rep, ok := first[groups[i]]
+		if !ok {
+			first[groups[i]] = tile
+			n += len(tile.Pix)
+			continue
+		}
+		for p := range tile.Pix {
+			d := float64(tile.Pix[p]) - float64(rep.Pix[p])
+			sum += d * d
+		}
+		n += len(tile.Pix)
+	}
+	rmse := math.Sqrt(sum / float64(n))
+	return math.Max(0, 1-rmse/reconstructionScale)
+}
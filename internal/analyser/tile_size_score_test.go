@@ -0,0 +1,49 @@
+package analyser
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestRankTileSizes(t *testing.T) {
+	img := scatterMap(240, 192, 24, 2)
+	sizes := []image.Point{{8, 8}, {12, 12}, {24, 24}, {48, 48}, {24, 12}}
+	ranking := RankTileSizes(img, img, AnalyseImageTileSizesFuzzy(img, img, sizes))
+	if len(ranking.Scores) != len(sizes) {
+		t.Fatalf("%d scores, want %d", len(ranking.Scores), len(sizes))
+	}
+	best, ok := ranking.Best()
+	if !ok || best.TileWidth != 24 || best.TileHeight != 24 {
+		t.Fatalf("best size %s, want 24x24", best.Size())
+	}
+	score := make(map[string]TileSizeScore)
+	for i, s := range ranking.Scores {
+		if i > 0 && s.Score > ranking.Scores[i-1].Score {
+			t.Errorf("%s scores %.3f, above %s before it", s.Result.Size(), s.Score, ranking.Scores[i-1].Result.Size())
+		}
+		score[s.Result.Size()] = s
+	}
+	// Smaller tiles reuse as well, but their extra grid lines cut through
+	// tiles; larger ones cannot rebuild the map.
+	for _, size := range []string{"12", "8"} {
+		if s := score[size]; s.EdgeAlignment >= score["24"].EdgeAlignment {
+			t.Errorf("%s edge alignment %.3f, not below 24px %.3f", size, s.EdgeAlignment, score["24"].EdgeAlignment)
+		}
+	}
+	if s := score["48"]; s.Reconstruction >= score["24"].Reconstruction || s.Compactness >= score["24"].Compactness {
+		t.Errorf("48px %+v rebuilds or compresses the map as well as 24px", s)
+	}
+	gap := ranking.Scores[0].Score - ranking.Scores[1].Score
+	if want := math.Min(1, gap/confidenceGap); ranking.Confidence != want {
+		t.Errorf("confidence %v, want %v", ranking.Confidence, want)
+	}
+
+	single := RankTileSizes(img, img, AnalyseImageTileSizesFuzzy(img, img, sizes[2:3]))
+	if single.Confidence != 1 || single.Level() != "high" {
+		t.Errorf("single size: confidence %v (%s), want 1 (high)", single.Confidence, single.Level())
+	}
+	if _, ok := RankTileSizes(img, img, nil).Best(); ok {
+		t.Error("empty ranking has a best size")
+	}
+}
@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"tilemap-generator/internal/tileutils"
+	"tilemap-generator/internal/wfc"
+)
+
+// maxAutoFrames is roughly how many frames a Recorder keeps when Every is 0.
+const maxAutoFrames = 120
+
+// Frame is a snapshot of an attempt in progress.
+type Frame struct {
+	// Mapping holds the decided tile of each cell, or -1.
+	Mapping [][]int
+	// Entropy holds the remaining entropy of each undecided cell, scaled so
+	// that the most uncertain cell of the first frame is 1.
+	Entropy [][]float64
+}
+
+// Recorder captures frames of the last attempt of a generation run. Set it
+// as Options.Record before generating.
+type Recorder struct {
+	// Every keeps one frame per Every solver steps; 0 picks a step that
+	// keeps about maxAutoFrames frames.
+	Every int
+
+	Frames []Frame
+
+	step     int
+	every    int
+	maxEntry float64
+}
+
+// reset starts a new attempt on a grid of the given number of cells.
+func (r *Recorder) reset(cells int) {
+	r.Frames = nil
+	r.step = 0
+	r.maxEntry = 0
+	r.every = r.Every
+	if r.every <= 0 {
+		r.every = cells/maxAutoFrames + 1
+	}
+}
+
+// hook returns a solver hook recording every r.every-th step.
+func (r *Recorder) hook(j job) func(*wfc.Solver) error {
+	return func(s *wfc.Solver) error {
+		if r.step%r.every == 0 {
+			r.capture(s, j)
+		}
+		r.step++
+		return nil
+	}
+}
+
+// capture appends the solver's current state as a frame.
+func (r *Recorder) capture(s *wfc.Solver, j job) {
+	mapping := j.decode(s.Result())
+	gw := s.Width
+	gh := s.Cells() / gw
+	if r.maxEntry == 0 {
+		for c := 0; c < s.Cells(); c++ {
+			if e := s.Entropy(c); e > r.maxEntry {
+				r.maxEntry = e
+			}
+		}
+		if r.maxEntry == 0 {
+			r.maxEntry = 1
+		}
+	}
+	entropy := make([][]float64, len(mapping))
+	for y := range entropy {
+		entropy[y] = make([]float64, len(mapping[y]))
+		for x := range entropy[y] {
+			// Models whose grid is smaller than the map, such as the
+			// overlapping model, share the entropy of the nearest cell.
+			cx, cy := min(x, gw-1), min(y, gh-1)
+			entropy[y][x] = s.Entropy(cy*gw+cx) / r.maxEntry
+		}
+	}
+	r.Frames = append(r.Frames, Frame{Mapping: mapping, Entropy: entropy})
+}
+
+// Render draws a frame: decided cells show their tile and undecided cells are
+// shaded from dark (nearly decided) to light (most uncertain).
+func (f Frame) Render(images map[int]image.Image, tileWidth, tileHeight int) *image.RGBA {
+	img := tileutils.DrawMapping(images, f.Mapping, tileWidth, tileHeight)
+	for y, row := range f.Mapping {
+		for x, id := range row {
+			if id >= 0 {
+				continue
+			}
+			e := f.Entropy[y][x]
+			if e > 1 {
+				e = 1
+			}
+			v := uint8(30 + 190*e)
+			shade := image.NewUniform(color.RGBA{v / 2, v / 2, v, 255})
+			r := image.Rect(x*tileWidth, y*tileHeight, (x+1)*tileWidth, (y+1)*tileHeight)
+			draw.Draw(img, r, shade, image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
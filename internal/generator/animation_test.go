@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := &Recorder{Every: 1}
+	result, err := Generate(terrainTileset(), Options{Width: 6, Height: 5, Seed: 3, Attempts: 5, Record: rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Frames) < 2 {
+		t.Fatalf("recorded %d frames, want the steps of the attempt", len(rec.Frames))
+	}
+	// The first frame precedes every observation and the last is the map.
+	first := rec.Frames[0]
+	for y, row := range first.Mapping {
+		for x, id := range row {
+			if id != -1 {
+				t.Fatalf("first frame has tile %d decided at (%d, %d)", id, x, y)
+			}
+			if e := first.Entropy[y][x]; e <= 0 || e > 1 {
+				t.Fatalf("first frame entropy at (%d, %d) = %v, want within (0, 1]", x, y, e)
+			}
+		}
+	}
+	if last := rec.Frames[len(rec.Frames)-1]; !reflect.DeepEqual(last.Mapping, result.Mapping) {
+		t.Errorf("last frame %v, want the generated map %v", last.Mapping, result.Mapping)
+	}
+	// A decided cell keeps its tile in every later frame.
+	for i := 1; i < len(rec.Frames); i++ {
+		for y, row := range rec.Frames[i-1].Mapping {
+			for x, id := range row {
+				if id >= 0 && rec.Frames[i].Mapping[y][x] != id {
+					t.Fatalf("frame %d changes decided tile %d at (%d, %d) to %d", i, id, x, y, rec.Frames[i].Mapping[y][x])
+				}
+			}
+		}
+	}
+
+	// Without Every the frame count stays near maxAutoFrames however large
+	// the map.
+	auto := &Recorder{}
+	if _, err := Generate(terrainTileset(), Options{Width: 30, Height: 30, Seed: 3, Attempts: 5, Record: auto}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(auto.Frames); n < 2 || n > maxAutoFrames+1 {
+		t.Errorf("recorded %d frames, want at most %d", n, maxAutoFrames+1)
+	}
+}
+
+func TestFrameRender(t *testing.T) {
+	tile := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range tile.Pix {
+		tile.Pix[i] = 200
+	}
+	f := Frame{
+		Mapping: [][]int{{7, -1, -1}},
+		Entropy: [][]float64{{0, 1, 0}},
+	}
+	img := f.Render(map[int]image.Image{7: tile}, 2, 2)
+	tests := []struct {
+		x    int
+		want color.RGBA
+	}{
+		{1, color.RGBA{200, 200, 200, 200}},
+		// The most uncertain cell is lightest, a nearly decided one dark.
+		{3, color.RGBA{110, 110, 220, 255}},
+		{5, color.RGBA{15, 15, 30, 255}},
+	}
+	for _, tc := range tests {
+		if got := img.RGBAAt(tc.x, 1); got != tc.want {
+			t.Errorf("pixel (%d, 1) = %v, want %v", tc.x, got, tc.want)
+		}
+	}
+}
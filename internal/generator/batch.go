@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Similarity compares two mappings. Both measures are in [0, 1] where 1 means
+// identical distributions, so maps of different sizes can be compared.
+type Similarity struct {
+	// Histogram is the overlap of the two tile frequency distributions.
+	Histogram float64 `json:"histogram"`
+	// Pattern is the overlap of the two distributions of 2x2 tile patterns.
+	Pattern float64 `json:"pattern"`
+	// Combined is the mean of Histogram and Pattern.
+	Combined float64 `json:"combined"`
+}
+
+// Compare measures how similar two mappings are.
+func Compare(a, b [][]int) Similarity {
+	return compareStats(newMapStats(a), newMapStats(b))
+}
+
+// mapStats holds the normalised tile and pattern distributions of a mapping.
+type mapStats struct {
+	tiles    map[int]float64
+	patterns map[[4]int]float64
+}
+
+func newMapStats(mapping [][]int) mapStats {
+	st := mapStats{tiles: make(map[int]float64), patterns: make(map[[4]int]float64)}
+	tiles, patterns := 0, 0
+	for y, row := range mapping {
+		for x, id := range row {
+			if id < 0 {
+				continue
+			}
+			st.tiles[id]++
+			tiles++
+			if y+1 < len(mapping) && x+1 < len(row) && x+1 < len(mapping[y+1]) {
+				p := [4]int{id, row[x+1], mapping[y+1][x], mapping[y+1][x+1]}
+				if p[1] >= 0 && p[2] >= 0 && p[3] >= 0 {
+					st.patterns[p]++
+					patterns++
+				}
+			}
+		}
+	}
+	for k := range st.tiles {
+		st.tiles[k] /= float64(tiles)
+	}
+	for k := range st.patterns {
+		st.patterns[k] /= float64(patterns)
+	}
+	return st
+}
+
+func compareStats(a, b mapStats) Similarity {
+	s := Similarity{
+		Histogram: overlap(a.tiles, b.tiles),
+		Pattern:   overlap(a.patterns, b.patterns),
+	}
+	s.Combined = (s.Histogram + s.Pattern) / 2
+	return s
+}
+
+// overlap returns the sum of the smaller share of every key, which is 1 minus
+// half the L1 distance between two normalised distributions.
+func overlap[K comparable](a, b map[K]float64) float64 {
+	sum := 0.0
+	for k, pa := range a {
+		if pb, ok := b[k]; ok {
+			sum += min(pa, pb)
+		}
+	}
+	return sum
+}
+
+// BatchEntry is one generated map of a batch.
+type BatchEntry struct {
+	Seed     int64   `json:"seed"`
+	Attempts int     `json:"attempts"`
+	Mapping  [][]int `json:"-"`
+	// File is where the map was saved; dropped maps are not saved.
+	File string `json:"file,omitempty"`
+	// Diversity is 1 minus the mean combined similarity to the other kept
+	// maps.
+	Diversity float64 `json:"diversity"`
+	// Source compares the map with the tileset's source mapping.
+	Source Similarity `json:"source"`
+	// DiversityRank and SourceRank order kept maps from 1 (most diverse,
+	// closest to the source). They are 0 for dropped maps.
+	DiversityRank int `json:"diversityRank,omitempty"`
+	SourceRank    int `json:"sourceRank,omitempty"`
+	// DuplicateOf is the seed of the kept map this one was too similar to.
+	DuplicateOf *int64 `json:"duplicateOf,omitempty"`
+	// DuplicateSimilarity is the combined similarity to that map.
+	DuplicateSimilarity float64 `json:"duplicateSimilarity,omitempty"`
+}
+
+// PairSimilarity compares two kept maps of a batch.
+type PairSimilarity struct {
+	A, B int64 `json:"-"`
+	Similarity
+}
+
+// MarshalJSON writes the pair with its seeds.
+func (p PairSimilarity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		A int64 `json:"a"`
+		B int64 `json:"b"`
+		Similarity
+	}{p.A, p.B, p.Similarity})
+}
+
+// BatchReport summarises a batch of generated maps.
+type BatchReport struct {
+	Threshold float64          `json:"threshold"`
+	Kept      int              `json:"kept"`
+	Dropped   int              `json:"dropped"`
+	Entries   []BatchEntry     `json:"entries"`
+	Pairs     []PairSimilarity `json:"pairs"`
+}
+
+// ScoreBatch compares every map of a batch with the others and with source.
+// Maps are taken in order and a map whose combined similarity to an already
+// kept map exceeds threshold is dropped as a near-duplicate. Kept maps are
+// then ranked by diversity and by closeness to the source statistics.
+func ScoreBatch(source [][]int, entries []BatchEntry, threshold float64) *BatchReport {
+	report := &BatchReport{Threshold: threshold, Entries: entries}
+	sourceStats := newMapStats(source)
+	stats := make([]mapStats, len(entries))
+	var kept []int
+	for i := range entries {
+		stats[i] = newMapStats(entries[i].Mapping)
+		entries[i].Source = compareStats(stats[i], sourceStats)
+		for _, k := range kept {
+			if s := compareStats(stats[i], stats[k]); s.Combined > threshold {
+				seed := entries[k].Seed
+				entries[i].DuplicateOf = &seed
+				entries[i].DuplicateSimilarity = s.Combined
+				break
+			}
+		}
+		if entries[i].DuplicateOf == nil {
+			kept = append(kept, i)
+		}
+	}
+	report.Kept = len(kept)
+	report.Dropped = len(entries) - len(kept)
+
+	total := make([]float64, len(entries))
+	for a := 0; a < len(kept); a++ {
+		for b := a + 1; b < len(kept); b++ {
+			i, j := kept[a], kept[b]
+			s := compareStats(stats[i], stats[j])
+			report.Pairs = append(report.Pairs, PairSimilarity{A: entries[i].Seed, B: entries[j].Seed, Similarity: s})
+			total[i] += s.Combined
+			total[j] += s.Combined
+		}
+	}
+	for _, i := range kept {
+		entries[i].Diversity = 1
+		if len(kept) > 1 {
+			entries[i].Diversity = 1 - total[i]/float64(len(kept)-1)
+		}
+	}
+
+	rank := func(better func(a, b int) bool, set func(i, r int)) {
+		order := append([]int{}, kept...)
+		sort.SliceStable(order, func(a, b int) bool { return better(order[a], order[b]) })
+		for r, i := range order {
+			set(i, r+1)
+		}
+	}
+	rank(func(a, b int) bool { return entries[a].Diversity > entries[b].Diversity },
+		func(i, r int) { entries[i].DiversityRank = r })
+	rank(func(a, b int) bool { return entries[a].Source.Combined > entries[b].Source.Combined },
+		func(i, r int) { entries[i].SourceRank = r })
+	return report
+}
+
+// SaveJSON writes the report to path.
+func (r *BatchReport) SaveJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// SaveCSV writes one row per map to path.
+func (r *BatchReport) SaveCSV(path string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"seed", "file", "kept", "diversity_rank", "source_rank", "diversity",
+		"source_histogram", "source_pattern", "source_combined", "duplicate_of", "duplicate_similarity"})
+	ff := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, e := range r.Entries {
+		dup, dupSim := "", ""
+		if e.DuplicateOf != nil {
+			dup = strconv.FormatInt(*e.DuplicateOf, 10)
+			dupSim = ff(e.DuplicateSimilarity)
+		}
+		w.Write([]string{
+			strconv.FormatInt(e.Seed, 10), e.File, strconv.FormatBool(e.DuplicateOf == nil),
+			strconv.Itoa(e.DiversityRank), strconv.Itoa(e.SourceRank), ff(e.Diversity),
+			ff(e.Source.Histogram), ff(e.Source.Pattern), ff(e.Source.Combined), dup, dupSim,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write batch summary: %w", err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
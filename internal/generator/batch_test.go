@@ -0,0 +1,107 @@
+package generator
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	ones := [][]int{{1, 1}, {1, 1}}
+	tests := []struct {
+		name string
+		a, b [][]int
+		want Similarity
+	}{
+		{"identical", ones, ones, Similarity{1, 1, 1}},
+		// Shares are compared, so size does not matter.
+		{"larger", ones, [][]int{{1, 1, 1, 1}, {1, 1, 1, 1}, {1, 1, 1, 1}}, Similarity{1, 1, 1}},
+		{"disjoint", ones, [][]int{{2, 2}, {2, 2}}, Similarity{0, 0, 0}},
+		// Half the tiles agree but no 2x2 pattern does.
+		{"half", ones, [][]int{{1, 2}, {1, 2}}, Similarity{0.5, 0, 0.25}},
+		// Empty cells and the patterns touching them are skipped.
+		{"empty cells", ones, [][]int{{1, 1, -1}, {1, 1, 2}}, Similarity{0.8, 1, 0.9}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Compare(tc.a, tc.b); got != tc.want {
+				t.Errorf("Compare = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScoreBatch(t *testing.T) {
+	ones := [][]int{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}}
+	twos := [][]int{{2, 2, 2}, {2, 2, 2}, {2, 2, 2}}
+	stripes := [][]int{{1, 2}, {1, 2}}
+	entries := []BatchEntry{
+		{Seed: 10, Mapping: ones},
+		{Seed: 11, Mapping: [][]int{{1, 1}, {1, 1}}},
+		{Seed: 12, Mapping: twos},
+		{Seed: 13, Mapping: stripes},
+	}
+	report := ScoreBatch(stripes, entries, 0.9)
+	if report.Kept != 3 || report.Dropped != 1 {
+		t.Fatalf("kept %d and dropped %d maps, want 3 and 1", report.Kept, report.Dropped)
+	}
+	if dup := entries[1]; dup.DuplicateOf == nil || *dup.DuplicateOf != 10 || dup.DuplicateSimilarity != 1 {
+		t.Errorf("seed 11 = %+v, want a duplicate of seed 10", dup)
+	}
+	if len(report.Pairs) != 3 || report.Pairs[1].A != 10 || report.Pairs[1].B != 13 || report.Pairs[1].Combined != 0.25 {
+		t.Errorf("pairs = %+v, want the kept maps compared pairwise", report.Pairs)
+	}
+
+	// The uniform maps share half their tiles with the stripes and nothing
+	// with each other; the stripes share half with both. Dropped maps are
+	// still compared with the source but not ranked.
+	tests := []struct {
+		i          int
+		diversity  float64
+		source     float64
+		divRank    int
+		sourceRank int
+	}{
+		{0, 0.875, 0.25, 1, 2},
+		{1, 0, 0.25, 0, 0},
+		{2, 0.875, 0.25, 2, 3},
+		{3, 0.75, 1, 3, 1},
+	}
+	for _, tc := range tests {
+		e := entries[tc.i]
+		if e.Diversity != tc.diversity || e.Source.Combined != tc.source || e.DiversityRank != tc.divRank || e.SourceRank != tc.sourceRank {
+			t.Errorf("seed %d: diversity %v rank %d, source %v rank %d; want %v rank %d, %v rank %d",
+				e.Seed, e.Diversity, e.DiversityRank, e.Source.Combined, e.SourceRank,
+				tc.diversity, tc.divRank, tc.source, tc.sourceRank)
+		}
+	}
+}
+
+func TestBatchReportSaveCSV(t *testing.T) {
+	dup := int64(1)
+	report := &BatchReport{Entries: []BatchEntry{
+		{Seed: 1, File: "map_1.json", DiversityRank: 1, SourceRank: 1, Diversity: 0.5},
+		{Seed: 2, DuplicateOf: &dup, DuplicateSimilarity: 0.95},
+	}}
+	path := filepath.Join(t.TempDir(), "batch.csv")
+	if err := report.SaveCSV(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][1] != "map_1.json" || rows[1][2] != "true" || rows[2][2] != "false" || rows[2][9] != "1" || rows[2][10] != "0.9500" {
+		t.Errorf("rows = %q", rows)
+	}
+
+	if err := report.SaveCSV(filepath.Join(t.TempDir(), "missing", "batch.csv")); err == nil {
+		t.Error("SaveCSV into a missing directory succeeded")
+	}
+}
@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"fmt"
+	"image"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// ChunkOptions select one chunk of an infinite world.
+type ChunkOptions struct {
+	WorldSeed int64
+	CX, CY    int
+	Size      int
+	Attempts  int
+	Diagnose  bool
+}
+
+// GenerateChunk generates chunk (CX, CY) of Size x Size tiles. Any chunk can
+// be generated on its own and still line up with its neighbours.
+//
+// The world is cut along seams: horizontal seam (x, y) is the top row of
+// chunk (x, y) and vertical seam (x, y) its left column, each Size+1 tiles
+// long so it also holds the corners at both ends. Every seam is derived from
+// the world seed and its own coordinates only, so the two chunks sharing it
+// derive the same tiles while different seams differ:
+//
+//   - A periodic Size x Size frame is solved from the world seed. Repeated
+//     across the world it forms a lattice in which every chunk is the frame
+//     and all seams are the same.
+//   - A horizontal seam is solved within a band reaching Size/2 rows into
+//     the chunks above and below it. The band's outline is pinned to the
+//     lattice, so it joins the lattice around it whatever it holds inside.
+//   - A vertical seam is solved the same way, with the horizontal bands it
+//     crosses at its ends pinned as well.
+//
+// The chunk is then solved on a (Size+1) x (Size+1) grid pinned to its four
+// seams; the extra row and column are the first row and column of the chunks
+// below and to the right. Every one of these grids has a solution, the
+// lattice patched with the bands, but the solver may still not find one in
+// its attempts, in which case a *FailureError is returned.
+func GenerateChunk(meta *maputils.TilesetMetadata, opts ChunkOptions) (*Result, error) {
+	if opts.Size < 2 {
+		return nil, fmt.Errorf("chunk size must be at least 2, got %d", opts.Size)
+	}
+	w, err := newChunkWorld(meta, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cx, cy := opts.CX, opts.CY
+	var bands [4][][]int
+	for i, band := range []struct {
+		vertical bool
+		x, y     int
+	}{{false, cx, cy}, {false, cx, cy + 1}, {true, cx, cy}, {true, cx + 1, cy}} {
+		if band.vertical {
+			bands[i], err = w.vBand(band.x, band.y)
+		} else {
+			bands[i], err = w.hBand(band.x, band.y)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	top, bottom, left, right := bands[0], bands[1], bands[2], bands[3]
+
+	n := opts.Size + 1
+	solver := wfc.NewSolver(w.model, n, n, false)
+	for i := 0; i < n; i++ {
+		solver.Restrict(i, 0, []int{top[w.depth][i]})
+		solver.Restrict(i, opts.Size, []int{bottom[w.depth][i]})
+		solver.Restrict(0, i, []int{left[i][w.depth]})
+		solver.Restrict(opts.Size, i, []int{right[i][w.depth]})
+	}
+	result, err := w.solve(solver, deriveSeed(opts.WorldSeed, 'c', cx, cy))
+	if err != nil {
+		return nil, fmt.Errorf("chunk (%d, %d) cannot be filled between its seams: %w", cx, cy, err)
+	}
+
+	mapping := result.Mapping[:opts.Size]
+	for y := range mapping {
+		mapping[y] = mapping[y][:opts.Size]
+	}
+	return &Result{Mapping: mapping, Seed: opts.WorldSeed, Attempts: result.Attempts}, nil
+}
+
+// chunkWorld derives the seam bands of one world for GenerateChunk. Bands
+// are depth = Size/2 tiles deep on either side of their seam, so the bands
+// on opposite sides of a chunk meet at most on a lattice row or column.
+type chunkWorld struct {
+	model  *wfc.Model
+	opts   ChunkOptions
+	depth  int
+	job    job
+	frame  [][]int
+	hBands map[image.Point][][]int
+}
+
+// newChunkWorld solves the frame of the world selected by opts.
+func newChunkWorld(meta *maputils.TilesetMetadata, opts ChunkOptions) (*chunkWorld, error) {
+	if err := checkOrthogonal(meta); err != nil {
+		return nil, err
+	}
+	model, ids, err := SimpleModel(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &chunkWorld{
+		model: model,
+		opts:  opts,
+		depth: opts.Size / 2,
+		job: job{
+			tileOf: func(s int) int { return ids[s] },
+			decode: func(states [][]int) [][]int { return statesToIDs(states, ids) },
+		},
+		hBands: make(map[image.Point][][]int),
+	}
+	frame := wfc.NewSolver(model, opts.Size, opts.Size, true)
+	if _, err := w.solve(frame, deriveSeed(opts.WorldSeed, 'f', 0, 0)); err != nil {
+		return nil, fmt.Errorf("world frame cannot be generated: %w", err)
+	}
+	w.frame = frame.Result()
+	return w, nil
+}
+
+// solve runs the chunk attempts on solver from seed.
+func (w *chunkWorld) solve(solver *wfc.Solver, seed int64) (*Result, error) {
+	return solve(solver, Options{Seed: seed, Attempts: w.opts.Attempts, Diagnose: w.opts.Diagnose}, w.job)
+}
+
+// lattice returns the state of the frame tile repeated at world cell (x, y).
+func (w *chunkWorld) lattice(x, y int) int {
+	size := w.opts.Size
+	return w.frame[(y%size+size)%size][(x%size+size)%size]
+}
+
+// hBand returns the states of the band around horizontal seam (x, y). Row
+// depth of the band is the seam.
+func (w *chunkWorld) hBand(x, y int) ([][]int, error) {
+	if band, ok := w.hBands[image.Pt(x, y)]; ok {
+		return band, nil
+	}
+	size, d := w.opts.Size, w.depth
+	x0, y0 := x*size, y*size-d
+	solver := wfc.NewSolver(w.model, size+1, 2*d+1, false)
+	for i := 0; i <= size; i++ {
+		solver.Restrict(i, 0, []int{w.lattice(x0+i, y0)})
+		solver.Restrict(i, 2*d, []int{w.lattice(x0+i, y0+2*d)})
+	}
+	for j := 0; j <= 2*d; j++ {
+		solver.Restrict(0, j, []int{w.lattice(x0, y0+j)})
+		solver.Restrict(size, j, []int{w.lattice(x0+size, y0+j)})
+	}
+	if _, err := w.solve(solver, deriveSeed(w.opts.WorldSeed, 'h', x, y)); err != nil {
+		return nil, fmt.Errorf("seam above chunk (%d, %d) cannot be generated: %w", x, y, err)
+	}
+	band := solver.Result()
+	w.hBands[image.Pt(x, y)] = band
+	return band, nil
+}
+
+// vBand returns the states of the band around vertical seam (x, y). Column
+// depth of the band is the seam. Its ends are pinned to the horizontal
+// bands above and below chunks (x-1, y) and (x, y).
+func (w *chunkWorld) vBand(x, y int) ([][]int, error) {
+	size, d := w.opts.Size, w.depth
+	x0, y0 := x*size-d, y*size
+	solver := wfc.NewSolver(w.model, 2*d+1, size+1, false)
+	for j := d + 1; j < size-d; j++ {
+		solver.Restrict(0, j, []int{w.lattice(x0, y0+j)})
+		solver.Restrict(2*d, j, []int{w.lattice(x0+2*d, y0+j)})
+	}
+	for _, by := range []int{y, y + 1} {
+		for _, bx := range []int{x - 1, x} {
+			band, err := w.hBand(bx, by)
+			if err != nil {
+				return nil, err
+			}
+			// Copy the part of the band that overlaps this one.
+			for j, row := range band {
+				for i, s := range row {
+					if gx, gy := bx*size+i-x0, by*size-d+j-y0; gx >= 0 && gx <= 2*d && gy >= 0 && gy <= size {
+						solver.Restrict(gx, gy, []int{s})
+					}
+				}
+			}
+		}
+	}
+	if _, err := w.solve(solver, deriveSeed(w.opts.WorldSeed, 'v', x, y)); err != nil {
+		return nil, fmt.Errorf("seam left of chunk (%d, %d) cannot be generated: %w", x, y, err)
+	}
+	return solver.Result(), nil
+}
+
+// deriveSeed mixes the world seed with a tag and a pair of coordinates using
+// the SplitMix64 finaliser so nearby coordinates get unrelated seeds.
+func deriveSeed(seed int64, tag byte, a, b int) int64 {
+	h := uint64(seed)
+	for _, v := range []uint64{uint64(tag), uint64(int64(a)), uint64(int64(b))} {
+		h += v + 0x9e3779b97f4a7c15
+		h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+		h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+		h ^= h >> 31
+	}
+	return int64(h)
+}
@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+)
+
+func TestChunkSeams(t *testing.T) {
+	const size = 8
+	meta := terrainTileset()
+	opts := ChunkOptions{WorldSeed: 3, Size: size, Attempts: 50}
+	chunk := func(cx, cy int) [][]int {
+		t.Helper()
+		o := opts
+		o.CX, o.CY = cx, cy
+		result, err := GenerateChunk(meta, o)
+		if err != nil {
+			t.Fatalf("chunk (%d, %d): %v", cx, cy, err)
+		}
+		return result.Mapping
+	}
+
+	// Chunks generated one at a time join into a valid 3x3 world.
+	world := make([][]int, 3*size)
+	for i := range world {
+		world[i] = make([]int, 3*size)
+	}
+	for cy := 0; cy < 3; cy++ {
+		for cx := 0; cx < 3; cx++ {
+			for y, row := range chunk(cx, cy) {
+				copy(world[cy*size+y][cx*size:], row)
+			}
+		}
+	}
+	checkTerrain(t, world)
+
+	// Both chunks on a seam derive the same tiles for it: the first column
+	// of chunk (1, 0) is the seam chunk (0, 0) was completed against.
+	w, err := newChunkWorld(meta, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	band, err := w.vBand(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seam := w.job.decode(band)
+	for y, row := range chunk(1, 0) {
+		if row[0] != seam[y][w.depth] {
+			t.Fatalf("row %d: chunk (1, 0) starts with %d, shared seam holds %d", y, row[0], seam[y][w.depth])
+		}
+	}
+
+	// Seams that do not touch differ.
+	tops := map[string]bool{}
+	for cy := 0; cy < 4; cy++ {
+		for cx := 0; cx < 4; cx++ {
+			tops[fmt.Sprint(chunk(cx, cy)[0])] = true
+		}
+	}
+	if len(tops) < 8 {
+		t.Errorf("16 chunks have %d distinct top seams", len(tops))
+	}
+	if top := chunk(0, 0)[0]; reflect.DeepEqual(top, chunk(5, 3)[0]) {
+		t.Errorf("chunks (0, 0) and (5, 3) share top seam %v", top)
+	}
+}
+
+func TestChunkFailure(t *testing.T) {
+	// Two tiles that must alternate cannot wrap around an odd frame.
+	meta := &maputils.TilesetMetadata{TileSize: 16}
+	for _, id := range []int{1, 2} {
+		other := []string{tileHash(3 - id)}
+		meta.Tiles = append(meta.Tiles, maputils.TilesetEntry{
+			ID:        id,
+			Hash:      tileHash(id),
+			Adjacency: maputils.Adjacency{Top: other, Bottom: other, Left: other, Right: other},
+		})
+	}
+	_, err := GenerateChunk(meta, ChunkOptions{WorldSeed: 1, Size: 3, Attempts: 3})
+	var fail *FailureError
+	if !errors.As(err, &fail) {
+		t.Fatalf("GenerateChunk = %v, want a failure", err)
+	}
+	if _, err := GenerateChunk(meta, ChunkOptions{WorldSeed: 1, Size: 4, Attempts: 3}); err != nil {
+		t.Errorf("even chunk: %v", err)
+	}
+}
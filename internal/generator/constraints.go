@@ -0,0 +1,481 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// Border sides understood by BorderConstraint.
+var borderSides = []string{"top", "bottom", "left", "right"}
+
+// TileSelector picks tiles either by ID or by class name. Classes are looked
+// up in the constraint spec first and then in the tileset.
+type TileSelector struct {
+	Tiles []int  `json:"tiles,omitempty" yaml:"tiles,omitempty"`
+	Class string `json:"class,omitempty" yaml:"class,omitempty"`
+}
+
+// CountConstraint bounds how many cells may hold the selected tiles. Max is
+// optional.
+type CountConstraint struct {
+	TileSelector `yaml:",inline"`
+	Min          int  `json:"min,omitempty" yaml:"min,omitempty"`
+	Max          *int `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// BorderConstraint limits the outermost cells on the given sides (all four
+// when empty) to the selected tiles.
+type BorderConstraint struct {
+	TileSelector `yaml:",inline"`
+	Sides        []string `json:"sides,omitempty" yaml:"sides,omitempty"`
+}
+
+// Constraints is a spec of global rules a generated map must satisfy on top
+// of local adjacency.
+type Constraints struct {
+	Classes   map[string][]int   `json:"classes,omitempty" yaml:"classes,omitempty"`
+	Counts    []CountConstraint  `json:"counts,omitempty" yaml:"counts,omitempty"`
+	Required  []int              `json:"required,omitempty" yaml:"required,omitempty"`
+	Border    []BorderConstraint `json:"border,omitempty" yaml:"border,omitempty"`
+	Connected []string           `json:"connected,omitempty" yaml:"connected,omitempty"`
+}
+
+// ConstraintResult reports whether one constraint held in a map.
+type ConstraintResult struct {
+	Name      string `json:"name"`
+	Satisfied bool   `json:"satisfied"`
+	Detail    string `json:"detail"`
+}
+
+// ConstraintError is returned when no attempt produced a map satisfying
+// every constraint. Report describes the last rejected map.
+type ConstraintError struct {
+	Report []ConstraintResult
+}
+
+func (e *ConstraintError) Error() string {
+	var failed []string
+	for _, r := range e.Report {
+		if !r.Satisfied {
+			failed = append(failed, r.Name)
+		}
+	}
+	return fmt.Sprintf("map violates constraints: %s", strings.Join(failed, ", "))
+}
+
+// LoadConstraints reads a constraint spec, as YAML when the file ends in
+// .yaml or .yml and as JSON otherwise.
+func LoadConstraints(path string) (*Constraints, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+	var c Constraints
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &c)
+	default:
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode constraints: %w", err)
+	}
+	return &c, nil
+}
+
+// countRule is a CountConstraint resolved to solver states.
+type countRule struct {
+	name   string
+	states []bool
+	tiles  map[int]bool
+	min    int
+	max    int
+}
+
+// constraintSet is a Constraints spec resolved against a model.
+type constraintSet struct {
+	counts    []countRule
+	required  []int
+	border    map[string][]int
+	connected map[string]map[int]bool
+	stateByID map[int]int
+	width     int
+	height    int
+}
+
+// compile resolves the spec against a tileset whose states map to ids.
+func (c *Constraints) compile(meta *maputils.TilesetMetadata, ids []int, width, height int) (*constraintSet, error) {
+	stateByID := make(map[int]int, len(ids))
+	for s, id := range ids {
+		stateByID[id] = s
+	}
+	resolve := func(sel TileSelector) ([]int, error) {
+		tiles := append([]int{}, sel.Tiles...)
+		if sel.Class != "" {
+			class, err := c.class(meta, sel.Class)
+			if err != nil {
+				return nil, err
+			}
+			tiles = append(tiles, class...)
+		}
+		if len(tiles) == 0 {
+			return nil, fmt.Errorf("constraint selects no tiles")
+		}
+		for _, id := range tiles {
+			if _, ok := stateByID[id]; !ok {
+				return nil, fmt.Errorf("constraint uses unknown tile ID %d", id)
+			}
+		}
+		return tiles, nil
+	}
+
+	cs := &constraintSet{
+		border:    make(map[string][]int),
+		connected: make(map[string]map[int]bool),
+		stateByID: stateByID,
+		width:     width,
+		height:    height,
+	}
+	for _, cc := range c.Counts {
+		tiles, err := resolve(cc.TileSelector)
+		if err != nil {
+			return nil, err
+		}
+		rule := countRule{
+			name:   "count " + selectorName(cc.TileSelector),
+			states: make([]bool, len(ids)),
+			tiles:  make(map[int]bool),
+			min:    cc.Min,
+			max:    -1,
+		}
+		if cc.Max != nil {
+			rule.max = *cc.Max
+			if rule.max < rule.min {
+				return nil, fmt.Errorf("%s: max %d is below min %d", rule.name, rule.max, rule.min)
+			}
+		}
+		for _, id := range tiles {
+			rule.states[stateByID[id]] = true
+			rule.tiles[id] = true
+		}
+		cs.counts = append(cs.counts, rule)
+	}
+
+	for _, id := range c.Required {
+		if _, ok := stateByID[id]; !ok {
+			return nil, fmt.Errorf("required tile %d is not in the tileset", id)
+		}
+		cs.required = append(cs.required, id)
+	}
+
+	// Rules naming the same side combine: the side keeps only the tiles
+	// every one of them allows.
+	selectors := make(map[string][]string)
+	for _, bc := range c.Border {
+		tiles, err := resolve(bc.TileSelector)
+		if err != nil {
+			return nil, err
+		}
+		sides := bc.Sides
+		if len(sides) == 0 {
+			sides = borderSides
+		}
+		for _, side := range sides {
+			if !contains(borderSides, side) {
+				return nil, fmt.Errorf("unknown border side %q", side)
+			}
+			allowed := tiles
+			if prev, ok := cs.border[side]; ok {
+				allowed = intersect(prev, allowed)
+			}
+			selectors[side] = append(selectors[side], selectorName(bc.TileSelector))
+			if len(allowed) == 0 {
+				return nil, fmt.Errorf("border %s: no tile is allowed by all of %s", side, strings.Join(selectors[side], "; "))
+			}
+			cs.border[side] = allowed
+		}
+	}
+
+	for _, name := range c.Connected {
+		tiles, err := c.class(meta, name)
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[int]bool)
+		for _, id := range tiles {
+			set[id] = true
+		}
+		cs.connected[name] = set
+	}
+	return cs, nil
+}
+
+// class looks up a tile class in the spec, then in the tileset.
+func (c *Constraints) class(meta *maputils.TilesetMetadata, name string) ([]int, error) {
+	if tiles, ok := c.Classes[name]; ok {
+		return tiles, nil
+	}
+	if tiles, ok := meta.Classes[name]; ok {
+		return tiles, nil
+	}
+	return nil, fmt.Errorf("unknown tile class %q", name)
+}
+
+// restrict applies the border constraints to the solver.
+func (cs *constraintSet) restrict(solver *wfc.Solver) {
+	for side, tiles := range cs.border {
+		states := make([]int, len(tiles))
+		for i, id := range tiles {
+			states[i] = cs.stateByID[id]
+		}
+		switch side {
+		case "top", "bottom":
+			y := 0
+			if side == "bottom" {
+				y = cs.height - 1
+			}
+			for x := 0; x < cs.width; x++ {
+				restrictMore(solver, x, y, states)
+			}
+		case "left", "right":
+			x := 0
+			if side == "right" {
+				x = cs.width - 1
+			}
+			for y := 0; y < cs.height; y++ {
+				restrictMore(solver, x, y, states)
+			}
+		}
+	}
+}
+
+// restrictMore narrows the existing restriction of a cell.
+func restrictMore(solver *wfc.Solver, x, y int, states []int) {
+	if prev := solver.Restriction(x, y); prev != nil {
+		states = intersect(prev, states)
+	}
+	solver.Restrict(x, y, states)
+}
+
+// hook enforces maximum counts while solving: once a count is reached the
+// selected states are banned from every undecided cell.
+func (cs *constraintSet) hook(s *wfc.Solver) error {
+	for _, rule := range cs.counts {
+		if rule.max < 0 {
+			continue
+		}
+		decided := 0
+		for i := 0; i < s.Cells(); i++ {
+			if st := s.State(i); st >= 0 && rule.states[st] {
+				decided++
+			}
+		}
+		if decided > rule.max {
+			return fmt.Errorf("%s exceeded max %d", rule.name, rule.max)
+		}
+		if decided < rule.max {
+			continue
+		}
+		for i := 0; i < s.Cells(); i++ {
+			if s.State(i) >= 0 {
+				continue
+			}
+			for st, ok := range rule.states {
+				if ok && s.Allowed(i, st) {
+					s.Ban(i, st)
+				}
+			}
+		}
+		if !s.Propagate() {
+			return s.Err()
+		}
+	}
+	return nil
+}
+
+// check evaluates every constraint against a finished mapping.
+func (cs *constraintSet) check(mapping [][]int) []ConstraintResult {
+	var report []ConstraintResult
+	freq := maputils.TileFrequencies(mapping)
+
+	for _, rule := range cs.counts {
+		n := 0
+		for id := range rule.tiles {
+			n += freq[id]
+		}
+		ok := n >= rule.min && (rule.max < 0 || n <= rule.max)
+		bounds := fmt.Sprintf("min %d", rule.min)
+		if rule.max >= 0 {
+			bounds += fmt.Sprintf(", max %d", rule.max)
+		}
+		report = append(report, ConstraintResult{
+			Name:      rule.name,
+			Satisfied: ok,
+			Detail:    fmt.Sprintf("%d cells (%s)", n, bounds),
+		})
+	}
+
+	for _, id := range cs.required {
+		report = append(report, ConstraintResult{
+			Name:      fmt.Sprintf("required tile %d", id),
+			Satisfied: freq[id] > 0,
+			Detail:    fmt.Sprintf("%d cells", freq[id]),
+		})
+	}
+
+	sides := make([]string, 0, len(cs.border))
+	for side := range cs.border {
+		sides = append(sides, side)
+	}
+	sort.Strings(sides)
+	for _, side := range sides {
+		allowed := make(map[int]bool)
+		for _, id := range cs.border[side] {
+			allowed[id] = true
+		}
+		bad := 0
+		for _, id := range borderCells(mapping, side) {
+			if !allowed[id] {
+				bad++
+			}
+		}
+		report = append(report, ConstraintResult{
+			Name:      "border " + side,
+			Satisfied: bad == 0,
+			Detail:    fmt.Sprintf("%d cell(s) outside the allowed tiles", bad),
+		})
+	}
+
+	names := make([]string, 0, len(cs.connected))
+	for name := range cs.connected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		regions := countRegions(mapping, cs.connected[name])
+		report = append(report, ConstraintResult{
+			Name:      fmt.Sprintf("connected %s", name),
+			Satisfied: regions <= 1,
+			Detail:    fmt.Sprintf("%d region(s)", regions),
+		})
+	}
+	return report
+}
+
+// borderCells returns the tile IDs along one side of mapping.
+func borderCells(mapping [][]int, side string) []int {
+	h := len(mapping)
+	if h == 0 {
+		return nil
+	}
+	w := len(mapping[0])
+	var out []int
+	switch side {
+	case "top":
+		out = append(out, mapping[0]...)
+	case "bottom":
+		out = append(out, mapping[h-1]...)
+	case "left":
+		for y := 0; y < h; y++ {
+			out = append(out, mapping[y][0])
+		}
+	case "right":
+		for y := 0; y < h; y++ {
+			out = append(out, mapping[y][w-1])
+		}
+	}
+	return out
+}
+
+// countRegions counts the 4-connected regions of cells holding a tile in set.
+func countRegions(mapping [][]int, set map[int]bool) int {
+	h := len(mapping)
+	if h == 0 {
+		return 0
+	}
+	w := len(mapping[0])
+	seen := make([]bool, w*h)
+	regions := 0
+	for start := range seen {
+		if seen[start] || !set[mapping[start/w][start%w]] {
+			continue
+		}
+		regions++
+		stack := []int{start}
+		seen[start] = true
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := c%w, c/w
+			for d := range wfc.DX[:4] {
+				nx, ny := x+wfc.DX[d], y+wfc.DY[d]
+				if nx < 0 || ny < 0 || nx >= w || ny >= h {
+					continue
+				}
+				n := ny*w + nx
+				if !seen[n] && set[mapping[ny][nx]] {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+	}
+	return regions
+}
+
+// satisfied reports whether every result in a report held.
+func satisfied(report []ConstraintResult) bool {
+	for _, r := range report {
+		if !r.Satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+func selectorName(sel TileSelector) string {
+	if sel.Class != "" && len(sel.Tiles) == 0 {
+		return "class " + sel.Class
+	}
+	parts := make([]string, len(sel.Tiles))
+	for i, id := range sel.Tiles {
+		parts[i] = fmt.Sprint(id)
+	}
+	name := "tiles " + strings.Join(parts, ",")
+	if sel.Class != "" {
+		name += " + class " + sel.Class
+	}
+	return name
+}
+
+func intersect(a, b []int) []int {
+	in := make(map[int]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	var out []int
+	for _, v := range a {
+		if in[v] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
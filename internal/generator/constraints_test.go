@@ -0,0 +1,240 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+)
+
+// Tile IDs of terrainTileset. They do not start at 0 so tests catch tile
+// IDs confused with solver states.
+const (
+	water = 10
+	shore = 11
+	grass = 12
+	chest = 13
+)
+
+// terrainTileset has water, shore, grass and chests. Shore sits between
+// water and grass, and a chest stands alone in grass. Every rule holds in
+// all four directions.
+func terrainTileset() *maputils.TilesetMetadata {
+	allowed := map[int][]int{
+		water: {water, shore},
+		shore: {water, shore, grass},
+		grass: {shore, grass, chest},
+		chest: {grass},
+	}
+	meta := &maputils.TilesetMetadata{TileSize: 16}
+	for _, id := range []int{water, shore, grass, chest} {
+		var hashes []string
+		for _, n := range allowed[id] {
+			hashes = append(hashes, tileHash(n))
+		}
+		meta.Tiles = append(meta.Tiles, maputils.TilesetEntry{
+			ID:        id,
+			Hash:      tileHash(id),
+			Adjacency: maputils.Adjacency{Top: hashes, Bottom: hashes, Left: hashes, Right: hashes},
+		})
+	}
+	return meta
+}
+
+func tileHash(id int) string {
+	return fmt.Sprintf("h%d", id)
+}
+
+// checkTerrain fails t if two neighbouring cells of mapping break the
+// terrainTileset rules.
+func checkTerrain(t *testing.T, mapping [][]int) {
+	t.Helper()
+	allowed := map[int]map[int]bool{}
+	for _, tile := range terrainTileset().Tiles {
+		allowed[tile.ID] = map[int]bool{}
+		for _, h := range tile.Adjacency.Right {
+			var id int
+			fmt.Sscanf(h, "h%d", &id)
+			allowed[tile.ID][id] = true
+		}
+	}
+	for y, row := range mapping {
+		for x, id := range row {
+			if x+1 < len(row) && !allowed[id][row[x+1]] {
+				t.Fatalf("tiles %d and %d side by side at (%d, %d)", id, row[x+1], x, y)
+			}
+			if y+1 < len(mapping) && !allowed[id][mapping[y+1][x]] {
+				t.Fatalf("tiles %d and %d stacked at (%d, %d)", id, mapping[y+1][x], x, y)
+			}
+		}
+	}
+}
+
+func TestConstraintBorders(t *testing.T) {
+	meta := terrainTileset()
+	ids := []int{water, shore, grass, chest}
+	tests := []struct {
+		name   string
+		border []BorderConstraint
+		want   map[string][]int
+		err    string
+	}{
+		{
+			// The top is narrowed by both rules, the left by the second
+			// only.
+			name: "overlapping sides",
+			border: []BorderConstraint{
+				{TileSelector: TileSelector{Tiles: []int{shore, grass}}, Sides: []string{"top"}},
+				{TileSelector: TileSelector{Tiles: []int{water, shore}}, Sides: []string{"top", "left"}},
+			},
+			want: map[string][]int{"top": {shore}, "left": {water, shore}},
+		},
+		{
+			name: "every side",
+			border: []BorderConstraint{
+				{TileSelector: TileSelector{Class: "wet"}},
+				{TileSelector: TileSelector{Tiles: []int{water}}, Sides: []string{"bottom"}},
+			},
+			want: map[string][]int{"top": {water, shore}, "bottom": {water}, "left": {water, shore}, "right": {water, shore}},
+		},
+		{
+			name: "empty intersection",
+			border: []BorderConstraint{
+				{TileSelector: TileSelector{Class: "wet"}, Sides: []string{"left", "right"}},
+				{TileSelector: TileSelector{Tiles: []int{grass, chest}}, Sides: []string{"right"}},
+			},
+			err: "border right: no tile is allowed by all of class wet; tiles 12,13",
+		},
+		{
+			name:   "unknown side",
+			border: []BorderConstraint{{TileSelector: TileSelector{Tiles: []int{water}}, Sides: []string{"north"}}},
+			err:    `unknown border side "north"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Constraints{Classes: map[string][]int{"wet": {water, shore}}, Border: tc.border}
+			cs, err := c.compile(meta, ids, 6, 4)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("compile error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cs.border, tc.want) {
+				t.Errorf("borders = %v, want %v", cs.border, tc.want)
+			}
+		})
+	}
+}
+
+func TestConstraintsGenerate(t *testing.T) {
+	limit := 3
+	c := &Constraints{
+		Counts:    []CountConstraint{{TileSelector: TileSelector{Tiles: []int{chest}}, Min: 2, Max: &limit}},
+		Required:  []int{water},
+		Border:    []BorderConstraint{{TileSelector: TileSelector{Tiles: []int{grass}}, Sides: []string{"top", "bottom"}}},
+		Connected: []string{"wet"},
+		Classes:   map[string][]int{"wet": {water, shore}},
+	}
+	for seed := int64(0); seed < 5; seed++ {
+		result, err := Generate(terrainTileset(), Options{Width: 10, Height: 8, Seed: seed, Attempts: 200, Constraints: c})
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		checkTerrain(t, result.Mapping)
+		if !satisfied(result.Constraints) {
+			t.Fatalf("seed %d: report %+v has a failed constraint", seed, result.Constraints)
+		}
+		freq := maputils.TileFrequencies(result.Mapping)
+		if freq[chest] < 2 || freq[chest] > 3 {
+			t.Errorf("seed %d: %d chests, want 2 to 3", seed, freq[chest])
+		}
+		if freq[water] == 0 {
+			t.Errorf("seed %d: required water is missing", seed)
+		}
+		for _, side := range []string{"top", "bottom"} {
+			for _, id := range borderCells(result.Mapping, side) {
+				if id != grass {
+					t.Fatalf("seed %d: %s border holds tile %d", seed, side, id)
+				}
+			}
+		}
+		if n := countRegions(result.Mapping, map[int]bool{water: true, shore: true}); n > 1 {
+			t.Errorf("seed %d: wet tiles form %d regions", seed, n)
+		}
+	}
+}
+
+func TestConstraintCountBounds(t *testing.T) {
+	// A maximum below the minimum is rejected up front.
+	limit := 1
+	c := &Constraints{Counts: []CountConstraint{{TileSelector: TileSelector{Tiles: []int{chest}}, Min: 2, Max: &limit}}}
+	if _, err := c.compile(terrainTileset(), []int{water, shore, grass, chest}, 3, 1); err == nil || !strings.Contains(err.Error(), "max 1 is below min 2") {
+		t.Errorf("compile error = %v, want max below min", err)
+	}
+
+	// Chests only stand next to grass, so a 2x1 map holds at most one and
+	// every attempt breaks the minimum.
+	c.Counts[0].Max = nil
+	_, err := Generate(terrainTileset(), Options{Width: 2, Height: 1, Seed: 1, Attempts: 5, Constraints: c})
+	var fail *FailureError
+	if !errors.As(err, &fail) {
+		t.Fatalf("Generate = %v, want a failure", err)
+	}
+	var violated *ConstraintError
+	if !errors.As(err, &violated) || satisfied(violated.Report) {
+		t.Fatalf("failure %v does not report the violated count", err)
+	}
+	if r := violated.Report[0]; r.Name != "count tiles 13" || r.Satisfied {
+		t.Errorf("report = %+v, want the chest count unsatisfied", r)
+	}
+}
+
+func TestLoadConstraintsYAML(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "spec.json")
+	yamlPath := filepath.Join(dir, "spec.yaml")
+	os.WriteFile(jsonPath, []byte(`{
+  "classes":   {"wet": [10, 11]},
+  "counts":    [{"class": "wet", "min": 1, "max": 3}],
+  "required":  [12],
+  "border":    [{"tiles": [12], "sides": ["top", "bottom"]}],
+  "connected": ["wet"]
+}`), 0o644)
+	os.WriteFile(yamlPath, []byte(`classes:
+  wet: [10, 11]
+counts:
+  - class: wet
+    min: 1
+    max: 3
+required: [12]
+border:
+  - tiles: [12]
+    sides: [top, bottom]
+connected: [wet]
+`), 0o644)
+
+	fromJSON, err := LoadConstraints(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := LoadConstraints(yamlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("YAML spec %+v differs from JSON spec %+v", fromYAML, fromJSON)
+	}
+	if fromYAML.Counts[0].Class != "wet" || *fromYAML.Counts[0].Max != 3 {
+		t.Errorf("YAML count = %+v, want class wet with max 3", fromYAML.Counts[0])
+	}
+}
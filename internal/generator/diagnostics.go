@@ -0,0 +1,192 @@
+package generator
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"tilemap-generator/internal/tileutils"
+	"tilemap-generator/internal/wfc"
+)
+
+var directionNames = []string{"top", "bottom", "left", "right", "top-left", "top-right", "bottom-left", "bottom-right"}
+
+// FailureError is returned when every attempt ended in a contradiction. When
+// Options.Diagnose is set it carries a Diagnosis of the last attempt.
+type FailureError struct {
+	Attempts  int
+	Err       error
+	Diagnosis *Diagnosis
+}
+
+func (e *FailureError) Error() string {
+	return fmt.Sprintf("generation failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *FailureError) Unwrap() error {
+	return e.Err
+}
+
+// CellTile identifies a cell and the tile involved at it.
+type CellTile struct {
+	X, Y  int
+	Tile  int
+	Cause string
+}
+
+// Diagnosis is a contradiction report expressed in tile IDs and output cells.
+type Diagnosis struct {
+	X, Y int
+	// Chain is the propagation chain that emptied the cell, origin first.
+	Chain []CellTile
+	// Responsible lists the observed or pinned cells whose choices started
+	// the chains that emptied the cell.
+	Responsible []CellTile
+	// Neighbours holds the decided tile next to the cell in each direction
+	// (top, bottom, left, right, then the diagonals for eight-way models), or
+	// -1 when undecided or off the map.
+	Neighbours []int
+	// MissingTransition is true when no tile in the set fits the decided
+	// neighbours at all.
+	MissingTransition bool
+	// Partial is the map at the time of the contradiction; -1 is undecided.
+	Partial [][]int
+}
+
+// newDiagnosis converts a solver diagnosis using tileOf to map states to
+// tile IDs and decode to turn the state grid into a tile grid.
+func newDiagnosis(d *wfc.Diagnosis, width int, tileOf func(int) int, decode func([][]int) [][]int) *Diagnosis {
+	cell := func(e wfc.Event) CellTile {
+		return CellTile{X: e.Cell % width, Y: e.Cell / width, Tile: tileOf(e.State), Cause: e.Cause.String()}
+	}
+	out := &Diagnosis{
+		X:                 d.Cell % width,
+		Y:                 d.Cell / width,
+		MissingTransition: d.MissingTransition,
+		Partial:           decode(d.States),
+	}
+	for _, e := range d.Chain {
+		out.Chain = append(out.Chain, cell(e))
+	}
+	for _, e := range d.Origins {
+		c := cell(e)
+		if e.Cause == wfc.CauseObserved || e.Cause == wfc.CauseRestricted {
+			c.Tile = -1
+			if s := d.States[c.Y][c.X]; s >= 0 {
+				c.Tile = tileOf(s)
+			}
+		}
+		out.Responsible = append(out.Responsible, c)
+	}
+	for _, s := range d.Neighbours {
+		if s < 0 {
+			out.Neighbours = append(out.Neighbours, -1)
+		} else {
+			out.Neighbours = append(out.Neighbours, tileOf(s))
+		}
+	}
+	return out
+}
+
+// Report renders the diagnosis as human-readable text.
+func (d *Diagnosis) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Contradiction at cell (%d, %d)\n\n", d.X, d.Y)
+
+	b.WriteString("Decided neighbours:\n")
+	for dir, t := range d.Neighbours {
+		name := fmt.Sprintf("dir %d", dir)
+		if dir < len(directionNames) {
+			name = directionNames[dir]
+		}
+		if t < 0 {
+			fmt.Fprintf(&b, "  %-12s undecided\n", name)
+		} else {
+			fmt.Fprintf(&b, "  %-12s tile %d\n", name, t)
+		}
+	}
+
+	b.WriteString("\nVerdict: ")
+	if d.MissingTransition {
+		b.WriteString("no tile in the set may sit next to all decided neighbours.\n" +
+			"The tileset is probably missing a transition tile for this combination.\n")
+	} else {
+		b.WriteString("tiles exist that fit the decided neighbours, but propagation\n" +
+			"from other cells removed them. The constraints are too tight here.\n")
+	}
+
+	b.WriteString("\nPropagation chain (origin first):\n")
+	for i, c := range d.Chain {
+		verb := "lost tile"
+		switch c.Cause {
+		case wfc.CauseObserved.String():
+			verb = "observed, dropping tile"
+		case wfc.CauseRestricted.String():
+			verb = "pinned, dropping tile"
+		}
+		fmt.Fprintf(&b, "  %3d. (%d, %d) %s %d\n", i+1, c.X, c.Y, verb, c.Tile)
+	}
+
+	b.WriteString("\nResponsible cells:\n")
+	if len(d.Responsible) == 0 {
+		b.WriteString("  none (the model cannot fill this cell at all)\n")
+	}
+	for _, c := range d.Responsible {
+		if c.Tile < 0 {
+			fmt.Fprintf(&b, "  (%d, %d) %s\n", c.X, c.Y, c.Cause)
+		} else {
+			fmt.Fprintf(&b, "  (%d, %d) %s as tile %d\n", c.X, c.Y, c.Cause, c.Tile)
+		}
+	}
+	return b.String()
+}
+
+// RenderOverlay draws the partial map with the contradiction cell filled
+// red, pinned cells outlined in cyan, observed cells outlined in yellow and
+// the rest of the propagation chain outlined in orange.
+func (d *Diagnosis) RenderOverlay(images map[int]image.Image, tileWidth, tileHeight int) *image.RGBA {
+	img := tileutils.DrawMapping(images, d.Partial, tileWidth, tileHeight)
+	cellRect := func(x, y int) image.Rectangle {
+		return image.Rect(x*tileWidth, y*tileHeight, (x+1)*tileWidth, (y+1)*tileHeight)
+	}
+
+	undecided := image.NewUniform(color.RGBA{60, 60, 60, 255})
+	for y, row := range d.Partial {
+		for x, id := range row {
+			if id < 0 {
+				draw.Draw(img, cellRect(x, y), undecided, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	for _, c := range d.Chain {
+		outline(img, cellRect(c.X, c.Y), color.RGBA{255, 140, 0, 255})
+	}
+	for _, c := range d.Responsible {
+		col := color.RGBA{255, 220, 0, 255}
+		if c.Cause == wfc.CauseRestricted.String() {
+			col = color.RGBA{0, 220, 255, 255}
+		}
+		outline(img, cellRect(c.X, c.Y), col)
+	}
+	red := image.NewUniform(color.RGBA{255, 0, 0, 160})
+	draw.Draw(img, cellRect(d.X, d.Y), red, image.Point{}, draw.Over)
+	outline(img, cellRect(d.X, d.Y), color.RGBA{255, 0, 0, 255})
+	return img
+}
+
+// outline draws a two pixel border inside r.
+func outline(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for i := 0; i < 2; i++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, r.Min.Y+i, c)
+			img.Set(x, r.Max.Y-1-i, c)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			img.Set(r.Min.X+i, y, c)
+			img.Set(r.Max.X-1-i, y, c)
+		}
+	}
+}
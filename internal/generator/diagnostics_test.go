@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// swapTileset has tiles 1, 2 and 3. Side by side, 1 and 2 swap and 3 stays;
+// stacked, 2 and 3 swap and 1 stays. Every tile has a neighbour in every
+// direction, but going right then down never reaches the same tile as
+// going down then right, so no 2x2 map exists and the contradiction only
+// shows once a cell is observed.
+var (
+	swapSide    = map[int]int{1: 2, 2: 1, 3: 3}
+	swapStacked = map[int]int{1: 1, 2: 3, 3: 2}
+)
+
+func swapTileset() *maputils.TilesetMetadata {
+	meta := &maputils.TilesetMetadata{TileSize: 16}
+	for _, id := range []int{1, 2, 3} {
+		h := []string{tileHash(swapSide[id])}
+		v := []string{tileHash(swapStacked[id])}
+		meta.Tiles = append(meta.Tiles, maputils.TilesetEntry{
+			ID:        id,
+			Hash:      tileHash(id),
+			Adjacency: maputils.Adjacency{Top: v, Bottom: v, Left: h, Right: h},
+		})
+	}
+	return meta
+}
+
+func TestDiagnoseContradiction(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		_, err := Generate(swapTileset(), Options{Width: 2, Height: 2, Seed: seed, Attempts: 1, Diagnose: true})
+		var fail *FailureError
+		if !errors.As(err, &fail) || fail.Diagnosis == nil {
+			t.Fatalf("seed %d: Generate = %v, want a failure with a diagnosis", seed, err)
+		}
+		var ce *wfc.ContradictionError
+		if !errors.As(err, &ce) {
+			t.Fatalf("seed %d: failure %v does not wrap the contradiction", seed, err)
+		}
+		d := fail.Diagnosis
+		if d.X != ce.X || d.Y != ce.Y {
+			t.Errorf("seed %d: diagnosis names cell (%d, %d), contradiction is at (%d, %d)", seed, d.X, d.Y, ce.X, ce.Y)
+		}
+
+		// The one observed cell is responsible. Propagation runs round the
+		// square through the other three cells and back into it, removing
+		// the tile it was given.
+		if len(d.Responsible) != 1 || d.Responsible[0].Cause != "observed" {
+			t.Fatalf("seed %d: responsible = %+v, want the observed cell", seed, d.Responsible)
+		}
+		if obs := d.Responsible[0]; obs.X != d.X || obs.Y != d.Y {
+			t.Errorf("seed %d: observed cell (%d, %d) is not the contradiction cell (%d, %d)", seed, obs.X, obs.Y, d.X, d.Y)
+		}
+		visited := map[[2]int]bool{}
+		for _, c := range d.Chain {
+			visited[[2]int{c.X, c.Y}] = true
+		}
+		first, last := d.Chain[0], d.Chain[len(d.Chain)-1]
+		if first.X != d.X || first.Y != d.Y || first.Cause != "observed" {
+			t.Errorf("seed %d: chain starts at %+v, want the observation", seed, first)
+		}
+		if last.X != d.X || last.Y != d.Y || len(visited) != 4 {
+			t.Errorf("seed %d: chain %+v does not go round the square back to the cell", seed, d.Chain)
+		}
+		if d.Partial[d.Y][d.X] != -1 {
+			t.Errorf("seed %d: partial map holds %d in the emptied cell", seed, d.Partial[d.Y][d.X])
+		}
+
+		// The verdict blames a missing transition exactly when no tile fits
+		// the decided neighbours.
+		fits := 0
+		for id := 1; id <= 3; id++ {
+			ok := true
+			for dir, n := range d.Neighbours {
+				rule := swapSide
+				if dir == wfc.Up || dir == wfc.Down {
+					rule = swapStacked
+				}
+				ok = ok && (n < 0 || rule[n] == id)
+			}
+			if ok {
+				fits++
+			}
+		}
+		if d.MissingTransition != (fits == 0) {
+			t.Errorf("seed %d: neighbours %v admit %d tile(s), verdict blames a missing transition: %v", seed, d.Neighbours, fits, d.MissingTransition)
+		}
+		want := fmt.Sprintf("Contradiction at cell (%d, %d)", d.X, d.Y)
+		if report := d.Report(); !strings.Contains(report, want) || !strings.Contains(report, "observed") {
+			t.Errorf("seed %d: report does not name the cell and the observation:\n%s", seed, report)
+		}
+	}
+}
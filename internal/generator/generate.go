@@ -0,0 +1,257 @@
+package generator
+
+import (
+	"fmt"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// Generation models understood by Generate.
+const (
+	ModeSimple      = "simple"
+	ModeOverlapping = "overlapping"
+)
+
+// Options control a generation run.
+type Options struct {
+	Width    int
+	Height   int
+	Seed     int64
+	Attempts int
+	Periodic bool
+
+	// Mode selects the model; empty means ModeSimple.
+	Mode string
+	// N is the pattern size of the overlapping model.
+	N int
+	// PeriodicInput lets overlapping patterns wrap around the source mapping.
+	PeriodicInput bool
+	// Augment is the number of rotated/reflected variants (1-8) of each
+	// overlapping pattern to include.
+	Augment int
+	// Counterparts maps tiles to their rotated/reflected versions for
+	// Augment, as returned by PatternCounterparts. It is required when
+	// Augment is above 1.
+	Counterparts []map[int]int
+
+	// Block is the side of the source area summarised by one coarse cell in
+	// the hierarchical model.
+	Block int
+
+	// Diagnose traces propagation so a failure carries a Diagnosis.
+	Diagnose bool
+
+	// Constraints are global rules the map must satisfy. They are supported
+	// by every model built on tile adjacency, but not the overlapping model.
+	Constraints *Constraints
+	// Symmetry makes the map mirror or rotationally symmetric. Like
+	// Constraints it is not supported by the overlapping model.
+	Symmetry *Symmetry
+
+	// Record, if set, captures frames of the last attempt for animation.
+	Record *Recorder
+}
+
+// Result holds a generated mapping and how it was produced.
+type Result struct {
+	Mapping  [][]int
+	Seed     int64
+	Attempts int
+	// Constraints reports each global constraint for the accepted map.
+	Constraints []ConstraintResult
+}
+
+// Generate synthesises a new mapping of opts.Width x opts.Height tiles using
+// the model selected by opts.Mode.
+func Generate(meta *maputils.TilesetMetadata, opts Options) (*Result, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, fmt.Errorf("invalid map size %dx%d", opts.Width, opts.Height)
+	}
+	if err := checkOrthogonal(meta); err != nil {
+		return nil, err
+	}
+	switch opts.Mode {
+	case "", ModeSimple:
+		return generateSimple(meta, opts)
+	case ModeOverlapping:
+		return generateOverlapping(meta, opts)
+	case ModeHierarchical:
+		return generateHierarchical(meta, opts)
+	default:
+		return nil, fmt.Errorf("unknown generation mode %q", opts.Mode)
+	}
+}
+
+// checkOrthogonal rejects isometric tilesets, whose diagonal adjacency the
+// generators and renderers do not understand yet.
+func checkOrthogonal(meta *maputils.TilesetMetadata) error {
+	if meta.Isometric() {
+		return fmt.Errorf("isometric tilesets cannot be generated yet")
+	}
+	return nil
+}
+
+// checkSimpleMode rejects models other than the simple one for features
+// built on tile adjacency alone, such as inpainting.
+func checkSimpleMode(opts Options, feature string) error {
+	if opts.Mode != "" && opts.Mode != ModeSimple {
+		return fmt.Errorf("%s supports only the %s model, not %q", feature, ModeSimple, opts.Mode)
+	}
+	return nil
+}
+
+// generateSimple runs the simple-tiled model learned from the tileset adjacency.
+func generateSimple(meta *maputils.TilesetMetadata, opts Options) (*Result, error) {
+	model, ids, err := SimpleModel(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	solver := wfc.NewSolver(model, opts.Width, opts.Height, opts.Periodic)
+	j, err := simpleJob(meta, ids, opts, opts.Width, opts.Height)
+	if err != nil {
+		return nil, err
+	}
+	return solve(solver, opts, j)
+}
+
+// simpleJob prepares a job for a simple-model grid of width x height.
+func simpleJob(meta *maputils.TilesetMetadata, ids []int, opts Options, width, height int) (job, error) {
+	j := job{
+		tileOf: func(s int) int { return ids[s] },
+		decode: func(states [][]int) [][]int { return statesToIDs(states, ids) },
+	}
+	if opts.Constraints != nil {
+		cs, err := opts.Constraints.compile(meta, ids, width, height)
+		if err != nil {
+			return j, err
+		}
+		j.constraints = cs
+	}
+	if opts.Symmetry != nil {
+		ss, err := opts.Symmetry.compile(meta, ids, width, height)
+		if err != nil {
+			return j, err
+		}
+		j.symmetry = ss
+	}
+	return j, nil
+}
+
+// generateOverlapping runs the NxN overlapping model learned from the
+// tileset mapping.
+func generateOverlapping(meta *maputils.TilesetMetadata, opts Options) (*Result, error) {
+	if len(meta.Mapping) == 0 {
+		return nil, fmt.Errorf("tileset has no mapping to learn patterns from")
+	}
+	if opts.Constraints != nil {
+		return nil, fmt.Errorf("global constraints are not supported by the overlapping model")
+	}
+	if opts.Symmetry != nil {
+		return nil, fmt.Errorf("symmetry is not supported by the overlapping model")
+	}
+	if opts.N == 0 {
+		opts.N = 3
+	}
+	if opts.Augment == 0 {
+		opts.Augment = 1
+	}
+	ov, err := wfc.NewOverlapping(meta.Mapping, opts.N, opts.PeriodicInput, opts.Augment, opts.Counterparts)
+	if err != nil {
+		return nil, err
+	}
+
+	gw, gh := ov.GridSize(opts.Width, opts.Height, opts.Periodic)
+	if gw <= 0 || gh <= 0 {
+		return nil, fmt.Errorf("map size %dx%d is smaller than pattern size %d", opts.Width, opts.Height, opts.N)
+	}
+	solver := wfc.NewSolver(ov.Model, gw, gh, opts.Periodic)
+	return solve(solver, opts, job{
+		tileOf: func(s int) int { return ov.Patterns[s][0] },
+		decode: func(states [][]int) [][]int {
+			return ov.Decode(states, opts.Width, opts.Height, opts.Periodic)
+		},
+	})
+}
+
+// job describes how solver states become a map and which global
+// constraints and symmetry the map must satisfy.
+type job struct {
+	tileOf      func(int) int
+	decode      func([][]int) [][]int
+	constraints *constraintSet
+	symmetry    *symmetrySet
+}
+
+// solve runs up to opts.Attempts attempts, each seeded with opts.Seed plus
+// the attempt number. Attempts ending in a contradiction or producing a map
+// that violates the constraints are retried. On failure it returns a
+// *FailureError, diagnosed when opts.Diagnose is set.
+func solve(solver *wfc.Solver, opts Options, j job) (*Result, error) {
+	solver.Trace = opts.Diagnose
+	var hooks []func(*wfc.Solver) error
+	if j.constraints != nil {
+		j.constraints.restrict(solver)
+		hooks = append(hooks, j.constraints.hook)
+	}
+	if j.symmetry != nil {
+		j.symmetry.restrict(solver)
+		hooks = append(hooks, j.symmetry.hook)
+	}
+	if opts.Record != nil {
+		hooks = append(hooks, opts.Record.hook(j))
+	}
+	solver.Hook = nil
+	if len(hooks) > 0 {
+		solver.Hook = func(s *wfc.Solver) error {
+			for _, h := range hooks {
+				if err := h(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	attempts := opts.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	var diagnosis *wfc.Diagnosis
+	for a := 0; a < attempts; a++ {
+		if j.symmetry != nil {
+			j.symmetry.reset()
+		}
+		if opts.Record != nil {
+			opts.Record.reset(solver.Cells())
+		}
+		err = solver.Run(opts.Seed + int64(a))
+		if opts.Record != nil {
+			opts.Record.capture(solver, j)
+		}
+		if err != nil {
+			if d := solver.Diagnose(); d != nil {
+				diagnosis = d
+			}
+			continue
+		}
+		mapping := j.decode(solver.Result())
+		result := &Result{Mapping: mapping, Seed: opts.Seed, Attempts: a + 1}
+		if j.constraints == nil {
+			return result, nil
+		}
+		result.Constraints = j.constraints.check(mapping)
+		if satisfied(result.Constraints) {
+			return result, nil
+		}
+		err = &ConstraintError{Report: result.Constraints}
+	}
+
+	fail := &FailureError{Attempts: attempts, Err: err}
+	if diagnosis != nil {
+		fail.Diagnosis = newDiagnosis(diagnosis, solver.Width, j.tileOf, j.decode)
+	}
+	return nil, fail
+}
@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tilemap-generator/internal/wfc"
+)
+
+// dotTile returns a 4x4 black tile with a white pixel at (x, y).
+func dotTile(x, y int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	img.Set(x, y, color.White)
+	return img
+}
+
+func TestPatternCounterparts(t *testing.T) {
+	// A dot in the top-left corner turns clockwise and mirrors into the
+	// top-right one; the top-right dot turns into a corner no tile has.
+	images := map[int]image.Image{1: dotTile(0, 0), 2: dotTile(3, 0)}
+	cps := PatternCounterparts(images)
+	if len(cps) != wfc.Variants-1 {
+		t.Fatalf("got counterparts for %d variants, want %d", len(cps), wfc.Variants-1)
+	}
+	if want := map[int]int{1: 2, 2: 1}; !reflect.DeepEqual(cps[0], want) {
+		t.Errorf("mirrored counterparts = %v, want %v", cps[0], want)
+	}
+	if want := map[int]int{1: 2}; !reflect.DeepEqual(cps[1], want) {
+		t.Errorf("turned counterparts = %v, want %v", cps[1], want)
+	}
+	// A turn and a mirror leave the top-left dot in place.
+	if want := map[int]int{1: 1}; !reflect.DeepEqual(cps[2], want) {
+		t.Errorf("turned and mirrored counterparts = %v, want %v", cps[2], want)
+	}
+
+	meta := terrainTileset()
+	meta.Mapping = [][]int{{water, shore, grass}, {water, shore, grass}, {water, shore, grass}}
+	_, err := Generate(meta, Options{Mode: ModeOverlapping, Width: 6, Height: 6, N: 2, Augment: 2})
+	if err == nil || !strings.Contains(err.Error(), "needs tile counterparts") {
+		t.Errorf("Generate error = %v, want missing counterparts", err)
+	}
+}
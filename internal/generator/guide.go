@@ -0,0 +1,258 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// Legend maps the colours of a guide image to the tiles allowed in the
+// regions painted with them.
+type Legend struct {
+	// Classes adds named tile sets on top of the tileset's classes.
+	Classes map[string][]int `json:"classes,omitempty"`
+	// Colours maps a "#rrggbb" colour to its tiles.
+	Colours map[string]TileSelector `json:"colours"`
+	// Blend is how many cells either side of a region boundary may also use
+	// the neighbouring region's tiles and tiles the legend does not mention,
+	// so transitions such as shorelines can be placed. Nil means 2.
+	Blend *int `json:"blend,omitempty"`
+}
+
+// LoadLegend reads a guide legend from a JSON file.
+func LoadLegend(path string) (*Legend, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legend: %w", err)
+	}
+	var l Legend
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("failed to decode legend: %w", err)
+	}
+	if len(l.Colours) == 0 {
+		return nil, fmt.Errorf("legend maps no colours")
+	}
+	return &l, nil
+}
+
+// legendColour is one parsed legend entry.
+type legendColour struct {
+	r, g, b int
+	tiles   []int
+}
+
+// GenerateFromGuide generates a map whose cells follow the regions painted in
+// guide. Every pixel of the guide is matched to the nearest legend colour and
+// the cells it covers are restricted to that colour's tiles; transparent
+// pixels leave their cells unrestricted. The guide is scaled to
+// opts.Width x opts.Height, or used one pixel per cell when they are zero.
+// Only the simple model is supported.
+func GenerateFromGuide(meta *maputils.TilesetMetadata, guide image.Image, legend *Legend, opts Options) (*Result, error) {
+	if err := checkSimpleMode(opts, "guided generation"); err != nil {
+		return nil, err
+	}
+	if err := checkOrthogonal(meta); err != nil {
+		return nil, err
+	}
+	bounds := guide.Bounds()
+	if opts.Width == 0 || opts.Height == 0 {
+		opts.Width, opts.Height = bounds.Dx(), bounds.Dy()
+	}
+	if opts.Width == 0 || opts.Height == 0 {
+		return nil, fmt.Errorf("guide image is empty")
+	}
+
+	model, ids, err := SimpleModel(meta)
+	if err != nil {
+		return nil, err
+	}
+	stateByID := make(map[int]int, len(ids))
+	for s, id := range ids {
+		stateByID[id] = s
+	}
+	colours, err := legend.compile(meta, stateByID)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := make([][]int, opts.Height)
+	for y := range regions {
+		regions[y] = make([]int, opts.Width)
+		for x := range regions[y] {
+			px := bounds.Min.X + x*bounds.Dx()/opts.Width
+			py := bounds.Min.Y + y*bounds.Dy()/opts.Height
+			regions[y][x] = nearestColour(colours, guide.At(px, py))
+		}
+	}
+
+	claimed := make(map[int]bool)
+	for _, c := range colours {
+		for _, id := range c.tiles {
+			claimed[id] = true
+		}
+	}
+	var free []int
+	for _, id := range ids {
+		if !claimed[id] {
+			free = append(free, id)
+		}
+	}
+
+	blend := 2
+	if legend.Blend != nil {
+		blend = *legend.Blend
+	}
+	solver := wfc.NewSolver(model, opts.Width, opts.Height, opts.Periodic)
+	for y, row := range regions {
+		for x := range row {
+			near := nearbyRegions(regions, x, y, blend)
+			if len(near) == 0 {
+				continue
+			}
+			allowed := make(map[int]bool)
+			for _, r := range near {
+				for _, id := range colours[r].tiles {
+					allowed[stateByID[id]] = true
+				}
+			}
+			if len(near) > 1 || hasUnpainted(regions, x, y, blend) {
+				for _, id := range free {
+					allowed[stateByID[id]] = true
+				}
+			}
+			states := make([]int, 0, len(allowed))
+			for s := range allowed {
+				states = append(states, s)
+			}
+			sort.Ints(states)
+			solver.Restrict(x, y, states)
+		}
+	}
+
+	j, err := simpleJob(meta, ids, opts, opts.Width, opts.Height)
+	if err != nil {
+		return nil, err
+	}
+	return solve(solver, opts, j)
+}
+
+// compile resolves every legend colour to its tile IDs, sorted by colour so
+// ties in nearestColour are broken the same way on every run.
+func (l *Legend) compile(meta *maputils.TilesetMetadata, stateByID map[int]int) ([]legendColour, error) {
+	keys := make([]string, 0, len(l.Colours))
+	for k := range l.Colours {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out []legendColour
+	for _, k := range keys {
+		r, g, b, err := parseHexColour(k)
+		if err != nil {
+			return nil, err
+		}
+		sel := l.Colours[k]
+		tiles := append([]int{}, sel.Tiles...)
+		if sel.Class != "" {
+			class, ok := l.Classes[sel.Class]
+			if !ok {
+				class, ok = meta.Classes[sel.Class]
+			}
+			if !ok {
+				return nil, fmt.Errorf("legend colour %s uses unknown tile class %q", k, sel.Class)
+			}
+			tiles = append(tiles, class...)
+		}
+		if len(tiles) == 0 {
+			return nil, fmt.Errorf("legend colour %s selects no tiles", k)
+		}
+		for _, id := range tiles {
+			if _, ok := stateByID[id]; !ok {
+				return nil, fmt.Errorf("legend colour %s uses unknown tile ID %d", k, id)
+			}
+		}
+		out = append(out, legendColour{r: r, g: g, b: b, tiles: tiles})
+	}
+	return out, nil
+}
+
+// parseHexColour parses "#rrggbb" or "rrggbb".
+func parseHexColour(s string) (r, g, b int, err error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid legend colour %q, expected #rrggbb", s)
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff), nil
+}
+
+// nearestColour returns the index of the legend colour closest to c, or -1
+// for mostly transparent pixels.
+func nearestColour(colours []legendColour, c color.Color) int {
+	r, g, b, a := c.RGBA()
+	if a < 0x8000 {
+		return -1
+	}
+	best, bestDist := -1, 0
+	for i, lc := range colours {
+		dr := int(r>>8) - lc.r
+		dg := int(g>>8) - lc.g
+		db := int(b>>8) - lc.b
+		if d := dr*dr + dg*dg + db*db; best < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// nearbyRegions lists the distinct painted regions within radius cells of
+// (x, y), the cell's own region first. It returns nil for unpainted cells.
+func nearbyRegions(regions [][]int, x, y, radius int) []int {
+	own := regions[y][x]
+	if own < 0 {
+		return nil
+	}
+	out := []int{own}
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			nx, ny := x+dx, y+dy
+			if ny < 0 || ny >= len(regions) || nx < 0 || nx >= len(regions[ny]) {
+				continue
+			}
+			if r := regions[ny][nx]; r >= 0 && !containsInt(out, r) {
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
+// hasUnpainted reports whether an unpainted cell lies within radius of (x, y).
+func hasUnpainted(regions [][]int, x, y, radius int) bool {
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			nx, ny := x+dx, y+dy
+			if ny >= 0 && ny < len(regions) && nx >= 0 && nx < len(regions[ny]) && regions[ny][nx] < 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package generator
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFromGuide(t *testing.T) {
+	// Water on the left half, grass on the right.
+	guide := image.NewRGBA(image.Rect(0, 0, 10, 4))
+	draw.Draw(guide, image.Rect(0, 0, 5, 4), &image.Uniform{color.RGBA{48, 96, 255, 255}}, image.Point{}, draw.Src)
+	draw.Draw(guide, image.Rect(5, 0, 10, 4), &image.Uniform{color.RGBA{64, 192, 64, 255}}, image.Point{}, draw.Src)
+	blend := 1
+	legend := &Legend{
+		Colours: map[string]TileSelector{"#3060ff": {Tiles: []int{water}}, "#40c040": {Tiles: []int{grass}}},
+		Blend:   &blend,
+	}
+
+	result, err := GenerateFromGuide(terrainTileset(), guide, legend, Options{Seed: 1, Attempts: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTerrain(t, result.Mapping)
+	for y, row := range result.Mapping {
+		if len(row) != 10 {
+			t.Fatalf("row %d has %d cells, want one per guide pixel", y, len(row))
+		}
+		// Only the cells next to the boundary may blend.
+		if row[0] != water || row[1] != water || row[2] != water || row[3] != water {
+			t.Errorf("row %d: water region holds %v", y, row[:4])
+		}
+		if row[6] != grass || row[7] != grass || row[8] != grass || row[9] != grass {
+			t.Errorf("row %d: grass region holds %v", y, row[6:])
+		}
+	}
+
+	_, err = GenerateFromGuide(terrainTileset(), guide, legend, Options{Mode: ModeHierarchical})
+	if err == nil || !strings.Contains(err.Error(), `guided generation supports only the simple model, not "hierarchical"`) {
+		t.Errorf("GenerateFromGuide error = %v, want the mode rejected", err)
+	}
+}
@@ -0,0 +1,212 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// ModeHierarchical generates a coarse layout first and then fills it with
+// tiles.
+const ModeHierarchical = "hierarchical"
+
+// generateHierarchical summarises each Block x Block area of the source
+// mapping by its dominant terrain, learns a model of those summaries and
+// generates a coarse layout with it. Every fine cell is then restricted to
+// the tiles seen in source blocks with the same summary, and the fine map is
+// solved with the tileset adjacency. If the fine map cannot be solved a new
+// coarse layout is tried, up to opts.Attempts layouts with one fine attempt
+// each. The result's Attempts counts every coarse and fine solver run.
+func generateHierarchical(meta *maputils.TilesetMetadata, opts Options) (*Result, error) {
+	if len(meta.Mapping) == 0 {
+		return nil, fmt.Errorf("tileset has no mapping to learn a coarse layout from")
+	}
+	if opts.Block == 0 {
+		opts.Block = 4
+	}
+	b := opts.Block
+	if b < 2 {
+		return nil, fmt.Errorf("block size must be at least 2, got %d", b)
+	}
+
+	labelOf := terrainLabels(meta)
+	coarseSample, vocab := summarise(meta.Mapping, b, labelOf)
+	if len(coarseSample) == 0 {
+		return nil, fmt.Errorf("source mapping is smaller than one %dx%d block", b, b)
+	}
+	coarseModel, labels := SampleModel(coarseSample)
+
+	model, ids, err := SimpleModel(meta)
+	if err != nil {
+		return nil, err
+	}
+	stateByID := make(map[int]int, len(ids))
+	for s, id := range ids {
+		stateByID[id] = s
+	}
+
+	cw := (opts.Width + b - 1) / b
+	ch := (opts.Height + b - 1) / b
+	coarseSolver := wfc.NewSolver(coarseModel, cw, ch, opts.Periodic)
+	fine := wfc.NewSolver(model, opts.Width, opts.Height, opts.Periodic)
+	j, err := simpleJob(meta, ids, opts, opts.Width, opts.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := opts.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var last error
+	runs := 0
+	for a := 0; a < attempts; a++ {
+		runs++
+		if _, err := coarseSolver.Solve(deriveSeed(opts.Seed, 'l', a, 0), 1); err != nil {
+			last = fmt.Errorf("coarse layout: %w", err)
+			continue
+		}
+		layout := coarseSolver.Result()
+
+		fine.ClearRestrictions()
+		for y := 0; y < opts.Height; y++ {
+			for x := 0; x < opts.Width; x++ {
+				allowed := blockVocabulary(layout, labels, vocab, x, y, b)
+				states := make([]int, 0, len(allowed))
+				for id := range allowed {
+					states = append(states, stateByID[id])
+				}
+				sort.Ints(states)
+				fine.Restrict(x, y, states)
+			}
+		}
+
+		// A layout the fine solver cannot fill at once is usually better
+		// replaced than retried, so each layout gets a single fine run.
+		fineOpts := opts
+		fineOpts.Seed = opts.Seed + int64(a)
+		fineOpts.Attempts = 1
+		runs++
+		result, err := solve(fine, fineOpts, j)
+		if err == nil {
+			result.Seed = opts.Seed
+			result.Attempts = runs
+			return result, nil
+		}
+		last = err
+	}
+	return nil, fmt.Errorf("no coarse layout could be filled after %d attempts (%d solver runs): %w", attempts, runs, last)
+}
+
+// terrainLabels maps each tile ID to a terrain label. When the tileset
+// defines classes a tile's label is its (first, alphabetically) class;
+// otherwise, or for unclassified tiles, the tile is its own terrain.
+func terrainLabels(meta *maputils.TilesetMetadata) func(id int) int {
+	names := make([]string, 0, len(meta.Classes))
+	for name := range meta.Classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	classOf := make(map[int]int)
+	for i, name := range names {
+		for _, id := range meta.Classes[name] {
+			if _, ok := classOf[id]; !ok {
+				classOf[id] = i
+			}
+		}
+	}
+	maxID := 0
+	for _, t := range meta.Tiles {
+		if t.ID > maxID {
+			maxID = t.ID
+		}
+	}
+	return func(id int) int {
+		if c, ok := classOf[id]; ok {
+			return maxID + 1 + c
+		}
+		return id
+	}
+}
+
+// summarise labels every complete b x b block of mapping with its dominant
+// terrain and records the tiles seen in blocks of each label.
+func summarise(mapping [][]int, b int, labelOf func(int) int) ([][]int, map[int]map[int]bool) {
+	rows := len(mapping) / b
+	cols := 0
+	if len(mapping) > 0 {
+		cols = len(mapping[0]) / b
+	}
+	vocab := make(map[int]map[int]bool)
+	coarse := make([][]int, rows)
+	for by := 0; by < rows; by++ {
+		coarse[by] = make([]int, cols)
+		for bx := 0; bx < cols; bx++ {
+			counts := make(map[int]int)
+			for y := by * b; y < (by+1)*b; y++ {
+				for x := bx * b; x < (bx+1)*b; x++ {
+					if id := mapping[y][x]; id >= 0 {
+						counts[labelOf(id)]++
+					}
+				}
+			}
+			label, best := -1, 0
+			for l, n := range counts {
+				if n > best || (n == best && l < label) {
+					label, best = l, n
+				}
+			}
+			coarse[by][bx] = label
+			if label < 0 {
+				continue
+			}
+			if vocab[label] == nil {
+				vocab[label] = make(map[int]bool)
+			}
+			for y := by * b; y < (by+1)*b; y++ {
+				for x := bx * b; x < (bx+1)*b; x++ {
+					if id := mapping[y][x]; id >= 0 {
+						vocab[label][id] = true
+					}
+				}
+			}
+		}
+	}
+	if rows == 0 || cols == 0 {
+		return nil, vocab
+	}
+	return coarse, vocab
+}
+
+// blockVocabulary returns the tiles allowed at fine cell (x, y). Cells on the
+// edge of their block may also use the tiles of the neighbouring block's
+// terrain so transitions between terrains stay possible.
+func blockVocabulary(layout [][]int, labels []int, vocab map[int]map[int]bool, x, y, b int) map[int]bool {
+	bx, by := x/b, y/b
+	allowed := make(map[int]bool)
+	add := func(cx, cy int) {
+		if cy < 0 || cy >= len(layout) || cx < 0 || cx >= len(layout[cy]) {
+			return
+		}
+		for id := range vocab[labels[layout[cy][cx]]] {
+			allowed[id] = true
+		}
+	}
+	add(bx, by)
+	if x%b == 0 {
+		add(bx-1, by)
+	}
+	if x%b == b-1 {
+		add(bx+1, by)
+	}
+	if y%b == 0 {
+		add(bx, by-1)
+	}
+	if y%b == b-1 {
+		add(bx, by+1)
+	}
+	return allowed
+}
@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+// terrainSource is a source map of a lake on the left and a meadow with a
+// chest on the right, split by a shoreline.
+func terrainSource() [][]int {
+	var mapping [][]int
+	for y := 0; y < 8; y++ {
+		row := make([]int, 16)
+		for x := range row {
+			switch {
+			case x < 7:
+				row[x] = water
+			case x == 7:
+				row[x] = shore
+			default:
+				row[x] = grass
+			}
+		}
+		mapping = append(mapping, row)
+	}
+	mapping[3][12] = chest
+	return mapping
+}
+
+func TestSummarise(t *testing.T) {
+	meta := terrainTileset()
+	meta.Classes = map[string][]int{"wet": {water, shore}}
+	labelOf := terrainLabels(meta)
+	wet := labelOf(water)
+	if labelOf(shore) != wet || labelOf(grass) != grass {
+		t.Fatalf("labels: water %d, shore %d, grass %d; want water and shore to share a class label", wet, labelOf(shore), labelOf(grass))
+	}
+
+	coarse, vocab := summarise(terrainSource(), 4, labelOf)
+	want := [][]int{{wet, wet, grass, grass}, {wet, wet, grass, grass}}
+	if !reflect.DeepEqual(coarse, want) {
+		t.Errorf("coarse = %v, want %v", coarse, want)
+	}
+	wantVocab := map[int]map[int]bool{wet: {water: true, shore: true}, grass: {grass: true, chest: true}}
+	if !reflect.DeepEqual(vocab, wantVocab) {
+		t.Errorf("vocabulary = %v, want %v", vocab, wantVocab)
+	}
+
+	// Without classes a block takes its most common tile, the lower ID on
+	// a tie as in the middle block. Incomplete blocks at the edges are
+	// dropped.
+	coarse, _ = summarise(terrainSource(), 5, terrainLabels(terrainTileset()))
+	if want := [][]int{{water, water, grass}}; !reflect.DeepEqual(coarse, want) {
+		t.Errorf("coarse without classes = %v, want %v", coarse, want)
+	}
+}
+
+func TestHierarchicalBlocks(t *testing.T) {
+	meta := terrainTileset()
+	meta.Classes = map[string][]int{"wet": {water, shore}}
+	meta.Mapping = terrainSource()
+	wet := map[int]bool{water: true, shore: true}
+	const b = 4
+	for seed := int64(0); seed < 5; seed++ {
+		result, err := Generate(meta, Options{Mode: ModeHierarchical, Width: 16, Height: 12, Block: b, Seed: seed, Attempts: 20})
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		checkTerrain(t, result.Mapping)
+
+		// Away from its edges a block holds the tiles of one terrain only.
+		for by := 0; by < 12/b; by++ {
+			for bx := 0; bx < 16/b; bx++ {
+				wetCells := 0
+				for y := by*b + 1; y < (by+1)*b-1; y++ {
+					for x := bx*b + 1; x < (bx+1)*b-1; x++ {
+						if wet[result.Mapping[y][x]] {
+							wetCells++
+						}
+					}
+				}
+				if wetCells != 0 && wetCells != (b-2)*(b-2) {
+					t.Fatalf("seed %d: block (%d, %d) mixes terrains inside:\n%v", seed, bx, by, result.Mapping)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// maxConflictSets bounds how many independent conflicts Inpaint reports.
+const maxConflictSets = 10
+
+// PinnedCell is a hand-placed tile in a sketch mapping.
+type PinnedCell struct {
+	X, Y int
+	ID   int
+}
+
+// ConflictError lists groups of pinned cells that cannot all hold their
+// tiles under the tileset adjacency. Each group is minimal: removing any one
+// of its cells makes the rest consistent or, for pins that only conflict
+// through cells further away, solvable within the attempt budget.
+type ConflictError struct {
+	Conflicts [][]PinnedCell
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d conflicting group(s) of pinned cells", len(e.Conflicts))
+	for _, group := range e.Conflicts {
+		b.WriteString("\n  -")
+		for _, c := range group {
+			fmt.Fprintf(&b, " (%d,%d)=%d", c.X, c.Y, c.ID)
+		}
+	}
+	return b.String()
+}
+
+// Inpaint fills every -1 cell of sketch while keeping all other cells pinned,
+// so that every neighbouring pair is allowed by the tileset adjacency. The
+// output size is taken from the sketch; opts.Width and opts.Height are
+// ignored. Only the simple model is supported. If the pinned cells
+// contradict each other, or leave no solution that the attempts can find, a
+// *ConflictError is returned.
+func Inpaint(meta *maputils.TilesetMetadata, sketch [][]int, opts Options) (*Result, error) {
+	if err := checkSimpleMode(opts, "inpainting"); err != nil {
+		return nil, err
+	}
+	height := len(sketch)
+	if height == 0 || len(sketch[0]) == 0 {
+		return nil, fmt.Errorf("sketch mapping is empty")
+	}
+	if err := checkOrthogonal(meta); err != nil {
+		return nil, err
+	}
+	width := len(sketch[0])
+
+	model, ids, err := SimpleModel(meta)
+	if err != nil {
+		return nil, err
+	}
+	stateByID := make(map[int]int, len(ids))
+	for s, id := range ids {
+		stateByID[id] = s
+	}
+
+	var pins []PinnedCell
+	for y, row := range sketch {
+		if len(row) != width {
+			return nil, fmt.Errorf("sketch row %d has %d cells, expected %d", y, len(row), width)
+		}
+		for x, id := range row {
+			if id < 0 {
+				continue
+			}
+			if _, ok := stateByID[id]; !ok {
+				return nil, fmt.Errorf("sketch cell (%d,%d) uses unknown tile ID %d", x, y, id)
+			}
+			pins = append(pins, PinnedCell{X: x, Y: y, ID: id})
+		}
+	}
+
+	solver := wfc.NewSolver(model, width, height, opts.Periodic)
+	consistent := func(set []PinnedCell) bool {
+		solver.ClearRestrictions()
+		for _, p := range set {
+			solver.Restrict(p.X, p.Y, []int{stateByID[p.ID]})
+		}
+		return solver.Check() == nil
+	}
+
+	if !consistent(pins) {
+		return nil, &ConflictError{Conflicts: findConflicts(pins, consistent)}
+	}
+
+	j, err := simpleJob(meta, ids, opts, width, height)
+	if err != nil {
+		return nil, err
+	}
+	result, err := solve(solver, opts, j)
+	var fail *FailureError
+	if err == nil || !errors.As(err, &fail) {
+		return result, err
+	}
+
+	// Propagation only catches pins that conflict through their
+	// neighbours. Retry with real solves, without the options' constraints,
+	// to tell whether the pins themselves leave no solution.
+	solver.Hook = nil
+	solver.Trace = false
+	solvable := func(set []PinnedCell) bool {
+		if !consistent(set) {
+			return false
+		}
+		_, err := solver.Solve(opts.Seed, opts.Attempts)
+		return err == nil
+	}
+	if len(pins) == 0 || solvable(pins) || !solvable(nil) {
+		return nil, err
+	}
+	return nil, &ConflictError{Conflicts: [][]PinnedCell{firstConflict(pins, solvable)}}
+}
+
+// findConflicts scans pins in order and, each time adding a pin makes the set
+// inconsistent, shrinks the pins before it to a minimal conflicting group.
+// The offending pin is then set aside and the scan continues.
+func findConflicts(pins []PinnedCell, consistent func([]PinnedCell) bool) [][]PinnedCell {
+	var conflicts [][]PinnedCell
+	var active []PinnedCell
+	for _, p := range pins {
+		trial := append(append([]PinnedCell{}, active...), p)
+		if consistent(trial) {
+			active = trial
+			continue
+		}
+		conflicts = append(conflicts, minimalConflict(active, p, consistent))
+		if len(conflicts) >= maxConflictSets {
+			break
+		}
+	}
+	return conflicts
+}
+
+// firstConflict finds, by binary search, the shortest prefix of pins that
+// pred rejects and shrinks it to a minimal conflicting group. Unlike
+// findConflicts it needs only a logarithmic number of calls to find the
+// group, which suits predicates that run whole solves. pred must accept no
+// pins and reject all of them.
+func firstConflict(pins []PinnedCell, pred func([]PinnedCell) bool) []PinnedCell {
+	lo, hi := 0, len(pins)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if pred(pins[:mid]) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return minimalConflict(pins[:hi-1], pins[hi-1], pred)
+}
+
+// minimalConflict removes chunks of candidates, halving the chunk size each
+// pass, as long as the remainder still conflicts with required.
+func minimalConflict(candidates []PinnedCell, required PinnedCell, consistent func([]PinnedCell) bool) []PinnedCell {
+	set := append([]PinnedCell{}, candidates...)
+	with := func(cells []PinnedCell) []PinnedCell {
+		return append(append([]PinnedCell{}, cells...), required)
+	}
+
+	chunk := len(set) / 2
+	if chunk < 1 {
+		chunk = 1
+	}
+	for ; chunk >= 1 && len(set) > 0; chunk /= 2 {
+		for i := 0; i < len(set); {
+			end := i + chunk
+			if end > len(set) {
+				end = len(set)
+			}
+			trial := append(append([]PinnedCell{}, set[:i]...), set[end:]...)
+			if !consistent(with(trial)) {
+				set = trial
+			} else {
+				i = end
+			}
+		}
+	}
+	return with(set)
+}
@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInpaintKeepsPins(t *testing.T) {
+	const u = -1
+	sketch := [][]int{
+		{u, u, u, u, u, u, u, grass},
+		{u, water, u, u, u, u, u, u},
+		{u, u, u, u, u, u, u, u},
+		{u, u, u, u, u, u, u, u},
+		{u, u, u, u, u, u, chest, u},
+		{shore, u, u, u, u, u, u, u},
+	}
+	for seed := int64(0); seed < 5; seed++ {
+		result, err := Inpaint(terrainTileset(), sketch, Options{Seed: seed, Attempts: 10})
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		checkTerrain(t, result.Mapping)
+		for y, row := range sketch {
+			for x, id := range row {
+				if got := result.Mapping[y][x]; id != u && got != id {
+					t.Fatalf("seed %d: pinned cell (%d, %d) holds %d, want %d", seed, x, y, got, id)
+				}
+				if result.Mapping[y][x] < 0 {
+					t.Fatalf("seed %d: cell (%d, %d) is not filled", seed, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestInpaintConflicts(t *testing.T) {
+	const u = -1
+	// Water cannot touch grass, and a chest cannot touch water. The other
+	// pins are fine and must not be reported.
+	sketch := [][]int{
+		{grass, u, u, u, u, u},
+		{u, water, grass, u, u, u},
+		{u, u, u, u, shore, u},
+		{u, u, grass, u, water, u},
+		{u, u, u, u, chest, u},
+	}
+	_, err := Inpaint(terrainTileset(), sketch, Options{Seed: 1, Attempts: 5})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Inpaint = %v, want a conflict", err)
+	}
+	want := [][]PinnedCell{
+		{{X: 1, Y: 1, ID: water}, {X: 2, Y: 1, ID: grass}},
+		{{X: 4, Y: 3, ID: water}, {X: 4, Y: 4, ID: chest}},
+	}
+	if !reflect.DeepEqual(conflict.Conflicts, want) {
+		t.Errorf("conflicts = %v, want %v", conflict.Conflicts, want)
+	}
+}
+
+func TestFirstConflict(t *testing.T) {
+	// Pins 2 and 5 clash with each other; no other pin matters.
+	var pins []PinnedCell
+	for i := 0; i < 8; i++ {
+		pins = append(pins, PinnedCell{X: i, ID: i})
+	}
+	calls := 0
+	pred := func(set []PinnedCell) bool {
+		calls++
+		has := map[int]bool{}
+		for _, p := range set {
+			has[p.ID] = true
+		}
+		return !(has[2] && has[5])
+	}
+	if got, want := firstConflict(pins, pred), []PinnedCell{pins[2], pins[5]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("firstConflict = %v, want %v", got, want)
+	}
+	if calls > 2*len(pins) {
+		t.Errorf("firstConflict made %d calls for %d pins", calls, len(pins))
+	}
+}
+
+func TestInpaintMode(t *testing.T) {
+	sketch := [][]int{{water, -1, grass}}
+	_, err := Inpaint(terrainTileset(), sketch, Options{Mode: ModeOverlapping, Attempts: 5})
+	if err == nil || !strings.Contains(err.Error(), `inpainting supports only the simple model, not "overlapping"`) {
+		t.Errorf("Inpaint error = %v, want the mode rejected", err)
+	}
+}
@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"fmt"
+
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/wfc"
+)
+
+// SimpleModel builds a simple-tiled WFC model from the adjacency sets stored
+// in a tileset. Each tile is weighted by its recorded frequency, or 1 for
+// tilesets trained before frequencies were stored. When the tileset recorded
+// diagonal neighbours the model constrains all eight directions. It returns
+// the model together with the tile ID of each state.
+func SimpleModel(meta *maputils.TilesetMetadata) (*wfc.Model, []int, error) {
+	stateByHash := make(map[string]int, len(meta.Tiles))
+	ids := make([]int, len(meta.Tiles))
+	for i, t := range meta.Tiles {
+		stateByHash[t.Hash] = i
+		ids[i] = t.ID
+	}
+
+	dirs := 4
+	for _, t := range meta.Tiles {
+		if t.Adjacency.HasDiagonals() {
+			dirs = 8
+			break
+		}
+	}
+
+	n := len(meta.Tiles)
+	m := &wfc.Model{
+		Weights:    make([]float64, n),
+		Propagator: make([][][]int, dirs),
+	}
+	for d := range m.Propagator {
+		m.Propagator[d] = make([][]int, n)
+	}
+
+	resolve := func(hashes []string) []int {
+		var out []int
+		for _, h := range hashes {
+			if s, ok := stateByHash[h]; ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+
+	for i, t := range meta.Tiles {
+		m.Weights[i] = 1
+		if t.Frequency > 0 {
+			m.Weights[i] = float64(t.Frequency)
+		}
+		m.Propagator[wfc.Up][i] = resolve(t.Adjacency.Top)
+		m.Propagator[wfc.Down][i] = resolve(t.Adjacency.Bottom)
+		m.Propagator[wfc.Left][i] = resolve(t.Adjacency.Left)
+		m.Propagator[wfc.Right][i] = resolve(t.Adjacency.Right)
+		if dirs == 8 {
+			m.Propagator[wfc.UpLeft][i] = resolve(t.Adjacency.TopLeft)
+			m.Propagator[wfc.UpRight][i] = resolve(t.Adjacency.TopRight)
+			m.Propagator[wfc.DownLeft][i] = resolve(t.Adjacency.BottomLeft)
+			m.Propagator[wfc.DownRight][i] = resolve(t.Adjacency.BottomRight)
+		}
+	}
+	m.Symmetrise()
+
+	for i := 0; i < n; i++ {
+		empty := true
+		for d := range m.Propagator {
+			if len(m.Propagator[d][i]) > 0 {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			return m, ids, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("tileset has no adjacency data")
+}
+
+// statesToIDs converts a grid of state indexes to tile IDs.
+func statesToIDs(states [][]int, ids []int) [][]int {
+	out := make([][]int, len(states))
+	for y, row := range states {
+		out[y] = make([]int, len(row))
+		for x, s := range row {
+			if s < 0 {
+				out[y][x] = -1
+				continue
+			}
+			out[y][x] = ids[s]
+		}
+	}
+	return out
+}
+
+// SampleModel learns a simple-tiled model directly from a grid of labels:
+// every pair of labels seen next to each other becomes allowed and each label
+// is weighted by how often it occurs. Negative cells are ignored. It returns
+// the model and the label of each state.
+func SampleModel(sample [][]int) (*wfc.Model, []int) {
+	stateByLabel := make(map[int]int)
+	var labels []int
+	var weights []float64
+	for _, row := range sample {
+		for _, l := range row {
+			if l < 0 {
+				continue
+			}
+			s, ok := stateByLabel[l]
+			if !ok {
+				s = len(labels)
+				stateByLabel[l] = s
+				labels = append(labels, l)
+				weights = append(weights, 0)
+			}
+			weights[s]++
+		}
+	}
+
+	m := &wfc.Model{Weights: weights, Propagator: make([][][]int, 4)}
+	seen := make([]map[[2]int]bool, 4)
+	for d := range m.Propagator {
+		m.Propagator[d] = make([][]int, len(labels))
+		seen[d] = make(map[[2]int]bool)
+	}
+	for y, row := range sample {
+		for x, l := range row {
+			if l < 0 {
+				continue
+			}
+			a := stateByLabel[l]
+			for d := range m.Propagator {
+				nx, ny := x+wfc.DX[d], y+wfc.DY[d]
+				if ny < 0 || ny >= len(sample) || nx < 0 || nx >= len(sample[ny]) || sample[ny][nx] < 0 {
+					continue
+				}
+				b := stateByLabel[sample[ny][nx]]
+				if !seen[d][[2]int{a, b}] {
+					seen[d][[2]int{a, b}] = true
+					m.Propagator[d][a] = append(m.Propagator[d][a], b)
+				}
+			}
+		}
+	}
+	m.Symmetrise()
+	return m, labels
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+)
+
+// diagonalTileset has two tiles that may meet in any cardinal direction
+// but only meet themselves diagonally, so every map is one tile or a
+// checkerboard of both.
+func diagonalTileset(diagonals bool) *maputils.TilesetMetadata {
+	both := []string{tileHash(water), tileHash(grass)}
+	meta := &maputils.TilesetMetadata{TileSize: 16}
+	for _, id := range []int{water, grass} {
+		adj := maputils.Adjacency{Top: both, Bottom: both, Left: both, Right: both}
+		if diagonals {
+			same := []string{tileHash(id)}
+			adj.TopLeft, adj.TopRight, adj.BottomLeft, adj.BottomRight = same, same, same, same
+		}
+		meta.Tiles = append(meta.Tiles, maputils.TilesetEntry{ID: id, Hash: tileHash(id), Adjacency: adj})
+	}
+	return meta
+}
+
+// diagonalBreaks counts the diagonal neighbours of mapping that differ.
+func diagonalBreaks(mapping [][]int) int {
+	n := 0
+	for y := 0; y+1 < len(mapping); y++ {
+		for x := range mapping[y] {
+			if x > 0 && mapping[y][x] != mapping[y+1][x-1] {
+				n++
+			}
+			if x+1 < len(mapping[y]) && mapping[y][x] != mapping[y+1][x+1] {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestSimpleModelDiagonals(t *testing.T) {
+	for _, tc := range []struct {
+		diagonals bool
+		dirs      int
+	}{{false, 4}, {true, 8}} {
+		m, _, err := SimpleModel(diagonalTileset(tc.diagonals))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Propagator) != tc.dirs {
+			t.Errorf("diagonals %v: model constrains %d directions, want %d", tc.diagonals, len(m.Propagator), tc.dirs)
+		}
+	}
+
+	cardinalBreaks := 0
+	for seed := int64(0); seed < 5; seed++ {
+		opts := Options{Width: 8, Height: 8, Seed: seed, Attempts: 10}
+		result, err := Generate(diagonalTileset(true), opts)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if n := diagonalBreaks(result.Mapping); n > 0 {
+			t.Errorf("seed %d: %d diagonal neighbours differ in %v", seed, n, result.Mapping)
+		}
+		result, err = Generate(diagonalTileset(false), opts)
+		if err != nil {
+			t.Fatalf("seed %d without diagonals: %v", seed, err)
+		}
+		cardinalBreaks += diagonalBreaks(result.Mapping)
+	}
+	// Without the diagonal rules nothing keeps the tiles apart.
+	if cardinalBreaks == 0 {
+		t.Error("maps without diagonal rules never mix tiles diagonally")
+	}
+}
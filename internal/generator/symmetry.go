@@ -0,0 +1,265 @@
+package generator
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"tilemap-generator/internal/maputils"
+	"tilemap-generator/internal/tileutils"
+	"tilemap-generator/internal/wfc"
+)
+
+// Symmetry kinds understood by NewSymmetry.
+const (
+	SymmetryMirrorH  = "mirror-h"
+	SymmetryMirrorV  = "mirror-v"
+	SymmetryMirrorHV = "mirror-hv"
+	SymmetryRot2     = "rot2"
+	SymmetryRot4     = "rot4"
+)
+
+// counterpartTolerance is the largest per-pixel channel difference (0-255)
+// at which a transformed tile image is taken to match another tile.
+const counterpartTolerance = 8.0
+
+// symmetryTransform maps a cell to its symmetric cell and tile images to
+// their symmetric counterparts.
+type symmetryTransform struct {
+	name  string
+	cell  func(x, y, w, h int) (int, int)
+	image func(image.Image) image.Image
+}
+
+var (
+	mirrorH = symmetryTransform{"horizontal mirror",
+		func(x, y, w, h int) (int, int) { return w - 1 - x, y },
+		func(img image.Image) image.Image { return imaging.FlipH(img) }}
+	mirrorV = symmetryTransform{"vertical mirror",
+		func(x, y, w, h int) (int, int) { return x, h - 1 - y },
+		func(img image.Image) image.Image { return imaging.FlipV(img) }}
+	rotate180 = symmetryTransform{"180° rotation",
+		func(x, y, w, h int) (int, int) { return w - 1 - x, h - 1 - y },
+		func(img image.Image) image.Image { return imaging.Rotate180(img) }}
+	rotate90 = symmetryTransform{"90° rotation",
+		func(x, y, w, h int) (int, int) { return w - 1 - y, x },
+		func(img image.Image) image.Image { return imaging.Rotate270(img) }}
+	rotate270 = symmetryTransform{"270° rotation",
+		func(x, y, w, h int) (int, int) { return y, h - 1 - x },
+		func(img image.Image) image.Image { return imaging.Rotate90(img) }}
+)
+
+// symmetryKinds lists the non-identity transforms of each symmetry group.
+var symmetryKinds = map[string][]symmetryTransform{
+	SymmetryMirrorH:  {mirrorH},
+	SymmetryMirrorV:  {mirrorV},
+	SymmetryMirrorHV: {mirrorH, mirrorV, rotate180},
+	SymmetryRot2:     {rotate180},
+	SymmetryRot4:     {rotate90, rotate180, rotate270},
+}
+
+// Symmetry makes generated maps mirror or rotationally symmetric. Every cell
+// is tied to its symmetric cells, which must hold the mirrored or rotated
+// counterpart of its tile.
+type Symmetry struct {
+	Kind string
+	// Counterparts holds, per transform of the kind, the counterpart of each
+	// tile ID.
+	Counterparts []map[int]int
+	// Missing lists tiles that lack a counterpart for at least one transform.
+	// They cannot appear in a symmetric map.
+	Missing []int
+
+	transforms []symmetryTransform
+}
+
+// NewSymmetry detects tile counterparts for a symmetry kind by transforming
+// the tile images and matching them against the set.
+func NewSymmetry(kind string, images map[int]image.Image) (*Symmetry, error) {
+	transforms, ok := symmetryKinds[kind]
+	if !ok {
+		names := make([]string, 0, len(symmetryKinds))
+		for k := range symmetryKinds {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown symmetry %q, expected one of %s", kind, strings.Join(names, ", "))
+	}
+	sym := &Symmetry{Kind: kind, transforms: transforms}
+	missing := make(map[int]bool)
+	for _, t := range transforms {
+		if t.name == rotate90.name || t.name == rotate270.name {
+			for _, img := range images {
+				if b := img.Bounds(); b.Dx() != b.Dy() {
+					return nil, fmt.Errorf("%s symmetry needs square tiles, got %dx%dpx", kind, b.Dx(), b.Dy())
+				}
+			}
+		}
+		cp := tileutils.FindCounterparts(images, t.image, counterpartTolerance)
+		for id := range images {
+			if _, ok := cp[id]; !ok {
+				missing[id] = true
+			}
+		}
+		sym.Counterparts = append(sym.Counterparts, cp)
+	}
+	for id := range missing {
+		sym.Missing = append(sym.Missing, id)
+	}
+	sort.Ints(sym.Missing)
+	if len(sym.Missing) == len(images) {
+		return nil, fmt.Errorf("no tile has a %s counterpart", kind)
+	}
+	return sym, nil
+}
+
+// PatternCounterparts detects, for each rotated or reflected variant of an
+// overlapping pattern (wfc.Variants), the counterpart of every tile. Entry
+// v-1 belongs to variant v. It is what Options.Counterparts expects.
+func PatternCounterparts(images map[int]image.Image) []map[int]int {
+	out := make([]map[int]int, 0, wfc.Variants-1)
+	for v := 1; v < wfc.Variants; v++ {
+		transform := func(img image.Image) image.Image {
+			for i := 0; i < v/2; i++ {
+				img = rotate90.image(img)
+			}
+			if v%2 == 1 {
+				img = mirrorH.image(img)
+			}
+			return img
+		}
+		out = append(out, tileutils.FindCounterparts(images, transform, counterpartTolerance))
+	}
+	return out
+}
+
+// symmetrySet is a Symmetry compiled for one solver grid.
+type symmetrySet struct {
+	// cells[g][c] is the cell transform g maps cell c to.
+	cells [][]int
+	// states[g][s] is the counterpart state of s under g, or -1.
+	states [][]int
+	// seen holds each cell's remaining state count when it was last synced.
+	seen []int
+	keep []bool
+}
+
+// compile resolves the symmetry for a width x height grid of simple-model
+// states of meta's tiles. Quarter turns need both the map and its tiles to
+// be square.
+func (sym *Symmetry) compile(meta *maputils.TilesetMetadata, ids []int, width, height int) (*symmetrySet, error) {
+	tw, th := meta.TileDims()
+	for _, t := range sym.transforms {
+		if t.name != rotate90.name && t.name != rotate270.name {
+			continue
+		}
+		if width != height {
+			return nil, fmt.Errorf("%s symmetry needs a square map, got %dx%d", sym.Kind, width, height)
+		}
+		if tw != th {
+			return nil, fmt.Errorf("%s symmetry needs square tiles, got %dx%dpx", sym.Kind, tw, th)
+		}
+	}
+	stateByID := make(map[int]int, len(ids))
+	for s, id := range ids {
+		stateByID[id] = s
+	}
+	ss := &symmetrySet{seen: make([]int, width*height), keep: make([]bool, len(ids))}
+	for g, t := range sym.transforms {
+		cells := make([]int, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				nx, ny := t.cell(x, y, width, height)
+				cells[y*width+x] = ny*width + nx
+			}
+		}
+		states := make([]int, len(ids))
+		for s, id := range ids {
+			states[s] = -1
+			if cp, ok := sym.Counterparts[g][id]; ok {
+				if cs, ok := stateByID[cp]; ok {
+					states[s] = cs
+				}
+			}
+		}
+		ss.cells = append(ss.cells, cells)
+		ss.states = append(ss.states, states)
+	}
+	return ss, nil
+}
+
+// restrict bans tiles without counterparts everywhere and limits cells that
+// a transform maps onto themselves to tiles that are their own counterpart.
+func (ss *symmetrySet) restrict(solver *wfc.Solver) {
+	n := len(ss.states[0])
+	for c := 0; c < solver.Cells(); c++ {
+		var states []int
+		for s := 0; s < n; s++ {
+			ok := true
+			for g := range ss.states {
+				cs := ss.states[g][s]
+				if cs < 0 || (ss.cells[g][c] == c && cs != s) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				states = append(states, s)
+			}
+		}
+		if len(states) < n {
+			restrictMore(solver, c%solver.Width, c/solver.Width, states)
+		}
+	}
+}
+
+// reset marks every cell as needing a sync at the start of an attempt.
+func (ss *symmetrySet) reset() {
+	for i := range ss.seen {
+		ss.seen[i] = -1
+	}
+}
+
+// hook keeps symmetric cells in step: whenever a cell loses a state, its
+// symmetric cells lose every state that is no longer the counterpart of one
+// still possible in the cell.
+func (ss *symmetrySet) hook(s *wfc.Solver) error {
+	for {
+		changed := false
+		for c := range ss.seen {
+			r := s.Remaining(c)
+			if r == ss.seen[c] {
+				continue
+			}
+			ss.seen[c] = r
+			for g := range ss.cells {
+				to := ss.cells[g][c]
+				if to == c {
+					continue
+				}
+				for i := range ss.keep {
+					ss.keep[i] = false
+				}
+				for st, cs := range ss.states[g] {
+					if cs >= 0 && s.Allowed(c, st) {
+						ss.keep[cs] = true
+					}
+				}
+				for cs, ok := range ss.keep {
+					if !ok && s.Allowed(to, cs) {
+						s.Ban(to, cs)
+						changed = true
+					}
+				}
+			}
+		}
+		if !changed {
+			return nil
+		}
+		if !s.Propagate() {
+			return s.Err()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package generator
+
+import (
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+)
+
+// freeTileset returns a tileset of 4px tiles in which every tile may meet
+// every other in all four directions.
+func freeTileset(ids []int) *maputils.TilesetMetadata {
+	var hashes []string
+	for _, id := range ids {
+		hashes = append(hashes, tileHash(id))
+	}
+	meta := &maputils.TilesetMetadata{TileSize: 4}
+	for _, id := range ids {
+		meta.Tiles = append(meta.Tiles, maputils.TilesetEntry{
+			ID:        id,
+			Hash:      tileHash(id),
+			Adjacency: maputils.Adjacency{Top: hashes, Bottom: hashes, Left: hashes, Right: hashes},
+		})
+	}
+	return meta
+}
+
+func TestNewSymmetry(t *testing.T) {
+	// Dots in the top corners mirror into each other, the plain tile into
+	// itself, and a dot left of the middle has no mirrored tile. A dot off
+	// the tile leaves it plain.
+	images := map[int]image.Image{1: dotTile(0, 0), 2: dotTile(3, 0), 3: dotTile(-1, -1), 4: dotTile(1, 2)}
+	sym, err := NewSymmetry(SymmetryMirrorH, images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []map[int]int{{1: 2, 2: 1, 3: 3}}; !reflect.DeepEqual(sym.Counterparts, want) {
+		t.Errorf("counterparts = %v, want %v", sym.Counterparts, want)
+	}
+	if want := []int{4}; !reflect.DeepEqual(sym.Missing, want) {
+		t.Errorf("missing = %v, want %v", sym.Missing, want)
+	}
+
+	if _, err := NewSymmetry("mirror-d", images); err == nil || !strings.Contains(err.Error(), `unknown symmetry "mirror-d"`) {
+		t.Errorf("NewSymmetry error = %v, want unknown symmetry", err)
+	}
+	wide := map[int]image.Image{1: image.NewRGBA(image.Rect(0, 0, 4, 2))}
+	if _, err := NewSymmetry(SymmetryRot4, wide); err == nil || !strings.Contains(err.Error(), "needs square tiles") {
+		t.Errorf("NewSymmetry error = %v, want square tiles", err)
+	}
+}
+
+func TestSymmetryGenerate(t *testing.T) {
+	// Dots in each corner turn into one another, the plain tile into itself.
+	images := map[int]image.Image{
+		1: dotTile(0, 0), 2: dotTile(3, 0), 3: dotTile(3, 3), 4: dotTile(0, 3),
+		5: dotTile(-1, -1), 6: dotTile(1, 2),
+	}
+	tests := []struct {
+		kind          string
+		width, height int
+	}{
+		{SymmetryMirrorH, 7, 5},
+		{SymmetryMirrorHV, 6, 7},
+		{SymmetryRot2, 5, 6},
+		{SymmetryRot4, 7, 7},
+	}
+	for _, tc := range tests {
+		t.Run(tc.kind, func(t *testing.T) {
+			sym, err := NewSymmetry(tc.kind, images)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dots := 0
+			for seed := int64(0); seed < 5; seed++ {
+				opts := Options{Width: tc.width, Height: tc.height, Seed: seed, Attempts: 10, Symmetry: sym}
+				result, err := Generate(freeTileset([]int{1, 2, 3, 4, 5, 6}), opts)
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				m := result.Mapping
+				for y, row := range m {
+					for x, id := range row {
+						if id == 6 {
+							t.Fatalf("seed %d: tile 6 without counterparts at (%d, %d)", seed, x, y)
+						}
+						if id != 5 {
+							dots++
+						}
+						for g, tr := range sym.transforms {
+							nx, ny := tr.cell(x, y, tc.width, tc.height)
+							if want := sym.Counterparts[g][id]; m[ny][nx] != want {
+								t.Fatalf("seed %d: %s takes tile %d at (%d, %d) to %d at (%d, %d), want %d",
+									seed, tr.name, id, x, y, m[ny][nx], nx, ny, want)
+							}
+						}
+					}
+				}
+			}
+			if dots == 0 {
+				t.Error("only the plain tile was placed")
+			}
+		})
+	}
+
+	sym, err := NewSymmetry(SymmetryRot4, images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Generate(freeTileset([]int{1, 2, 3, 4, 5, 6}), Options{Width: 6, Height: 5, Symmetry: sym})
+	if err == nil || !strings.Contains(err.Error(), "needs a square map") {
+		t.Errorf("Generate error = %v, want square map", err)
+	}
+}
@@ -17,3 +17,12 @@ func LoadAndPreprocess(path string) (image.Image, image.Image, error) {
 	cleaned := PreprocessForTraining(img)
 	return img, cleaned, nil
 }
+
+// LoadImage opens an image from disk without any preprocessing.
+func LoadImage(path string) (image.Image, error) {
+	img, err := imaging.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	return img, nil
+}
@@ -2,32 +2,78 @@ package maputils
 
 import "sort"
 
-// Adjacency lists the neighboring tile hashes in the four cardinal directions.
+// Adjacency lists the neighbouring tile hashes in the four cardinal
+// directions and, for tilesets trained with diagonals, the four diagonal
+// directions. Readers that only know the cardinal directions ignore the
+// diagonal keys.
 type Adjacency struct {
 	Top    []string `json:"top"`
 	Bottom []string `json:"bottom"`
 	Left   []string `json:"left"`
 	Right  []string `json:"right"`
+
+	TopLeft     []string `json:"topLeft,omitempty"`
+	TopRight    []string `json:"topRight,omitempty"`
+	BottomLeft  []string `json:"bottomLeft,omitempty"`
+	BottomRight []string `json:"bottomRight,omitempty"`
+}
+
+// HasDiagonals reports whether any diagonal neighbours were recorded.
+func (a Adjacency) HasDiagonals() bool {
+	return len(a.TopLeft)+len(a.TopRight)+len(a.BottomLeft)+len(a.BottomRight) > 0
 }
 
+// Neighbour offsets in the order top, bottom, left, right, top-left,
+// top-right, bottom-left, bottom-right.
+var (
+	neighbourDX = []int{0, 0, -1, 1, -1, 1, -1, 1}
+	neighbourDY = []int{-1, 1, 0, 0, -1, -1, 1, 1}
+)
+
+// isoNeighbourDX and isoNeighbourDY give, in the same order, where the
+// neighbours of an isometric cell lie in its mapping. Diamonds share edges
+// only with their diagonal neighbours on screen: top-left is one step back
+// along the mapping's x axis, top-right one step back along y.
+var (
+	isoNeighbourDX = []int{0, 0, 0, 0, -1, 0, 0, 1}
+	isoNeighbourDY = []int{0, 0, 0, 0, 0, -1, 1, 0}
+)
+
 // BuildAdjacency returns, for each tile ID, the hashes of neighbouring tiles in
-// each direction based on the provided mapping grid.
+// each cardinal direction based on the provided mapping grid.
 func BuildAdjacency(tiles []Tile, mapping [][]int) map[int]Adjacency {
+	return buildAdjacency(tiles, mapping, neighbourDX, neighbourDY, 0, 4)
+}
+
+// BuildAdjacencyWithDiagonals is BuildAdjacency but also records the four
+// diagonal neighbours, so corner-sensitive tiles such as inner shoreline
+// corners only meet the tiles they met in the source.
+func BuildAdjacencyWithDiagonals(tiles []Tile, mapping [][]int) map[int]Adjacency {
+	return buildAdjacency(tiles, mapping, neighbourDX, neighbourDY, 0, 8)
+}
+
+// BuildIsometricAdjacency records, for a mapping in isometric coordinates,
+// the neighbours along the four edges of each diamond under the diagonal
+// keys, named for their direction on screen. The cardinal keys stay empty.
+func BuildIsometricAdjacency(tiles []Tile, mapping [][]int) map[int]Adjacency {
+	return buildAdjacency(tiles, mapping, isoNeighbourDX, isoNeighbourDY, 4, 8)
+}
+
+// buildAdjacency records the neighbours in directions [from, to) of the
+// order top, bottom, left, right, top-left, top-right, bottom-left,
+// bottom-right, found at the mapping offsets dx, dy.
+func buildAdjacency(tiles []Tile, mapping [][]int, dx, dy []int, from, to int) map[int]Adjacency {
 	hashByID := make(map[int]string)
 	for _, t := range tiles {
 		hashByID[t.ID] = t.Hash
 	}
-	type builder struct {
-		top, bottom, left, right map[string]struct{}
-	}
-	builders := make(map[int]*builder)
+	builders := make(map[int][]map[string]struct{})
 	for _, t := range tiles {
-		builders[t.ID] = &builder{
-			top:    map[string]struct{}{},
-			bottom: map[string]struct{}{},
-			left:   map[string]struct{}{},
-			right:  map[string]struct{}{},
+		sets := make([]map[string]struct{}, 8)
+		for d := from; d < to; d++ {
+			sets[d] = map[string]struct{}{}
 		}
+		builders[t.ID] = sets
 	}
 	rows := len(mapping)
 	if rows == 0 {
@@ -36,30 +82,37 @@ func BuildAdjacency(tiles []Tile, mapping [][]int) map[int]Adjacency {
 	cols := len(mapping[0])
 	for y := 0; y < rows; y++ {
 		for x := 0; x < cols; x++ {
-			id := mapping[y][x]
-			b := builders[id]
-			if y > 0 {
-				b.top[hashByID[mapping[y-1][x]]] = struct{}{}
+			sets, ok := builders[mapping[y][x]]
+			if !ok {
+				continue
 			}
-			if y < rows-1 {
-				b.bottom[hashByID[mapping[y+1][x]]] = struct{}{}
-			}
-			if x > 0 {
-				b.left[hashByID[mapping[y][x-1]]] = struct{}{}
-			}
-			if x < cols-1 {
-				b.right[hashByID[mapping[y][x+1]]] = struct{}{}
+			for d := from; d < to; d++ {
+				nx, ny := x+dx[d], y+dy[d]
+				if nx < 0 || nx >= cols || ny < 0 || ny >= rows {
+					continue
+				}
+				if h, ok := hashByID[mapping[ny][nx]]; ok {
+					sets[d][h] = struct{}{}
+				}
 			}
 		}
 	}
 	res := make(map[int]Adjacency)
-	for id, b := range builders {
-		res[id] = Adjacency{
-			Top:    sortedKeys(b.top),
-			Bottom: sortedKeys(b.bottom),
-			Left:   sortedKeys(b.left),
-			Right:  sortedKeys(b.right),
+	for id, sets := range builders {
+		var a Adjacency
+		if from == 0 {
+			a.Top = sortedKeys(sets[0])
+			a.Bottom = sortedKeys(sets[1])
+			a.Left = sortedKeys(sets[2])
+			a.Right = sortedKeys(sets[3])
+		}
+		if to == 8 {
+			a.TopLeft = sortedKeys(sets[4])
+			a.TopRight = sortedKeys(sets[5])
+			a.BottomLeft = sortedKeys(sets[6])
+			a.BottomRight = sortedKeys(sets[7])
 		}
+		res[id] = a
 	}
 	return res
 }
@@ -72,3 +125,139 @@ func sortedKeys(m map[string]struct{}) []string {
 	sort.Strings(out)
 	return out
 }
+
+// NeighbourCount records how often a tile was seen in one direction of
+// another tile, and that count as a share of all neighbours in the direction.
+type NeighbourCount struct {
+	ID          int     `json:"id"`
+	Hash        string  `json:"hash"`
+	Count       int     `json:"count"`
+	Probability float64 `json:"probability"`
+}
+
+// WeightedAdjacency lists neighbour counts in the four cardinal directions
+// and, for tilesets trained with diagonals, the four diagonal directions,
+// ordered by tile ID.
+type WeightedAdjacency struct {
+	Top    []NeighbourCount `json:"top"`
+	Bottom []NeighbourCount `json:"bottom"`
+	Left   []NeighbourCount `json:"left"`
+	Right  []NeighbourCount `json:"right"`
+
+	TopLeft     []NeighbourCount `json:"topLeft,omitempty"`
+	TopRight    []NeighbourCount `json:"topRight,omitempty"`
+	BottomLeft  []NeighbourCount `json:"bottomLeft,omitempty"`
+	BottomRight []NeighbourCount `json:"bottomRight,omitempty"`
+}
+
+// TileFrequencies counts how many cells of mapping hold each tile ID.
+// Empty cells (-1) are not counted.
+func TileFrequencies(mapping [][]int) map[int]int {
+	freq := make(map[int]int)
+	for _, row := range mapping {
+		for _, id := range row {
+			if id >= 0 {
+				freq[id]++
+			}
+		}
+	}
+	return freq
+}
+
+// BuildWeightedAdjacency returns, for each tile ID, how often every other
+// tile occurs next to it in each cardinal direction of the mapping grid.
+// Empty cells (-1) are skipped.
+func BuildWeightedAdjacency(tiles []Tile, mapping [][]int) map[int]WeightedAdjacency {
+	return buildWeightedAdjacency(tiles, mapping, neighbourDX, neighbourDY, 0, 4)
+}
+
+// BuildWeightedAdjacencyWithDiagonals is BuildWeightedAdjacency but also
+// counts the four diagonal neighbours, matching
+// BuildAdjacencyWithDiagonals.
+func BuildWeightedAdjacencyWithDiagonals(tiles []Tile, mapping [][]int) map[int]WeightedAdjacency {
+	return buildWeightedAdjacency(tiles, mapping, neighbourDX, neighbourDY, 0, 8)
+}
+
+// BuildIsometricWeightedAdjacency counts, for a mapping in isometric
+// coordinates, the neighbours along the four edges of each diamond under
+// the diagonal keys, matching BuildIsometricAdjacency.
+func BuildIsometricWeightedAdjacency(tiles []Tile, mapping [][]int) map[int]WeightedAdjacency {
+	return buildWeightedAdjacency(tiles, mapping, isoNeighbourDX, isoNeighbourDY, 4, 8)
+}
+
+// buildWeightedAdjacency counts the neighbours in directions [from, to) as
+// buildAdjacency records them.
+func buildWeightedAdjacency(tiles []Tile, mapping [][]int, dx, dy []int, from, to int) map[int]WeightedAdjacency {
+	hashByID := make(map[int]string)
+	for _, t := range tiles {
+		hashByID[t.ID] = t.Hash
+	}
+	counters := make(map[int][]map[int]int)
+	for _, t := range tiles {
+		counts := make([]map[int]int, 8)
+		for d := from; d < to; d++ {
+			counts[d] = map[int]int{}
+		}
+		counters[t.ID] = counts
+	}
+	rows := len(mapping)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(mapping[0])
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			counts, ok := counters[mapping[y][x]]
+			if !ok {
+				continue
+			}
+			for d := from; d < to; d++ {
+				nx, ny := x+dx[d], y+dy[d]
+				if nx < 0 || nx >= cols || ny < 0 || ny >= rows {
+					continue
+				}
+				if id := mapping[ny][nx]; id >= 0 {
+					counts[d][id]++
+				}
+			}
+		}
+	}
+	res := make(map[int]WeightedAdjacency)
+	for id, counts := range counters {
+		var w WeightedAdjacency
+		if from == 0 {
+			w.Top = neighbourCounts(counts[0], hashByID)
+			w.Bottom = neighbourCounts(counts[1], hashByID)
+			w.Left = neighbourCounts(counts[2], hashByID)
+			w.Right = neighbourCounts(counts[3], hashByID)
+		}
+		if to == 8 {
+			w.TopLeft = neighbourCounts(counts[4], hashByID)
+			w.TopRight = neighbourCounts(counts[5], hashByID)
+			w.BottomLeft = neighbourCounts(counts[6], hashByID)
+			w.BottomRight = neighbourCounts(counts[7], hashByID)
+		}
+		res[id] = w
+	}
+	return res
+}
+
+func neighbourCounts(counts map[int]int, hashByID map[int]string) []NeighbourCount {
+	total := 0
+	ids := make([]int, 0, len(counts))
+	for id, n := range counts {
+		ids = append(ids, id)
+		total += n
+	}
+	sort.Ints(ids)
+	out := make([]NeighbourCount, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, NeighbourCount{
+			ID:          id,
+			Hash:        hashByID[id],
+			Count:       counts[id],
+			Probability: float64(counts[id]) / float64(total),
+		})
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package maputils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildAdjacencyWithDiagonals(t *testing.T) {
+	// Every tile is unique, so each neighbour set names the single tile
+	// found in that direction.
+	mapping := [][]int{
+		{0, 1, 2},
+		{3, 4, 5},
+		{6, 7, 8},
+	}
+	var tiles []Tile
+	for id := 0; id < 9; id++ {
+		tiles = append(tiles, Tile{ID: id, Hash: string(rune('a' + id))})
+	}
+
+	cardinal := BuildAdjacency(tiles, mapping)
+	if cardinal[4].HasDiagonals() {
+		t.Errorf("BuildAdjacency recorded diagonals %+v", cardinal[4])
+	}
+
+	adj := BuildAdjacencyWithDiagonals(tiles, mapping)
+	want := Adjacency{
+		Top: []string{"b"}, Bottom: []string{"h"}, Left: []string{"d"}, Right: []string{"f"},
+		TopLeft: []string{"a"}, TopRight: []string{"c"}, BottomLeft: []string{"g"}, BottomRight: []string{"i"},
+	}
+	if !reflect.DeepEqual(adj[4], want) {
+		t.Errorf("centre adjacency = %+v, want %+v", adj[4], want)
+	}
+	// The top-left corner has a single diagonal neighbour.
+	corner := adj[0]
+	if len(corner.TopLeft)+len(corner.TopRight)+len(corner.BottomLeft) != 0 || !reflect.DeepEqual(corner.BottomRight, []string{"e"}) {
+		t.Errorf("corner adjacency = %+v, want only e below right", corner)
+	}
+
+	weighted := BuildWeightedAdjacencyWithDiagonals(tiles, mapping)
+	if got := weighted[4].BottomRight; len(got) != 1 || got[0].ID != 8 || got[0].Count != 1 || got[0].Probability != 1 {
+		t.Errorf("weighted bottom-right of the centre = %+v, want tile 8 once", got)
+	}
+}
@@ -0,0 +1,175 @@
+package maputils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Equivalence maps the tile IDs of one tileset to those of another tileset
+// drawn in a different style, so a layout made with one can be rendered with
+// the other.
+type Equivalence struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Tiles maps each tile ID of From to the tile ID of To used in its
+	// place. Edit this to correct suggestions.
+	Tiles map[int]int `json:"tiles"`
+	// Candidates lists, for each From tile, the To tiles seen at the same
+	// positions of the aligned source maps, most frequent first. It is only
+	// informational.
+	Candidates map[int][]EquivalenceCandidate `json:"candidates,omitempty"`
+}
+
+// EquivalenceCandidate is a To tile seen in place of a From tile.
+type EquivalenceCandidate struct {
+	ID    int     `json:"id"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// SuggestEquivalence pairs the tiles of two tilesets trained from aligned
+// source maps: every From tile is mapped to the To tile found most often at
+// the same grid positions. Only the area both mappings cover is compared.
+func SuggestEquivalence(from, to *TilesetMetadata, fromName, toName string) (*Equivalence, error) {
+	if len(from.Mapping) == 0 || len(to.Mapping) == 0 {
+		return nil, fmt.Errorf("both tilesets need a mapping to suggest equivalences")
+	}
+	counts := make(map[int]map[int]int)
+	rows := min(len(from.Mapping), len(to.Mapping))
+	for y := 0; y < rows; y++ {
+		cols := min(len(from.Mapping[y]), len(to.Mapping[y]))
+		for x := 0; x < cols; x++ {
+			a, b := from.Mapping[y][x], to.Mapping[y][x]
+			if a < 0 || b < 0 {
+				continue
+			}
+			if counts[a] == nil {
+				counts[a] = make(map[int]int)
+			}
+			counts[a][b]++
+		}
+	}
+
+	eq := &Equivalence{
+		From:       fromName,
+		To:         toName,
+		Tiles:      make(map[int]int),
+		Candidates: make(map[int][]EquivalenceCandidate),
+	}
+	for a, seen := range counts {
+		total := 0
+		var list []EquivalenceCandidate
+		for b, n := range seen {
+			list = append(list, EquivalenceCandidate{ID: b, Count: n})
+			total += n
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			return list[i].ID < list[j].ID
+		})
+		for i := range list {
+			list[i].Share = float64(list[i].Count) / float64(total)
+		}
+		eq.Tiles[a] = list[0].ID
+		eq.Candidates[a] = list
+	}
+	return eq, nil
+}
+
+// Apply converts a mapping of From tile IDs to To tile IDs. Cells whose tile
+// has no equivalent become -1; their distinct tile IDs are returned sorted.
+func (e *Equivalence) Apply(mapping [][]int) ([][]int, []int) {
+	missing := make(map[int]bool)
+	out := make([][]int, len(mapping))
+	for y, row := range mapping {
+		out[y] = make([]int, len(row))
+		for x, id := range row {
+			out[y][x] = -1
+			if id < 0 {
+				continue
+			}
+			if b, ok := e.Tiles[id]; ok {
+				out[y][x] = b
+			} else {
+				missing[id] = true
+			}
+		}
+	}
+	ids := make([]int, 0, len(missing))
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return out, ids
+}
+
+// Merge adds the pairs of s for tiles e does not map yet, so hand edits
+// to e survive a new suggestion, and takes the candidates of s. It returns
+// the added tile IDs, sorted.
+func (e *Equivalence) Merge(s *Equivalence) []int {
+	if e.Tiles == nil {
+		e.Tiles = make(map[int]int)
+	}
+	var added []int
+	for a, b := range s.Tiles {
+		if _, ok := e.Tiles[a]; !ok {
+			e.Tiles[a] = b
+			added = append(added, a)
+		}
+	}
+	sort.Ints(added)
+	e.Candidates = s.Candidates
+	return added
+}
+
+// Validate checks that every tile the table maps to exists in the target
+// tileset.
+func (e *Equivalence) Validate(to *TilesetMetadata) error {
+	known := make(map[int]bool, len(to.Tiles))
+	for _, t := range to.Tiles {
+		known[t.ID] = true
+	}
+	from := make([]int, 0, len(e.Tiles))
+	for a, b := range e.Tiles {
+		if !known[b] {
+			from = append(from, a)
+		}
+	}
+	if len(from) == 0 {
+		return nil
+	}
+	sort.Ints(from)
+	pairs := make([]string, len(from))
+	for i, a := range from {
+		pairs[i] = fmt.Sprintf("%d -> %d", a, e.Tiles[a])
+	}
+	return fmt.Errorf("equivalence table maps to tiles that tileset %q does not have: %s", e.To, strings.Join(pairs, ", "))
+}
+
+// SaveEquivalence writes an equivalence table as indented JSON so it is easy
+// to edit by hand.
+func SaveEquivalence(path string, e *Equivalence) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadEquivalence reads an equivalence table.
+func LoadEquivalence(path string) (*Equivalence, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read equivalence table: %w", err)
+	}
+	var e Equivalence
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode equivalence table: %w", err)
+	}
+	return &e, nil
+}
@@ -0,0 +1,87 @@
+package maputils
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSuggestEquivalence(t *testing.T) {
+	// Tile 2 is drawn as 21 twice and as 22 once; the extra To column and
+	// the empty cell are not compared.
+	from := &TilesetMetadata{Mapping: [][]int{
+		{1, 2, 2},
+		{1, 2, -1},
+	}}
+	to := &TilesetMetadata{Mapping: [][]int{
+		{11, 21, 22, 30},
+		{11, 21, 23, 30},
+	}}
+	eq, err := SuggestEquivalence(from, to, "summer", "winter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]int{1: 11, 2: 21}; !reflect.DeepEqual(eq.Tiles, want) {
+		t.Errorf("tiles = %v, want %v", eq.Tiles, want)
+	}
+	want := []EquivalenceCandidate{{ID: 21, Count: 2, Share: 2.0 / 3}, {ID: 22, Count: 1, Share: 1.0 / 3}}
+	if !reflect.DeepEqual(eq.Candidates[2], want) {
+		t.Errorf("candidates of tile 2 = %+v, want %+v", eq.Candidates[2], want)
+	}
+
+	mapping, missing := eq.Apply([][]int{{1, 2}, {3, -1}})
+	if want := [][]int{{11, 21}, {-1, -1}}; !reflect.DeepEqual(mapping, want) {
+		t.Errorf("Apply = %v, want %v", mapping, want)
+	}
+	if want := []int{3}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "eq.json")
+	if err := SaveEquivalence(path, eq); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadEquivalence(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, eq) {
+		t.Errorf("loaded %+v, want %+v", loaded, eq)
+	}
+
+	if _, err := SuggestEquivalence(&TilesetMetadata{}, to, "a", "b"); err == nil {
+		t.Error("SuggestEquivalence without a From mapping succeeded")
+	}
+}
+
+func TestEquivalenceMergeAndValidate(t *testing.T) {
+	// Tile 1 was corrected by hand and tile 2 added by hand; the new
+	// suggestion only contributes tile 3.
+	edited := &Equivalence{From: "summer", To: "winter", Tiles: map[int]int{1: 12, 2: 21}}
+	suggested := &Equivalence{
+		From:       "summer",
+		To:         "winter",
+		Tiles:      map[int]int{1: 11, 3: 31},
+		Candidates: map[int][]EquivalenceCandidate{1: {{ID: 11, Count: 4, Share: 1}}},
+	}
+	added := edited.Merge(suggested)
+	if want := []int{3}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added %v, want %v", added, want)
+	}
+	if want := map[int]int{1: 12, 2: 21, 3: 31}; !reflect.DeepEqual(edited.Tiles, want) {
+		t.Errorf("merged tiles = %v, want %v", edited.Tiles, want)
+	}
+	if !reflect.DeepEqual(edited.Candidates, suggested.Candidates) {
+		t.Errorf("merged candidates = %v, want the suggestion's", edited.Candidates)
+	}
+
+	winter := &TilesetMetadata{Tiles: []TilesetEntry{{ID: 11}, {ID: 12}, {ID: 31}}}
+	err := edited.Validate(winter)
+	if err == nil || err.Error() != `equivalence table maps to tiles that tileset "winter" does not have: 2 -> 21` {
+		t.Errorf("Validate = %v, want tile 21 reported", err)
+	}
+	winter.Tiles = append(winter.Tiles, TilesetEntry{ID: 21})
+	if err := edited.Validate(winter); err != nil {
+		t.Errorf("Validate = %v, want every tile found", err)
+	}
+}
@@ -0,0 +1,269 @@
+package maputils
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// EdgePolicy decides what happens to the parts of an image that do not fill
+// a whole tile: the remainder at the right and bottom edges, and the strip
+// before a grid offset.
+type EdgePolicy string
+
+// Edge policies understood by Grid. The zero value behaves as EdgeDrop.
+const (
+	// EdgeDrop leaves partial tiles out of the grid.
+	EdgeDrop EdgePolicy = "drop"
+	// EdgePadTransparent keeps partial tiles, padded to full size with
+	// transparent pixels.
+	EdgePadTransparent EdgePolicy = "pad-transparent"
+	// EdgePadEdge keeps partial tiles, padded to full size by repeating
+	// the image's edge pixels.
+	EdgePadEdge EdgePolicy = "pad-edge"
+	// EdgePartial keeps partial tiles at their smaller size.
+	EdgePartial EdgePolicy = "partial"
+)
+
+// ParseEdgePolicy validates an edge policy name. An empty name is EdgeDrop.
+func ParseEdgePolicy(s string) (EdgePolicy, error) {
+	switch p := EdgePolicy(s); p {
+	case "":
+		return EdgeDrop, nil
+	case EdgeDrop, EdgePadTransparent, EdgePadEdge, EdgePartial:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown edge policy %q (want drop, pad-transparent, pad-edge or partial)", s)
+	}
+}
+
+// Orientation is the shape of a grid's tiles.
+type Orientation string
+
+// Orientations understood by Grid. The zero value behaves as Orthogonal.
+const (
+	// Orthogonal grids cut rectangular tiles in rows and columns.
+	Orthogonal Orientation = "orthogonal"
+	// Isometric grids cut diamond tiles; see Grid.
+	Isometric Orientation = "isometric"
+)
+
+// ParseOrientation validates an orientation name. An empty name is
+// Orthogonal.
+func ParseOrientation(s string) (Orientation, error) {
+	switch o := Orientation(s); o {
+	case "":
+		return Orthogonal, nil
+	case Orthogonal, Isometric:
+		return o, nil
+	default:
+		return "", fmt.Errorf("unknown grid orientation %q (want orthogonal or isometric)", s)
+	}
+}
+
+// Grid describes how an image is cut into tiles: tiles of TileWidth x
+// TileHeight pixels whose first row and column start at (OffsetX, OffsetY).
+// Edge selects how partial tiles at the image edges are treated; by default
+// they are dropped.
+//
+// Margin and Spacing describe spritesheets: Margin pixels of border before
+// the first tile on both axes and Spacing pixels of gutter between
+// neighbouring tiles.
+//
+// An Isometric grid instead cuts diamonds inscribed in TileWidth x
+// TileHeight boxes, with pixels outside the diamond left transparent. Cell
+// (x, y) is the diamond at isometric coordinates (x, y) relative to the
+// first covered diamond: x grows down and to the right on screen and y
+// down and to the left. Cells whose diamond is not covered by the image
+// have no tile; see Covered. Isometric grids ignore Margin and Spacing and
+// treat every keeping edge policy as EdgePadTransparent.
+type Grid struct {
+	TileWidth   int
+	TileHeight  int
+	OffsetX     int
+	OffsetY     int
+	Margin      int
+	Spacing     int
+	Edge        EdgePolicy
+	Orientation Orientation
+}
+
+// SquareGrid returns a grid of size x size tiles starting at the origin.
+func SquareGrid(size int) Grid {
+	return Grid{TileWidth: size, TileHeight: size}
+}
+
+// Square reports whether the grid's tiles are square.
+func (g Grid) Square() bool {
+	return g.TileWidth == g.TileHeight
+}
+
+// Isometric reports whether the grid cuts diamond tiles.
+func (g Grid) Isometric() bool {
+	return g.Orientation == Isometric
+}
+
+// keepsEdges reports whether partial tiles are part of the grid.
+func (g Grid) keepsEdges() bool {
+	return g.Edge != "" && g.Edge != EdgeDrop
+}
+
+// axis returns where the first cell along one axis of length n starts and
+// how many cells there are. When partial tiles are kept the first cell may
+// start before the image so the strip before the offset is covered.
+func (g Grid) axis(n, offset, size int) (start, count int) {
+	if size <= 0 {
+		return 0, 0
+	}
+	pitch := size + g.Spacing
+	start = offset + g.Margin
+	if !g.keepsEdges() {
+		return start, max((n-start+g.Spacing)/pitch, 0)
+	}
+	for start > g.Margin {
+		start -= pitch
+	}
+	end := n - g.Margin
+	if end <= start {
+		return start, 0
+	}
+	return start, (end - start + pitch - 1) / pitch
+}
+
+// Dims returns how many tiles fit across and down bounds. For isometric
+// grids it is the extent of the covered diamonds in isometric coordinates.
+func (g Grid) Dims(bounds image.Rectangle) (cols, rows int) {
+	if g.Isometric() {
+		r := g.isoRange(bounds)
+		return r.Dx(), r.Dy()
+	}
+	_, cols = g.axis(bounds.Dx(), g.OffsetX, g.TileWidth)
+	_, rows = g.axis(bounds.Dy(), g.OffsetY, g.TileHeight)
+	return cols, rows
+}
+
+// Cell returns the pixel rectangle of grid cell (x, y) within bounds. Cells
+// of partial tiles extend past bounds. For isometric grids it is the box
+// around the cell's diamond.
+func (g Grid) Cell(bounds image.Rectangle, x, y int) image.Rectangle {
+	if g.Isometric() {
+		r := g.isoRange(bounds)
+		return g.diamondBox(bounds, r.Min.X+x, r.Min.Y+y)
+	}
+	sx, _ := g.axis(bounds.Dx(), g.OffsetX, g.TileWidth)
+	sy, _ := g.axis(bounds.Dy(), g.OffsetY, g.TileHeight)
+	x0 := bounds.Min.X + sx + x*(g.TileWidth+g.Spacing)
+	y0 := bounds.Min.Y + sy + y*(g.TileHeight+g.Spacing)
+	return image.Rect(x0, y0, x0+g.TileWidth, y0+g.TileHeight)
+}
+
+// Partial reports whether grid cell (x, y) extends past bounds.
+func (g Grid) Partial(bounds image.Rectangle, x, y int) bool {
+	return !g.Cell(bounds, x, y).In(bounds)
+}
+
+// Covered reports whether grid cell (x, y) holds a tile. Every cell of an
+// orthogonal grid does; an isometric cell does when its diamond lies inside
+// bounds or, if partial tiles are kept, overlaps it.
+func (g Grid) Covered(bounds image.Rectangle, x, y int) bool {
+	if !g.Isometric() {
+		return true
+	}
+	r := g.isoRange(bounds)
+	return g.diamondCovered(bounds, r.Min.X+x, r.Min.Y+y)
+}
+
+// Cells lists the cells holding a tile, row by row.
+func (g Grid) Cells(bounds image.Rectangle) []image.Point {
+	if g.Isometric() {
+		r := g.isoRange(bounds)
+		var cells []image.Point
+		for j := r.Min.Y; j < r.Max.Y; j++ {
+			for i := r.Min.X; i < r.Max.X; i++ {
+				if g.diamondCovered(bounds, i, j) {
+					cells = append(cells, image.Pt(i, j).Sub(r.Min))
+				}
+			}
+		}
+		return cells
+	}
+	cols, rows := g.Dims(bounds)
+	cells := make([]image.Point, 0, cols*rows)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			cells = append(cells, image.Pt(x, y))
+		}
+	}
+	return cells
+}
+
+// Cropped returns how many pixels of bounds lie in rows or columns outside
+// every tile of the grid. Margins and gutters are not counted. For
+// isometric grids it counts the pixels outside every covered diamond.
+func (g Grid) Cropped(bounds image.Rectangle) int {
+	if g.Isometric() {
+		return g.isoCropped(bounds)
+	}
+	lost := func(n, offset, size int) int {
+		start, count := g.axis(n, offset, size)
+		if count == 0 {
+			return max(n-2*g.Margin, 0)
+		}
+		end := start + count*(size+g.Spacing) - g.Spacing
+		lead := max(start-g.Margin, 0)
+		trail := max(n-g.Margin-end, 0)
+		return lead + trail
+	}
+	w, h := bounds.Dx(), bounds.Dy()
+	lx := lost(w, g.OffsetX, g.TileWidth)
+	ly := lost(h, g.OffsetY, g.TileHeight)
+	return lx*h + ly*w - lx*ly
+}
+
+// SliceImageWithGrid cuts img into the tiles of g, one per cell listed by
+// g.Cells. Partial tiles are padded or kept at their smaller size according
+// to g.Edge. Isometric tiles are masked to their diamond.
+func SliceImageWithGrid(img image.Image, g Grid) []image.Image {
+	bounds := img.Bounds()
+	cells := g.Cells(bounds)
+	tiles := make([]image.Image, 0, len(cells))
+	if g.Isometric() {
+		origin := g.isoRange(bounds).Min
+		for _, c := range cells {
+			r := g.diamondBox(bounds, origin.X+c.X, origin.Y+c.Y)
+			tiles = append(tiles, maskDiamond(cutTile(img, r, EdgePadTransparent).(*image.RGBA)))
+		}
+		return tiles
+	}
+	for _, c := range cells {
+		tiles = append(tiles, cutTile(img, g.Cell(bounds, c.X, c.Y), g.Edge))
+	}
+	return tiles
+}
+
+// cutTile copies cell r out of img, treating the part of r outside the
+// image according to edge.
+func cutTile(img image.Image, r image.Rectangle, edge EdgePolicy) image.Image {
+	in := r.Intersect(img.Bounds())
+	if edge == EdgePartial && in != r {
+		tile := image.NewRGBA(image.Rect(0, 0, in.Dx(), in.Dy()))
+		draw.Draw(tile, tile.Bounds(), img, in.Min, draw.Src)
+		return tile
+	}
+
+	tile := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(tile, in.Sub(r.Min), img, in.Min, draw.Src)
+	if edge == EdgePadEdge && in != r && !in.Empty() {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if (image.Point{X: x, Y: y}).In(in) {
+					continue
+				}
+				cx := min(max(x, in.Min.X), in.Max.X-1)
+				cy := min(max(y, in.Min.Y), in.Max.Y-1)
+				tile.Set(x-r.Min.X, y-r.Min.Y, img.At(cx, cy))
+			}
+		}
+	}
+	return tile
+}
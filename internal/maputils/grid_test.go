@@ -0,0 +1,199 @@
+package maputils
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// coordSheet returns a w x h image whose pixel (x, y) is coordColour(x, y).
+func coordSheet(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, coordColour(x, y))
+		}
+	}
+	return img
+}
+
+func coordColour(x, y int) color.RGBA {
+	return color.RGBA{uint8(x), uint8(y), 7, 255}
+}
+
+var transparent = color.RGBA{}
+
+func TestGridSlicing(t *testing.T) {
+	type cell struct {
+		x, y int
+		box  image.Rectangle
+	}
+	type pixel struct {
+		x, y   int // cell
+		px, py int // pixel within the tile
+		want   color.RGBA
+	}
+	tests := []struct {
+		name       string
+		w, h       int
+		grid       Grid
+		cols, rows int
+		cropped    int
+		cells      []cell
+		sizes      map[image.Point]image.Point
+		pixels     []pixel
+	}{
+		{
+			// Columns start at 5, 21, ..., 69 and end at 85, leaving 5 + 15
+			// columns; rows at 3 and 27 end at 51, leaving 3 + 19 rows.
+			name: "16x24 offset drop",
+			w:    100, h: 70,
+			grid: Grid{TileWidth: 16, TileHeight: 24, OffsetX: 5, OffsetY: 3},
+			cols: 5, rows: 2,
+			cropped: 20*70 + 22*100 - 20*22,
+			cells: []cell{
+				{0, 0, image.Rect(5, 3, 21, 27)},
+				{4, 1, image.Rect(69, 27, 85, 51)},
+			},
+			pixels: []pixel{
+				{0, 0, 0, 0, coordColour(5, 3)},
+				{4, 1, 15, 23, coordColour(84, 50)},
+			},
+		},
+		{
+			// Pitches of 33 and 17 from a margin of 2: columns at 2 and 35
+			// end at 67, 30 columns short of the right margin.
+			name: "32x16 margin spacing drop",
+			w:    99, h: 70,
+			grid: Grid{TileWidth: 32, TileHeight: 16, Margin: 2, Spacing: 1},
+			cols: 2, rows: 4,
+			cropped: 30 * 70,
+			cells: []cell{
+				{0, 0, image.Rect(2, 2, 34, 18)},
+				{1, 0, image.Rect(35, 2, 67, 18)},
+				{1, 3, image.Rect(35, 53, 67, 69)},
+			},
+			pixels: []pixel{
+				{1, 0, 0, 0, coordColour(35, 2)},
+				{1, 3, 31, 15, coordColour(66, 68)},
+			},
+		},
+		{
+			// Kept edges add the strips before the offset and after the
+			// last whole tile, padded with transparent pixels.
+			name: "16x24 offset pad-transparent",
+			w:    100, h: 70,
+			grid: Grid{TileWidth: 16, TileHeight: 24, OffsetX: 5, OffsetY: 3, Edge: EdgePadTransparent},
+			cols: 7, rows: 4,
+			cells: []cell{
+				{0, 0, image.Rect(-11, -21, 5, 3)},
+				{1, 1, image.Rect(5, 3, 21, 27)},
+				{6, 3, image.Rect(85, 51, 101, 75)},
+			},
+			pixels: []pixel{
+				{0, 0, 0, 0, transparent},
+				{0, 0, 15, 23, coordColour(4, 2)},
+				{6, 3, 15, 0, transparent},
+				{6, 3, 14, 18, coordColour(99, 69)},
+				{6, 3, 14, 19, transparent},
+			},
+		},
+		{
+			name: "16x24 offset pad-edge",
+			w:    100, h: 70,
+			grid: Grid{TileWidth: 16, TileHeight: 24, OffsetX: 5, OffsetY: 3, Edge: EdgePadEdge},
+			cols: 7, rows: 4,
+			pixels: []pixel{
+				{0, 0, 0, 0, coordColour(0, 0)},
+				{0, 0, 12, 1, coordColour(1, 0)},
+				{6, 3, 15, 23, coordColour(99, 69)},
+			},
+		},
+		{
+			name: "16x24 offset partial",
+			w:    100, h: 70,
+			grid: Grid{TileWidth: 16, TileHeight: 24, OffsetX: 5, OffsetY: 3, Edge: EdgePartial},
+			cols: 7, rows: 4,
+			sizes: map[image.Point]image.Point{
+				{0, 0}: {5, 3},
+				{1, 0}: {16, 3},
+				{1, 1}: {16, 24},
+				{6, 3}: {15, 19},
+			},
+			pixels: []pixel{
+				{0, 0, 0, 0, coordColour(0, 0)},
+				{6, 3, 14, 18, coordColour(99, 69)},
+			},
+		},
+		{
+			// With edges kept the last column runs past the image into the
+			// right margin; rows still fit before the bottom margin.
+			name: "32x16 margin spacing partial",
+			w:    99, h: 70,
+			grid: Grid{TileWidth: 32, TileHeight: 16, Margin: 2, Spacing: 1, Edge: EdgePartial},
+			cols: 3, rows: 4,
+			cells: []cell{
+				{2, 0, image.Rect(68, 2, 100, 18)},
+				{2, 3, image.Rect(68, 53, 100, 69)},
+			},
+			sizes: map[image.Point]image.Point{
+				{1, 0}: {32, 16},
+				{2, 0}: {31, 16},
+			},
+			pixels: []pixel{
+				{2, 0, 30, 15, coordColour(98, 17)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img := coordSheet(tc.w, tc.h)
+			b := img.Bounds()
+			g := tc.grid
+
+			cols, rows := g.Dims(b)
+			if cols != tc.cols || rows != tc.rows {
+				t.Fatalf("Dims = %dx%d, want %dx%d", cols, rows, tc.cols, tc.rows)
+			}
+			if got := g.Cropped(b); got != tc.cropped {
+				t.Errorf("Cropped = %d, want %d", got, tc.cropped)
+			}
+			for _, c := range tc.cells {
+				if got := g.Cell(b, c.x, c.y); got != c.box {
+					t.Errorf("Cell(%d, %d) = %v, want %v", c.x, c.y, got, c.box)
+				}
+				if got, want := g.Partial(b, c.x, c.y), !c.box.In(b); got != want {
+					t.Errorf("Partial(%d, %d) = %v, want %v", c.x, c.y, got, want)
+				}
+			}
+
+			tiles := SliceImageWithGrid(img, g)
+			if len(tiles) != cols*rows {
+				t.Fatalf("%d tiles, want %d", len(tiles), cols*rows)
+			}
+			for y := 0; y < rows; y++ {
+				for x := 0; x < cols; x++ {
+					// Partial tiles are the cell clipped to the image; the
+					// sizes listed by the case pin down a few by hand.
+					size := image.Pt(g.TileWidth, g.TileHeight)
+					if g.Edge == EdgePartial {
+						size = g.Cell(b, x, y).Intersect(b).Size()
+					}
+					if s, ok := tc.sizes[image.Pt(x, y)]; ok && s != size {
+						t.Fatalf("tile (%d, %d) should be %v, but its cell clipped to the image is %v", x, y, s, size)
+					}
+					if got := tiles[y*cols+x].Bounds().Size(); got != size {
+						t.Errorf("tile (%d, %d) is %v, want %v", x, y, got, size)
+					}
+				}
+			}
+			for _, p := range tc.pixels {
+				tile := tiles[p.y*cols+p.x].(*image.RGBA)
+				if got := tile.RGBAAt(p.px, p.py); got != p.want {
+					t.Errorf("tile (%d, %d) pixel (%d, %d) = %v, want %v", p.x, p.y, p.px, p.py, got, p.want)
+				}
+			}
+		})
+	}
+}
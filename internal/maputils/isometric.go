@@ -0,0 +1,158 @@
+package maputils
+
+import (
+	"image"
+	"math"
+)
+
+// Isometric geometry. With half sizes hw = TileWidth/2 and hh =
+// TileHeight/2, the diamond at isometric coordinates (i, j) sits in the box
+// whose top-left corner is
+//
+//	(OffsetX + (i-j)*hw, OffsetY + (i+j)*hh)
+//
+// relative to the image, so stepping i moves half a tile right and down and
+// stepping j half a tile left and down. The lattice repeats every
+// (TileWidth, 0) and (hw, hh), so offsets in [0, TileWidth) x [0, hh)
+// describe every grid.
+
+// diamondBox returns the box around diamond (i, j).
+func (g Grid) diamondBox(bounds image.Rectangle, i, j int) image.Rectangle {
+	hw, hh := g.TileWidth/2, g.TileHeight/2
+	x := bounds.Min.X + g.OffsetX + (i-j)*hw
+	y := bounds.Min.Y + g.OffsetY + (i+j)*hh
+	return image.Rect(x, y, x+g.TileWidth, y+g.TileHeight)
+}
+
+// diamondCovered reports whether diamond (i, j) lies inside bounds or, when
+// edges are kept, overlaps it.
+func (g Grid) diamondCovered(bounds image.Rectangle, i, j int) bool {
+	box := g.diamondBox(bounds, i, j)
+	if box.In(bounds) {
+		return true
+	}
+	if !g.keepsEdges() || !box.Overlaps(bounds) {
+		return false
+	}
+	// The pixel of bounds nearest the centre decides whether the diamond
+	// reaches into it. The distance is separable, so the nearest pixel
+	// centre can be found on each axis alone.
+	nearest := func(c float64, lo, hi int) float64 {
+		return math.Floor(math.Max(float64(lo), math.Min(c, float64(hi)-0.5))) + 0.5
+	}
+	cx := float64(box.Min.X) + float64(g.TileWidth)/2
+	cy := float64(box.Min.Y) + float64(g.TileHeight)/2
+	px := nearest(cx, bounds.Min.X, bounds.Max.X)
+	py := nearest(cy, bounds.Min.Y, bounds.Max.Y)
+	return math.Abs(px-cx)/(float64(g.TileWidth)/2)+math.Abs(py-cy)/(float64(g.TileHeight)/2) <= 1
+}
+
+// DiamondAt returns the isometric coordinates of the diamond of an
+// isometric grid holding pixel (x, y) of bounds. The coordinates are
+// absolute, not relative to the first covered cell, and the diamond need
+// not be covered; compare results to tell which pixels share a tile.
+func (g Grid) DiamondAt(bounds image.Rectangle, x, y int) image.Point {
+	i, j := g.diamondAt(bounds, x, y)
+	return image.Pt(i, j)
+}
+
+func (g Grid) diamondAt(bounds image.Rectangle, x, y int) (i, j int) {
+	hw, hh := float64(g.TileWidth)/2, float64(g.TileHeight)/2
+	u := (float64(x-bounds.Min.X-g.OffsetX)+0.5)/hw - 1
+	v := (float64(y-bounds.Min.Y-g.OffsetY)+0.5)/hh - 1
+	return int(math.Round((u + v) / 2)), int(math.Round((v - u) / 2))
+}
+
+// isoRange returns the isometric coordinates spanned by the covered
+// diamonds: Min is the first cell, Max one past the last.
+func (g Grid) isoRange(bounds image.Rectangle) image.Rectangle {
+	hw, hh := g.TileWidth/2, g.TileHeight/2
+	if hw == 0 || hh == 0 || bounds.Empty() {
+		return image.Rectangle{}
+	}
+	// Boxes overlapping bounds have d = i-j and s = i+j within these
+	// ranges. The covered diamonds form a convex region, so along each
+	// diagonal d only the first and last covered s matter.
+	dlo := floorDiv(-g.OffsetX-g.TileWidth, hw)
+	dhi := floorDiv(bounds.Dx()-g.OffsetX, hw) + 1
+	slo := floorDiv(-g.OffsetY-g.TileHeight, hh)
+	shi := floorDiv(bounds.Dy()-g.OffsetY, hh) + 1
+
+	var r image.Rectangle
+	found := false
+	add := func(d, s int) {
+		c := image.Rect((d+s)/2, (s-d)/2, (d+s)/2+1, (s-d)/2+1)
+		if !found {
+			r, found = c, true
+			return
+		}
+		r = r.Union(c)
+	}
+	for d := dlo; d <= dhi; d++ {
+		// i and j are whole only when d and s have the same parity.
+		first := slo + (d-slo)&1
+		for s := first; s <= shi; s += 2 {
+			if g.diamondCovered(bounds, (d+s)/2, (s-d)/2) {
+				add(d, s)
+				break
+			}
+		}
+		last := shi - (shi-d)&1
+		for s := last; s >= slo; s -= 2 {
+			if g.diamondCovered(bounds, (d+s)/2, (s-d)/2) {
+				add(d, s)
+				break
+			}
+		}
+	}
+	return r
+}
+
+// floorDiv divides rounding towards negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// isoCropped counts the pixels of bounds outside every covered diamond.
+func (g Grid) isoCropped(bounds image.Rectangle) int {
+	r := g.isoRange(bounds)
+	if r.Empty() {
+		return bounds.Dx() * bounds.Dy()
+	}
+	lost := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i, j := g.diamondAt(bounds, x, y)
+			if !(image.Point{X: i, Y: j}).In(r) || !g.diamondCovered(bounds, i, j) {
+				lost++
+			}
+		}
+	}
+	return lost
+}
+
+// InDiamond reports whether pixel (x, y) of a w x h box lies in the
+// inscribed diamond, i.e. survives the mask of isometric tiles.
+func InDiamond(x, y, w, h int) bool {
+	du := math.Abs(float64(2*x+1-w)) / float64(w)
+	dv := math.Abs(float64(2*y+1-h)) / float64(h)
+	return du+dv <= 1
+}
+
+// maskDiamond clears the pixels of tile outside its inscribed diamond.
+func maskDiamond(tile *image.RGBA) *image.RGBA {
+	b := tile.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if !InDiamond(x, y, b.Dx(), b.Dy()) {
+				o := tile.PixOffset(b.Min.X+x, b.Min.Y+y)
+				clear(tile.Pix[o : o+4])
+			}
+		}
+	}
+	return tile
+}
@@ -0,0 +1,256 @@
+package maputils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// diamond8x4 is the mask of an 8x4 isometric tile, written out by hand so
+// the tests do not rely on InDiamond.
+var diamond8x4 = []string{
+	"...##...",
+	".######.",
+	".######.",
+	"...##...",
+}
+
+// isoOwner returns the isometric coordinates of the diamond holding pixel
+// (x, y) of a grid of 8x4 diamonds at (ox, oy), found by brute force over
+// the boxes covering the pixel.
+func isoOwner(t *testing.T, ox, oy, x, y int) image.Point {
+	t.Helper()
+	var owners []image.Point
+	for j := -20; j <= 20; j++ {
+		for i := -20; i <= 20; i++ {
+			bx, by := ox+(i-j)*4, oy+(i+j)*2
+			rx, ry := x-bx, y-by
+			if rx >= 0 && rx < 8 && ry >= 0 && ry < 4 && diamond8x4[ry][rx] == '#' {
+				owners = append(owners, image.Pt(i, j))
+			}
+		}
+	}
+	if len(owners) != 1 {
+		t.Fatalf("pixel (%d, %d) lies in %d diamonds, want 1", x, y, len(owners))
+	}
+	return owners[0]
+}
+
+// isoColour encodes the isometric coordinates of a diamond as its colour.
+func isoColour(p image.Point) color.RGBA {
+	return color.RGBA{uint8(100 + p.X), uint8(100 + p.Y), 0, 255}
+}
+
+// isoSheet paints every pixel of a w x h image with the colour of the 8x4
+// diamond holding it.
+func isoSheet(t *testing.T, w, h, ox, oy int) *image.RGBA {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, isoColour(isoOwner(t, ox, oy, x, y)))
+		}
+	}
+	return img
+}
+
+func TestIsometricCells(t *testing.T) {
+	// A 24x12 sheet of 8x4 diamonds holds rows of 3, 2, 3, 2 and 3 whole
+	// diamonds. Isometric i runs from 0 to 4 and j from -2 to 2, so cell
+	// (x, y) is diamond (x, y-2).
+	img := isoSheet(t, 24, 12, 0, 0)
+	g := Grid{TileWidth: 8, TileHeight: 4, Orientation: Isometric}
+	b := img.Bounds()
+
+	want := []image.Point{
+		{2, 0},
+		{1, 1}, {2, 1}, {3, 1},
+		{0, 2}, {1, 2}, {2, 2}, {3, 2}, {4, 2},
+		{1, 3}, {2, 3}, {3, 3},
+		{2, 4},
+	}
+	got := g.Cells(b)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Cells = %v, want %v", got, want)
+	}
+	if cols, rows := g.Dims(b); cols != 5 || rows != 5 {
+		t.Errorf("Dims = %dx%d, want 5x5", cols, rows)
+	}
+	if g.Covered(b, 0, 0) || !g.Covered(b, 2, 0) {
+		t.Errorf("Covered(0, 0) = %v, Covered(2, 0) = %v, want false, true", g.Covered(b, 0, 0), g.Covered(b, 2, 0))
+	}
+
+	boxes := []struct {
+		x, y int
+		want image.Rectangle
+	}{
+		{2, 0, image.Rect(16, 0, 24, 4)},
+		{0, 2, image.Rect(0, 0, 8, 4)},
+		{2, 2, image.Rect(8, 4, 16, 8)},
+		{4, 2, image.Rect(16, 8, 24, 12)},
+		{2, 4, image.Rect(0, 8, 8, 12)},
+	}
+	for _, tc := range boxes {
+		if got := g.Cell(b, tc.x, tc.y); got != tc.want {
+			t.Errorf("Cell(%d, %d) = %v, want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestIsometricSlicing(t *testing.T) {
+	tests := []struct {
+		name   string
+		w, h   int
+		ox, oy int
+		edge   EdgePolicy
+	}{
+		{"origin", 24, 12, 0, 0, EdgeDrop},
+		{"offset", 30, 15, 3, 1, EdgeDrop},
+		{"odd offset", 29, 14, 7, 0, EdgeDrop},
+		{"origin padded", 24, 12, 0, 0, EdgePadTransparent},
+		{"offset padded", 30, 15, 5, 1, EdgePadTransparent},
+		{"offset partial", 30, 15, 2, 1, EdgePartial},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img := isoSheet(t, tc.w, tc.h, tc.ox, tc.oy)
+			b := img.Bounds()
+			g := Grid{TileWidth: 8, TileHeight: 4, OffsetX: tc.ox, OffsetY: tc.oy, Edge: tc.edge, Orientation: Isometric}
+
+			// A diamond is covered when its box is inside the image or,
+			// when edges are kept, any of its pixels is.
+			covered := map[image.Point]bool{}
+			for j := -20; j <= 20; j++ {
+				for i := -20; i <= 20; i++ {
+					box := image.Rect(tc.ox+(i-j)*4, tc.oy+(i+j)*2, tc.ox+(i-j)*4+8, tc.oy+(i+j)*2+4)
+					if box.In(b) {
+						covered[image.Pt(i, j)] = true
+					}
+				}
+			}
+			cropped := 0
+			for y := 0; y < tc.h; y++ {
+				for x := 0; x < tc.w; x++ {
+					p := isoOwner(t, tc.ox, tc.oy, x, y)
+					if tc.edge != EdgeDrop {
+						covered[p] = true
+					}
+					if !covered[p] {
+						cropped++
+					}
+				}
+			}
+
+			cells := g.Cells(b)
+			tiles := SliceImageWithGrid(img, g)
+			if len(cells) != len(covered) || len(tiles) != len(cells) {
+				t.Fatalf("%d cells and %d tiles, want %d", len(cells), len(tiles), len(covered))
+			}
+			if got := g.Cropped(b); got != cropped {
+				t.Errorf("Cropped = %d, want %d", got, cropped)
+			}
+
+			for k, c := range cells {
+				box := g.Cell(b, c.X, c.Y)
+				iso := g.DiamondAt(b, box.Min.X+4, box.Min.Y+2)
+				if !covered[iso] {
+					t.Fatalf("cell %v is diamond %v, which is not covered", c, iso)
+				}
+				want := isoColour(iso)
+				tile := tiles[k].(*image.RGBA)
+				if tile.Bounds().Dx() != 8 || tile.Bounds().Dy() != 4 {
+					t.Fatalf("tile %v is %v, want 8x4", c, tile.Bounds())
+				}
+				for y := 0; y < 4; y++ {
+					for x := 0; x < 8; x++ {
+						got := tile.RGBAAt(x, y)
+						inside := diamond8x4[y][x] == '#'
+						p := box.Min.Add(image.Pt(x, y))
+						switch {
+						case !inside && got.A != 0:
+							t.Fatalf("tile %v pixel (%d, %d) = %v outside the diamond, want transparent", c, x, y, got)
+						case inside && p.In(b) && got != want:
+							t.Fatalf("tile %v pixel (%d, %d) = %v, want %v", c, x, y, got, want)
+						case inside && !p.In(b) && got.A != 0:
+							t.Fatalf("tile %v pixel (%d, %d) = %v outside the image, want transparent", c, x, y, got)
+						}
+					}
+				}
+			}
+
+			for y := 0; y < tc.h; y++ {
+				for x := 0; x < tc.w; x++ {
+					if got, want := g.DiamondAt(b, x, y), isoOwner(t, tc.ox, tc.oy, x, y); got != want {
+						t.Fatalf("DiamondAt(%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestIsometricAdjacency(t *testing.T) {
+	img := isoSheet(t, 40, 20, 2, 1)
+	g := Grid{TileWidth: 8, TileHeight: 4, OffsetX: 2, OffsetY: 1, Orientation: Isometric}
+	b := img.Bounds()
+
+	// Every cell gets its own tile, so each direction holds at most the
+	// one diamond sharing that edge on screen.
+	cols, rows := g.Dims(b)
+	mapping := make([][]int, rows)
+	for y := range mapping {
+		mapping[y] = make([]int, cols)
+		for x := range mapping[y] {
+			mapping[y][x] = -1
+		}
+	}
+	var tiles []Tile
+	byBox := map[image.Point]Tile{}
+	for id, c := range g.Cells(b) {
+		tile := Tile{ID: id, Hash: fmt.Sprintf("h%02d", id), X: c.X, Y: c.Y}
+		tiles = append(tiles, tile)
+		mapping[c.Y][c.X] = id
+		byBox[g.Cell(b, c.X, c.Y).Min] = tile
+	}
+
+	adj := BuildIsometricAdjacency(tiles, mapping)
+	weighted := BuildIsometricWeightedAdjacency(tiles, mapping)
+	for _, tile := range tiles {
+		box := g.Cell(b, tile.X, tile.Y).Min
+		a, w := adj[tile.ID], weighted[tile.ID]
+		if a.Top != nil || a.Bottom != nil || a.Left != nil || a.Right != nil {
+			t.Fatalf("tile %d has cardinal neighbours %+v", tile.ID, a)
+		}
+		if w.Top != nil || w.Bottom != nil || w.Left != nil || w.Right != nil {
+			t.Fatalf("tile %d has cardinal neighbour counts %+v", tile.ID, w)
+		}
+		dirs := []struct {
+			name   string
+			offset image.Point
+			hashes []string
+			counts []NeighbourCount
+		}{
+			{"topLeft", image.Pt(-4, -2), a.TopLeft, w.TopLeft},
+			{"topRight", image.Pt(4, -2), a.TopRight, w.TopRight},
+			{"bottomLeft", image.Pt(-4, 2), a.BottomLeft, w.BottomLeft},
+			{"bottomRight", image.Pt(4, 2), a.BottomRight, w.BottomRight},
+		}
+		for _, d := range dirs {
+			var want []string
+			if n, ok := byBox[box.Add(d.offset)]; ok {
+				want = []string{n.Hash}
+			}
+			if fmt.Sprint(d.hashes) != fmt.Sprint(want) {
+				t.Errorf("tile %d %s = %v, want %v", tile.ID, d.name, d.hashes, want)
+			}
+			var got []string
+			for _, c := range d.counts {
+				got = append(got, c.Hash)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("tile %d weighted %s = %v, want %v", tile.ID, d.name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package maputils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LoadTileset reads tileset.json from a tileset directory.
+func LoadTileset(dir string) (*TilesetMetadata, error) {
+	f, err := os.Open(filepath.Join(dir, "tileset.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tileset: %w", err)
+	}
+	defer f.Close()
+
+	var meta TilesetMetadata
+	if err := json.NewDecoder(f).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to decode tileset: %w", err)
+	}
+	if len(meta.Tiles) == 0 {
+		return nil, fmt.Errorf("tileset %s contains no tiles", dir)
+	}
+	return &meta, nil
+}
@@ -0,0 +1,67 @@
+package maputils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// MappingFile is a standalone grid of tile IDs, such as a generated map.
+type MappingFile struct {
+	Tileset string  `json:"tileset,omitempty"`
+	Seed    int64   `json:"seed"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	Mapping [][]int `json:"mapping"`
+	// Chunk is set when the mapping is one chunk of a larger world.
+	Chunk *ChunkCoord `json:"chunk,omitempty"`
+}
+
+// ChunkCoord locates a chunk in a chunked world. The chunk covers world
+// cells [X*Size, (X+1)*Size) horizontally and likewise vertically.
+type ChunkCoord struct {
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	Size int `json:"size"`
+}
+
+// SaveMapping writes a mapping file as JSON.
+func SaveMapping(path string, m MappingFile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(m)
+}
+
+// LoadMapping reads a mapping file. Both the MappingFile object form and a
+// bare 2D array of tile IDs are accepted.
+func LoadMapping(path string) (*MappingFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping: %w", err)
+	}
+
+	var m MappingFile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &m.Mapping)
+	} else {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mapping: %w", err)
+	}
+
+	m.Height = len(m.Mapping)
+	m.Width = 0
+	for y, row := range m.Mapping {
+		if y == 0 {
+			m.Width = len(row)
+		} else if len(row) != m.Width {
+			return nil, fmt.Errorf("mapping row %d has %d cells, expected %d", y, len(row), m.Width)
+		}
+	}
+	return &m, nil
+}
@@ -15,11 +15,63 @@ type TilesetEntry struct {
 	X         int       `json:"x"`
 	Y         int       `json:"y"`
 	Adjacency Adjacency `json:"adjacency"`
+	// Partial marks tiles cut from edge cells that did not fit inside the
+	// source image; see TilesetMetadata.EdgePolicy.
+	Partial bool `json:"partial,omitempty"`
+	// Frequency is the number of map cells holding this tile.
+	Frequency int `json:"frequency,omitempty"`
+	// Neighbours counts how often each tile was seen next to this one.
+	Neighbours *WeightedAdjacency `json:"neighbours,omitempty"`
 }
 
 type TilesetMetadata struct {
-	TileSize int            `json:"tileSize"`
-	Tiles    []TilesetEntry `json:"tiles"`
+	// TileSize is the side of square tiles. It is omitted for rectangular
+	// tiles; TileDims reads either form.
+	TileSize   int `json:"tileSize,omitempty"`
+	TileWidth  int `json:"tileWidth,omitempty"`
+	TileHeight int `json:"tileHeight,omitempty"`
+	// OffsetX and OffsetY are where the tile grid starts in the source image.
+	OffsetX int `json:"offsetX,omitempty"`
+	OffsetY int `json:"offsetY,omitempty"`
+	// Margin and Spacing are the border and gutter, in pixels, of a
+	// spritesheet source.
+	Margin  int `json:"margin,omitempty"`
+	Spacing int `json:"spacing,omitempty"`
+	// Orientation is "isometric" for diamond tiles, whose mapping is in
+	// isometric coordinates with -1 for cells not covered by the source and
+	// whose adjacency uses the diagonal keys only. It is omitted for
+	// orthogonal tiles.
+	Orientation Orientation `json:"orientation,omitempty"`
+	// EdgePolicy is how partial edge tiles were treated; empty means they
+	// were dropped. CroppedPixels counts the source pixels left out of the
+	// tiles.
+	EdgePolicy    EdgePolicy `json:"edgePolicy,omitempty"`
+	CroppedPixels int        `json:"croppedPixels,omitempty"`
+	// PixelScale is the factor by which the source was detected to be
+	// upscaled pixel art. When Native is set, tiles were cut from the image
+	// downscaled to native resolution; scale them up by this factor to
+	// match the source. Otherwise they were cut from the source as is.
+	PixelScale int            `json:"pixelScale,omitempty"`
+	Native     bool           `json:"native,omitempty"`
+	Tiles      []TilesetEntry `json:"tiles"`
+	Mapping    [][]int        `json:"mapping,omitempty"`
+	// Classes names groups of tile IDs, e.g. "water". They are not written
+	// by training and may be added by hand.
+	Classes map[string][]int `json:"classes,omitempty"`
+}
+
+// Isometric reports whether the tileset holds diamond tiles.
+func (m *TilesetMetadata) Isometric() bool {
+	return m.Orientation == Isometric
+}
+
+// TileDims returns the tile width and height. Tilesets saved before
+// rectangular tiles were supported only record TileSize.
+func (m *TilesetMetadata) TileDims() (width, height int) {
+	if m.TileWidth > 0 && m.TileHeight > 0 {
+		return m.TileWidth, m.TileHeight
+	}
+	return m.TileSize, m.TileSize
 }
 
 func SaveTileset(tiles []Tile, outputDir string, tileSize int) error {
@@ -53,8 +105,10 @@ func SaveTileset(tiles []Tile, outputDir string, tileSize int) error {
 	}
 
 	metadata := TilesetMetadata{
-		TileSize: tileSize,
-		Tiles:    entries,
+		TileSize:   tileSize,
+		TileWidth:  tileSize,
+		TileHeight: tileSize,
+		Tiles:      entries,
 	}
 
 	metaPath := filepath.Join(outputDir, "tileset.json")
@@ -13,6 +13,9 @@ type Tile struct {
 	Hash  string
 	X     int
 	Y     int
+	// Partial is set when the tile was cut from an edge cell that did not
+	// fit inside the image.
+	Partial bool
 }
 
 func SliceAndHashTiles(path string, tileSize int) ([]Tile, error) {
@@ -5,24 +5,13 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"image"
-	"image/draw"
 	"image/png"
 )
 
-// SliceImageIntoTiles slices an image into square tiles of a given size.
+// SliceImageIntoTiles slices an image into square tiles of a given size,
+// starting at the top-left corner.
 func SliceImageIntoTiles(img image.Image, tileSize int) []image.Image {
-	bounds := img.Bounds()
-	var tiles []image.Image
-
-	for y := bounds.Min.Y; y+tileSize <= bounds.Max.Y; y += tileSize {
-		for x := bounds.Min.X; x+tileSize <= bounds.Max.X; x += tileSize {
-			tileRect := image.Rect(0, 0, tileSize, tileSize)
-			tile := image.NewRGBA(tileRect)
-			draw.Draw(tile, tileRect, img, image.Point{X: x, Y: y}, draw.Src)
-			tiles = append(tiles, tile)
-		}
-	}
-	return tiles
+	return SliceImageWithGrid(img, SquareGrid(tileSize))
 }
 
 // HashTiles returns an array of string hashes representing each tile.
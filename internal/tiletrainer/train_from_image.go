@@ -3,29 +3,125 @@ package tiletrainer
 import (
 	"fmt"
 	"image"
+	"image/draw"
+	"path/filepath"
 
 	"tilemap-generator/internal/analyser"
+	"tilemap-generator/internal/maputils"
 	"tilemap-generator/internal/tileutils"
 )
 
+// Options control how TrainFromImages builds a tileset.
+type Options struct {
+	// Grid is the tile grid to cut both images with.
+	Grid maputils.Grid
+	// Diagnostic saves a diagnostic grid of the detected tile groups.
+	Diagnostic bool
+	// Diagonals records diagonal neighbours in tileset.json.
+	Diagonals bool
+	// Scale is the detected upscaling of the source pixel art; it is
+	// recorded in tileset.json whenever it is above 1. Source, when set, is
+	// the upscaled image the images were normalised from with Scale.
+	// Cropped pixels and the heatmap then refer to Source, so they match
+	// the image the user supplied.
+	Scale  analyser.PixelScale
+	Source image.Image
+	// MaxDeltaE, when positive, groups tiles by colour similarity instead
+	// of exact hashes: tiles whose mean CIEDE2000 difference is at most
+	// MaxDeltaE share a tile. Use it for lossy sources such as JPEG.
+	MaxDeltaE float64
+	// Heatmap saves heatmap.png: the source tinted by how often each
+	// cell's tile is reused.
+	Heatmap bool
+}
+
 // TrainFromImages deduplicates tiles using the cleaned image but saves tiles
 // cut from the original image into outputDir. A mapping of tile positions to
 // tile IDs is written to tileset.json.
-func TrainFromImages(original, cleaned image.Image, tileSize int, outputDir string, diagnostic bool) error {
-	rawTiles := tileutils.ExtractTiles(cleaned, tileSize)
+func TrainFromImages(original, cleaned image.Image, outputDir string, opts Options) error {
+	if opts.MaxDeltaE > 0 {
+		return trainBySimilarity(original, outputDir, opts)
+	}
+
+	rawTiles := maputils.SliceImageWithGrid(cleaned, opts.Grid)
 	groups, unique := analyser.FuzzyMatchTiles(rawTiles, 5)
 
 	fmt.Printf("Deduplicated tiles: %d unique of %d total\n", unique, len(rawTiles))
 
-	if diagnostic {
+	if opts.Diagnostic {
+		diagPath := fmt.Sprintf("%s/diagnostic.png", outputDir)
+		_ = analyser.SaveDiagnosticGrid(rawTiles, groups, opts.Grid.TileWidth, opts.Grid.TileHeight, diagPath)
+	}
+
+	tiles, mapping, err := tileutils.ExtractUniqueTilesWithGrid(original, cleaned, opts.Grid)
+	if err != nil {
+		return err
+	}
+	return saveTileset(tiles, mapping, original, outputDir, opts)
+}
+
+// trainBySimilarity groups the original tiles with
+// analyser.GroupSimilarTiles, so the same groups decide the mapping, the
+// saved tiles and the diagnostic grid.
+func trainBySimilarity(original image.Image, outputDir string, opts Options) error {
+	rawTiles := maputils.SliceImageWithGrid(original, opts.Grid)
+	groups, reps := analyser.GroupSimilarTiles(rawTiles, opts.MaxDeltaE)
+
+	fmt.Printf("Grouped tiles by similarity (ΔE ≤ %.1f): %d unique of %d total\n", opts.MaxDeltaE, len(reps), len(rawTiles))
+
+	if opts.Diagnostic {
 		diagPath := fmt.Sprintf("%s/diagnostic.png", outputDir)
-		_ = analyser.SaveDiagnosticGrid(rawTiles, groups, tileSize, diagPath)
+		_ = analyser.SaveDiagnosticGrid(rawTiles, groups, opts.Grid.TileWidth, opts.Grid.TileHeight, diagPath)
 	}
 
-	tiles, mapping, err := tileutils.ExtractUniqueTilesWithIndex(original, cleaned, tileSize)
+	tiles, mapping, err := tileutils.ExtractGroupedTilesWithGrid(original, opts.Grid, groups, reps)
 	if err != nil {
 		return err
 	}
+	return saveTileset(tiles, mapping, original, outputDir, opts)
+}
+
+func saveTileset(tiles []maputils.Tile, mapping [][]int, original image.Image, outputDir string, opts Options) error {
+	pixelScale := 0
+	if opts.Scale.Scale > 1 {
+		pixelScale = opts.Scale.Scale
+	}
+	native := pixelScale > 0 && opts.Source != nil
+	cropped := opts.Grid.Cropped(original.Bounds())
+	if native {
+		cropped = opts.Scale.SourcePixels(cropped)
+	}
+	err := tileutils.SaveTileset(tiles, mapping, outputDir, tileutils.SaveOptions{
+		Grid:          opts.Grid,
+		CroppedPixels: cropped,
+		PixelScale:    pixelScale,
+		Native:        native,
+		Diagonals:     opts.Diagonals,
+	})
+	if err != nil || !opts.Heatmap {
+		return err
+	}
+
+	source, grid := original, opts.Grid
+	if native {
+		source, grid = upscaledLayout(opts.Source, original.Bounds().Size(), opts.Scale), opts.Scale.UpscaleGrid(grid)
+	}
+	hm := tileutils.RenderReuseHeatmap(source, grid, mapping)
+	heatmapPath := filepath.Join(outputDir, "heatmap.png")
+	if err := tileutils.SavePNG(hm.Image, heatmapPath); err != nil {
+		return fmt.Errorf("failed to save heatmap: %w", err)
+	}
+	fmt.Printf("🔥 Reuse heatmap saved to %s: %d of %d cells hold a tile used once, most reused tile fills %d cells\n",
+		heatmapPath, hm.SingletonCells, hm.Cells, hm.MaxCount)
+	return nil
+}
 
-	return tileutils.SaveTilesetWithIndex(tiles, mapping, outputDir, tileSize)
+// upscaledLayout returns src on a canvas spanning the blocks behind a
+// native image of the given size, so the upscaled grid cuts it into the
+// same cells as the native grid. Partial blocks at the edges leave strips
+// of less than a block transparent.
+func upscaledLayout(src image.Image, native image.Point, scale analyser.PixelScale) image.Image {
+	canvas := image.NewRGBA(scale.SourceBounds(src.Bounds(), native))
+	draw.Draw(canvas, src.Bounds(), src, src.Bounds().Min, draw.Src)
+	return canvas
 }
@@ -0,0 +1,79 @@
+package tileutils
+
+import (
+	"image"
+	"sort"
+)
+
+// FindCounterparts applies transform to every tile image and looks for the
+// tile that matches the result, so a tile can be swapped for its mirrored or
+// rotated version. Images are compared at full resolution and match when
+// they have the same size and no channel of any pixel differs by more than
+// tolerance (0-255), so tiles that differ in any detail, such as doors
+// facing opposite ways, are never paired. A tile that matches its own
+// transformed image is its own counterpart; otherwise the closest match, by
+// mean difference, wins. Tiles without a match are absent from the returned
+// map.
+func FindCounterparts(images map[int]image.Image, transform func(image.Image) image.Image, tolerance float64) map[int]int {
+	ids := make([]int, 0, len(images))
+	for id := range images {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	out := make(map[int]int)
+	for _, id := range ids {
+		t := transform(images[id])
+		if _, ok := pixelDiff(t, images[id], tolerance); ok {
+			out[id] = id
+			continue
+		}
+		best, bestDiff := -1, 0.0
+		for _, other := range ids {
+			d, ok := pixelDiff(t, images[other], tolerance)
+			if ok && (best < 0 || d < bestDiff) {
+				best, bestDiff = other, d
+			}
+		}
+		if best >= 0 {
+			out[id] = best
+		}
+	}
+	return out
+}
+
+// pixelDiff returns the mean absolute difference of the RGBA channels of two
+// images, scaled to 0-255. It reports false when their sizes differ or any
+// channel of any pixel differs by more than tolerance.
+func pixelDiff(a, b image.Image, tolerance float64) (float64, bool) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return 0, false
+	}
+	limit := uint64(tolerance * 257)
+	var sum uint64
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range []uint64{absDiff(r1, r2), absDiff(g1, g2), absDiff(b1, b2), absDiff(a1, a2)} {
+				if d > limit {
+					return 0, false
+				}
+				sum += d
+			}
+		}
+	}
+	n := float64(ab.Dx() * ab.Dy() * 4)
+	if n == 0 {
+		return 0, true
+	}
+	return float64(sum) / n / 257, true
+}
+
+func absDiff(a, b uint32) uint64 {
+	if a > b {
+		return uint64(a - b)
+	}
+	return uint64(b - a)
+}
@@ -1,6 +1,7 @@
 package tileutils
 
 import (
+	"fmt"
 	"image"
 
 	"tilemap-generator/internal/maputils"
@@ -10,52 +11,101 @@ import (
 // deduplicates tiles using the cleaned version, and returns unique tiles from the
 // original along with a mapping of tile indices to unique tile IDs.
 func ExtractUniqueTilesWithIndex(original, cleaned image.Image, tileSize int) ([]maputils.Tile, [][]int, error) {
-	cleanTiles := maputils.SliceImageIntoTiles(cleaned, tileSize)
-	origTiles := maputils.SliceImageIntoTiles(original, tileSize)
+	return ExtractUniqueTilesWithGrid(original, cleaned, maputils.SquareGrid(tileSize))
+}
+
+// ExtractUniqueTilesWithGrid is ExtractUniqueTilesWithIndex for a grid that
+// may not start at the image origin.
+func ExtractUniqueTilesWithGrid(original, cleaned image.Image, grid maputils.Grid) ([]maputils.Tile, [][]int, error) {
+	cleanTiles := maputils.SliceImageWithGrid(cleaned, grid)
+	origTiles := maputils.SliceImageWithGrid(original, grid)
 
 	if len(cleanTiles) != len(origTiles) {
 		return nil, nil, nil
 	}
 
 	bounds := cleaned.Bounds()
-	cols := bounds.Dx() / tileSize
-	rows := bounds.Dy() / tileSize
-
-	mapping := make([][]int, rows)
-	for i := range mapping {
-		mapping[i] = make([]int, cols)
-	}
+	mapping := newMapping(grid, bounds)
 
 	seen := make(map[string]int)
 	var tiles []maputils.Tile
 	nextID := 0
-	for y := 0; y < rows; y++ {
-		for x := 0; x < cols; x++ {
-			idx := y*cols + x
-			hash, err := maputils.HashTile(cleanTiles[idx])
-			if err != nil {
-				return nil, nil, err
-			}
-			id, ok := seen[hash]
-			if !ok {
-				id = nextID
-				seen[hash] = id
-				tiles = append(tiles, maputils.Tile{
-					ID:    id,
-					Image: origTiles[idx],
-					Hash:  hash,
-					X:     x,
-					Y:     y,
-				})
-				nextID++
-			}
-			mapping[y][x] = id
+	for idx, c := range grid.Cells(bounds) {
+		hash, err := maputils.HashTile(cleanTiles[idx])
+		if err != nil {
+			return nil, nil, err
+		}
+		id, ok := seen[hash]
+		if !ok {
+			id = nextID
+			seen[hash] = id
+			tiles = append(tiles, maputils.Tile{
+				ID:      id,
+				Image:   origTiles[idx],
+				Hash:    hash,
+				X:       c.X,
+				Y:       c.Y,
+				Partial: grid.Partial(bounds, c.X, c.Y),
+			})
+			nextID++
 		}
+		mapping[c.Y][c.X] = id
 	}
 
 	return tiles, mapping, nil
 }
 
+// ExtractGroupedTilesWithGrid builds the tileset from a grouping of the
+// grid's cells, such as one from analyser.GroupSimilarTiles: groups[i] is
+// the group of the i-th cell listed by grid.Cells and representatives[g] is
+// the cell whose pixels in original become tile g. Every cell of a group
+// maps to that tile.
+func ExtractGroupedTilesWithGrid(original image.Image, grid maputils.Grid, groups, representatives []int) ([]maputils.Tile, [][]int, error) {
+	origTiles := maputils.SliceImageWithGrid(original, grid)
+	if len(groups) != len(origTiles) {
+		return nil, nil, fmt.Errorf("grouping covers %d cells, grid has %d", len(groups), len(origTiles))
+	}
+
+	bounds := original.Bounds()
+	cells := grid.Cells(bounds)
+	mapping := newMapping(grid, bounds)
+	for idx, c := range cells {
+		mapping[c.Y][c.X] = groups[idx]
+	}
+
+	tiles := make([]maputils.Tile, len(representatives))
+	for id, idx := range representatives {
+		hash, err := maputils.HashTile(origTiles[idx])
+		if err != nil {
+			return nil, nil, err
+		}
+		c := cells[idx]
+		tiles[id] = maputils.Tile{
+			ID:      id,
+			Image:   origTiles[idx],
+			Hash:    hash,
+			X:       c.X,
+			Y:       c.Y,
+			Partial: grid.Partial(bounds, c.X, c.Y),
+		}
+	}
+	return tiles, mapping, nil
+}
+
+// newMapping returns the grid's mapping for bounds with every cell empty
+// (-1) until a tile is assigned. Only isometric grids leave cells empty.
+func newMapping(grid maputils.Grid, bounds image.Rectangle) [][]int {
+	cols, rows := grid.Dims(bounds)
+	mapping := make([][]int, rows)
+	for y := range mapping {
+		mapping[y] = make([]int, cols)
+		for x := range mapping[y] {
+			mapping[y][x] = -1
+		}
+	}
+	return mapping
+}
+
 // ExtractTiles returns a slice of tiles from an image.
 func ExtractTiles(img image.Image, tileSize int) []image.Image {
 	return maputils.SliceImageIntoTiles(img, tileSize)
@@ -0,0 +1,213 @@
+package tileutils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"tilemap-generator/internal/maputils"
+)
+
+const (
+	// heatmapTint is the opacity of the colour laid over each cell.
+	heatmapTint = 150
+	// legendHeight is the height of the legend strip below the map.
+	legendHeight = 64
+	// legendMinWidth keeps the legend readable under narrow maps.
+	legendMinWidth = 320
+)
+
+// heatmapRamp runs from rarely reused (blue) to most reused (red) tiles.
+var heatmapRamp = []color.RGBA{
+	{40, 80, 255, 255},
+	{0, 200, 200, 255},
+	{60, 220, 60, 255},
+	{255, 220, 0, 255},
+	{255, 40, 20, 255},
+}
+
+// singletonColour marks cells whose tile occurs only once.
+var singletonColour = color.RGBA{255, 0, 255, 255}
+
+// ReuseHeatmap is a rendered reuse heatmap and the figures in its legend.
+type ReuseHeatmap struct {
+	Image *image.RGBA
+	// MaxCount is the largest number of cells sharing one tile.
+	MaxCount int
+	// SingletonCells counts the cells holding a tile used exactly once.
+	SingletonCells int
+	// Cells counts the mapped cells.
+	Cells int
+}
+
+// RenderReuseHeatmap draws original with every cell of grid tinted by how
+// often its tile occurs in mapping, with a legend underneath. Counts from 2
+// up to the most reused tile follow a logarithmic blue to red ramp; cells
+// whose tile occurs exactly once are tinted magenta and outlined, marking
+// bespoke art. Cells holding -1 are left untinted. Isometric cells are
+// tinted within their diamond.
+func RenderReuseHeatmap(original image.Image, grid maputils.Grid, mapping [][]int) *ReuseHeatmap {
+	freq := maputils.TileFrequencies(mapping)
+	hm := &ReuseHeatmap{}
+	for _, n := range freq {
+		hm.MaxCount = max(hm.MaxCount, n)
+	}
+
+	bounds := original.Bounds()
+	width := max(bounds.Dx(), legendMinWidth)
+	img := image.NewRGBA(image.Rect(0, 0, width, bounds.Dy()+legendHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, bounds.Dx(), bounds.Dy()), original, bounds.Min, draw.Src)
+
+	// Isometric cells are tinted through a diamond mask so neighbouring
+	// diamonds sharing the box keep their own colour.
+	var mask *image.Alpha
+	if grid.Isometric() {
+		mask = diamondMask(grid.TileWidth, grid.TileHeight)
+	}
+	for y, row := range mapping {
+		for x, id := range row {
+			if id < 0 {
+				continue
+			}
+			hm.Cells++
+			box := grid.Cell(bounds, x, y).Sub(bounds.Min)
+			n := freq[id]
+			c := rampColour(reuseLevel(n, hm.MaxCount))
+			if n == 1 {
+				hm.SingletonCells++
+				c = singletonColour
+			}
+			tint(img, box, bounds.Sub(bounds.Min), c, mask)
+			if n == 1 {
+				if mask != nil {
+					outlineMask(img, box, bounds.Sub(bounds.Min), mask, c)
+				} else {
+					outline(img, box.Intersect(bounds.Sub(bounds.Min)), c)
+				}
+			}
+		}
+	}
+
+	drawLegend(img, image.Rect(0, bounds.Dy(), width, bounds.Dy()+legendHeight), hm)
+	hm.Image = img
+	return hm
+}
+
+// reuseLevel places count on the logarithmic scale from 2 (0) to maxCount
+// (1).
+func reuseLevel(count, maxCount int) float64 {
+	if maxCount <= 2 {
+		return 1
+	}
+	return math.Log(float64(count)/2) / math.Log(float64(maxCount)/2)
+}
+
+// rampColour interpolates heatmapRamp at t in [0, 1].
+func rampColour(t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t)) * float64(len(heatmapRamp)-1)
+	i := min(int(t), len(heatmapRamp)-2)
+	f := t - float64(i)
+	a, b := heatmapRamp[i], heatmapRamp[i+1]
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + f*(float64(y)-float64(x)) + 0.5) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// tint lays c over the part of box inside clip at heatmapTint opacity,
+// through mask when it is not nil.
+func tint(img *image.RGBA, box, clip image.Rectangle, c color.RGBA, mask *image.Alpha) {
+	a := uint32(heatmapTint)
+	over := color.RGBA{uint8(uint32(c.R) * a / 255), uint8(uint32(c.G) * a / 255), uint8(uint32(c.B) * a / 255), uint8(a)}
+	r := box.Intersect(clip)
+	if mask == nil {
+		draw.Draw(img, r, image.NewUniform(over), image.Point{}, draw.Over)
+		return
+	}
+	draw.DrawMask(img, r, image.NewUniform(over), image.Point{}, mask, r.Min.Sub(box.Min), draw.Over)
+}
+
+// diamondMask is opaque inside the diamond inscribed in a w x h box.
+func diamondMask(w, h int) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if maputils.InDiamond(x, y, w, h) {
+				mask.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+	return mask
+}
+
+// outlineMask colours the pixels of mask, placed at box, that border its
+// transparent part, within clip.
+func outlineMask(img *image.RGBA, box, clip image.Rectangle, mask *image.Alpha, c color.Color) {
+	b := mask.Bounds()
+	opaque := func(x, y int) bool {
+		return (image.Point{X: x, Y: y}).In(b) && mask.AlphaAt(x, y).A > 0
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if !opaque(x, y) {
+				continue
+			}
+			if opaque(x-1, y) && opaque(x+1, y) && opaque(x, y-1) && opaque(x, y+1) {
+				continue
+			}
+			if p := box.Min.Add(image.Pt(x, y)); p.In(clip) {
+				img.Set(p.X, p.Y, c)
+			}
+		}
+	}
+}
+
+// outline draws a one pixel border inside r.
+func outline(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		img.Set(x, r.Min.Y, c)
+		img.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		img.Set(r.Min.X, y, c)
+		img.Set(r.Max.X-1, y, c)
+	}
+}
+
+// drawLegend draws the colour ramp with its count range and the singleton
+// swatch into r.
+func drawLegend(img *image.RGBA, r image.Rectangle, hm *ReuseHeatmap) {
+	face := basicfont.Face7x13
+	label := func(x, y int, s string) {
+		d := font.Drawer{Dst: img, Src: image.Black, Face: face, Dot: fixed.P(x, y)}
+		d.DrawString(s)
+	}
+	textWidth := func(s string) int {
+		return font.MeasureString(face, s).Ceil()
+	}
+
+	const pad = 8
+	bar := image.Rect(r.Min.X+pad, r.Min.Y+pad, r.Max.X-pad, r.Min.Y+pad+12)
+	for x := bar.Min.X; x < bar.Max.X; x++ {
+		c := rampColour(float64(x-bar.Min.X) / float64(max(bar.Dx()-1, 1)))
+		draw.Draw(img, image.Rect(x, bar.Min.Y, x+1, bar.Max.Y), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	outline(img, bar, color.Black)
+
+	textY := bar.Max.Y + 13
+	label(bar.Min.X, textY, "2")
+	caption := "uses per tile (log)"
+	label(bar.Min.X+(bar.Dx()-textWidth(caption))/2, textY, caption)
+	maxLabel := fmt.Sprintf("%d", max(hm.MaxCount, 2))
+	label(bar.Max.X-textWidth(maxLabel), textY, maxLabel)
+
+	swatch := image.Rect(bar.Min.X, textY+6, bar.Min.X+12, textY+18)
+	draw.Draw(img, swatch, image.NewUniform(singletonColour), image.Point{}, draw.Src)
+	outline(img, swatch, color.Black)
+	label(swatch.Max.X+6, swatch.Max.Y-2, fmt.Sprintf("used once: %d of %d cells", hm.SingletonCells, hm.Cells))
+}
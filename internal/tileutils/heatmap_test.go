@@ -0,0 +1,71 @@
+package tileutils
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"tilemap-generator/internal/maputils"
+)
+
+func TestRenderReuseHeatmap(t *testing.T) {
+	original := image.NewRGBA(image.Rect(0, 0, 24, 16))
+	draw.Draw(original, original.Bounds(), image.Black, image.Point{}, draw.Src)
+	grid := maputils.Grid{TileWidth: 8, TileHeight: 8}
+	// Tile 1 fills three cells, tiles 2 and 3 one each; the last cell is
+	// empty.
+	mapping := [][]int{{1, 1, 2}, {1, 3, -1}}
+	hm := RenderReuseHeatmap(original, grid, mapping)
+	if hm.MaxCount != 3 || hm.SingletonCells != 2 || hm.Cells != 5 {
+		t.Errorf("max %d, singletons %d, cells %d; want 3, 2, 5", hm.MaxCount, hm.SingletonCells, hm.Cells)
+	}
+	if got, want := hm.Image.Bounds().Size(), image.Pt(legendMinWidth, 16+legendHeight); got != want {
+		t.Errorf("image size %v, want the map widened to %v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		x, y int
+		want color.RGBA
+	}{
+		// The most reused tile takes the red end of the ramp, laid over the
+		// black map at heatmapTint opacity.
+		{"most reused", 4, 4, color.RGBA{150, 23, 11, 255}},
+		{"singleton outline", 16, 0, color.RGBA{255, 0, 255, 255}},
+		{"singleton inside", 12, 12, color.RGBA{150, 0, 150, 255}},
+		{"empty cell", 20, 12, color.RGBA{0, 0, 0, 255}},
+	}
+	for _, tc := range tests {
+		if got := hm.Image.RGBAAt(tc.x, tc.y); got != tc.want {
+			t.Errorf("%s: pixel (%d, %d) = %v, want %v", tc.name, tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	// Isometric cells are tinted inside their diamond only.
+	iso := maputils.Grid{TileWidth: 8, TileHeight: 4, Orientation: maputils.Isometric}
+	isoMap := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	draw.Draw(isoMap, isoMap.Bounds(), image.Black, image.Point{}, draw.Src)
+	hm = RenderReuseHeatmap(isoMap, iso, [][]int{{-1, -1}, {-1, 1}})
+	box := iso.Cell(isoMap.Bounds(), 1, 1)
+	centre, corner := box.Min.Add(image.Pt(4, 2)), box.Min
+	if got := hm.Image.RGBAAt(centre.X, centre.Y); got != (color.RGBA{150, 0, 150, 255}) {
+		t.Errorf("diamond centre %v = %v, want tinted", centre, got)
+	}
+	if got := hm.Image.RGBAAt(corner.X, corner.Y); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("box corner %v = %v, want untinted", corner, got)
+	}
+}
+
+func TestReuseRamp(t *testing.T) {
+	if got := rampColour(reuseLevel(2, 50)); got != heatmapRamp[0] {
+		t.Errorf("twice-used tile = %v, want the blue end %v", got, heatmapRamp[0])
+	}
+	if got := rampColour(reuseLevel(50, 50)); got != heatmapRamp[len(heatmapRamp)-1] {
+		t.Errorf("most used tile = %v, want the red end %v", got, heatmapRamp[len(heatmapRamp)-1])
+	}
+	// The scale is logarithmic: 10 uses lie halfway between 2 and 50.
+	if got := reuseLevel(10, 50); got != 0.5 {
+		t.Errorf("reuseLevel(10, 50) = %v, want 0.5", got)
+	}
+}
@@ -0,0 +1,111 @@
+package tileutils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"tilemap-generator/internal/maputils"
+)
+
+// LoadTileImages reads the tile images referenced by a tileset, keyed by tile ID.
+func LoadTileImages(tilesetDir string, meta *maputils.TilesetMetadata) (map[int]image.Image, error) {
+	images := make(map[int]image.Image, len(meta.Tiles))
+	for _, t := range meta.Tiles {
+		f, err := os.Open(filepath.Join(tilesetDir, t.File))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open tile %d: %w", t.ID, err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tile %d: %w", t.ID, err)
+		}
+		images[t.ID] = img
+	}
+	return images, nil
+}
+
+// DrawMapping composites tile images into a single image following mapping.
+// Cells holding -1 or an unknown ID are left transparent.
+func DrawMapping(images map[int]image.Image, mapping [][]int, tileWidth, tileHeight int) *image.RGBA {
+	rows := len(mapping)
+	cols := 0
+	if rows > 0 {
+		cols = len(mapping[0])
+	}
+	out := image.NewRGBA(image.Rect(0, 0, cols*tileWidth, rows*tileHeight))
+	for y, row := range mapping {
+		for x, id := range row {
+			img, ok := images[id]
+			if !ok {
+				continue
+			}
+			r := image.Rect(x*tileWidth, y*tileHeight, (x+1)*tileWidth, (y+1)*tileHeight)
+			draw.Draw(out, r, img, img.Bounds().Min, draw.Src)
+		}
+	}
+	return out
+}
+
+// RenderMapping loads the tiles of a tileset and composites mapping with them.
+func RenderMapping(tilesetDir string, meta *maputils.TilesetMetadata, mapping [][]int) (*image.RGBA, error) {
+	images, err := LoadTileImages(tilesetDir, meta)
+	if err != nil {
+		return nil, err
+	}
+	w, h := meta.TileDims()
+	return DrawMapping(images, mapping, w, h), nil
+}
+
+// SavePNG encodes img as a PNG file at path.
+func SavePNG(img image.Image, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+// SaveGIF encodes frames as an animated GIF at path, showing each frame for
+// delay hundredths of a second and holding the last one four times as long.
+// Colours are reduced to the Plan 9 palette.
+func SaveGIF(frames []image.Image, delay int, path string) error {
+	anim := &gif.GIF{}
+	pal := color.Palette(palette.Plan9)
+	index := make(map[color.RGBA]uint8)
+	for i, frame := range frames {
+		b := frame.Bounds()
+		p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), pal)
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				c := color.RGBAModel.Convert(frame.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+				idx, ok := index[c]
+				if !ok {
+					idx = uint8(pal.Index(c))
+					index[c] = idx
+				}
+				p.Pix[y*p.Stride+x] = idx
+			}
+		}
+		d := delay
+		if i == len(frames)-1 {
+			d *= 4
+		}
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, d)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gif.EncodeAll(f, anim)
+}
@@ -10,14 +10,49 @@ import (
 	"tilemap-generator/internal/maputils"
 )
 
+// SaveOptions control what SaveTileset records in tileset.json.
+type SaveOptions struct {
+	// Grid is the tile grid the tiles were cut from.
+	Grid maputils.Grid
+	// CroppedPixels is the number of source pixels left out of the tiles.
+	CroppedPixels int
+	// PixelScale is the detected upscaling factor of the source pixel art,
+	// or 0 if none was detected.
+	PixelScale int
+	// Native records that PixelScale was removed from the source before
+	// slicing.
+	Native bool
+	// Diagonals records the four diagonal neighbours of every tile as well
+	// as the cardinal ones. Isometric grids always record just the diagonal
+	// ones.
+	Diagonals bool
+}
+
 // SaveTilesetWithIndex saves unique tiles to disk and writes a metadata file
 // containing the mapping of tile positions to tile IDs.
 func SaveTilesetWithIndex(tiles []maputils.Tile, mapping [][]int, outputDir string, tileSize int) error {
+	return SaveTileset(tiles, mapping, outputDir, SaveOptions{Grid: maputils.SquareGrid(tileSize)})
+}
+
+// SaveTileset is SaveTilesetWithIndex with additional options.
+func SaveTileset(tiles []maputils.Tile, mapping [][]int, outputDir string, opts SaveOptions) error {
 	if err := os.MkdirAll(filepath.Join(outputDir, "tiles"), 0755); err != nil {
 		return err
 	}
 
-	adj := maputils.BuildAdjacency(tiles, mapping)
+	var adj map[int]maputils.Adjacency
+	var weighted map[int]maputils.WeightedAdjacency
+	if opts.Grid.Isometric() {
+		adj = maputils.BuildIsometricAdjacency(tiles, mapping)
+		weighted = maputils.BuildIsometricWeightedAdjacency(tiles, mapping)
+	} else if opts.Diagonals {
+		adj = maputils.BuildAdjacencyWithDiagonals(tiles, mapping)
+		weighted = maputils.BuildWeightedAdjacencyWithDiagonals(tiles, mapping)
+	} else {
+		adj = maputils.BuildAdjacency(tiles, mapping)
+		weighted = maputils.BuildWeightedAdjacency(tiles, mapping)
+	}
+	freq := maputils.TileFrequencies(mapping)
 
 	var entries []maputils.TilesetEntry
 	for _, tile := range tiles {
@@ -34,24 +69,41 @@ func SaveTilesetWithIndex(tiles []maputils.Tile, mapping [][]int, outputDir stri
 		}
 		outFile.Close()
 
+		neighbours := weighted[tile.ID]
 		entries = append(entries, maputils.TilesetEntry{
-			ID:        tile.ID,
-			File:      filename,
-			Hash:      tile.Hash,
-			X:         tile.X,
-			Y:         tile.Y,
-			Adjacency: adj[tile.ID],
+			ID:         tile.ID,
+			File:       filename,
+			Hash:       tile.Hash,
+			X:          tile.X,
+			Y:          tile.Y,
+			Partial:    tile.Partial,
+			Adjacency:  adj[tile.ID],
+			Frequency:  freq[tile.ID],
+			Neighbours: &neighbours,
 		})
 	}
 
-	meta := struct {
-		TileSize int                     `json:"tileSize"`
-		Tiles    []maputils.TilesetEntry `json:"tiles"`
-		Mapping  [][]int                 `json:"mapping"`
-	}{
-		TileSize: tileSize,
-		Tiles:    entries,
-		Mapping:  mapping,
+	meta := maputils.TilesetMetadata{
+		TileWidth:     opts.Grid.TileWidth,
+		TileHeight:    opts.Grid.TileHeight,
+		OffsetX:       opts.Grid.OffsetX,
+		OffsetY:       opts.Grid.OffsetY,
+		Margin:        opts.Grid.Margin,
+		Spacing:       opts.Grid.Spacing,
+		Tiles:         entries,
+		Mapping:       mapping,
+		CroppedPixels: opts.CroppedPixels,
+		PixelScale:    opts.PixelScale,
+		Native:        opts.Native,
+	}
+	if opts.Grid.Isometric() {
+		meta.Orientation = maputils.Isometric
+	}
+	if opts.Grid.Edge != maputils.EdgeDrop {
+		meta.EdgePolicy = opts.Grid.Edge
+	}
+	if opts.Grid.Square() {
+		meta.TileSize = opts.Grid.TileWidth
 	}
 
 	metaPath := filepath.Join(outputDir, "tileset.json")
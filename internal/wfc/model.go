@@ -0,0 +1,85 @@
+package wfc
+
+import "sort"
+
+// Direction indexes used by Model.Propagator. Models with four directions use
+// the cardinal ones only; models with eight also constrain diagonal
+// neighbours.
+const (
+	Up = iota
+	Down
+	Left
+	Right
+	UpLeft
+	UpRight
+	DownLeft
+	DownRight
+)
+
+// DX and DY give the grid offset of each direction.
+var (
+	DX = []int{0, 0, -1, 1, -1, 1, -1, 1}
+	DY = []int{-1, 1, 0, 0, -1, -1, 1, 1}
+)
+
+var opposite = []int{Down, Up, Right, Left, DownRight, DownLeft, UpRight, UpLeft}
+
+// Opposite returns the direction pointing back towards the origin cell.
+func Opposite(d int) int {
+	return opposite[d]
+}
+
+// Model describes the states a cell can take and which states may sit next to
+// each other. Propagator[d][s] lists the states allowed in the neighbour at
+// direction d of a cell holding state s. The propagator must be symmetric:
+// b in Propagator[d][a] implies a in Propagator[Opposite(d)][b].
+type Model struct {
+	Weights    []float64
+	Propagator [][][]int
+}
+
+// States returns the number of states in the model.
+func (m *Model) States() int {
+	return len(m.Weights)
+}
+
+// Symmetrise adds any missing reverse entries to the propagator so the model
+// satisfies the symmetry requirement and sorts each list.
+func (m *Model) Symmetrise() {
+	n := m.States()
+	for d := range m.Propagator {
+		if d >= len(opposite) || opposite[d] < d {
+			continue
+		}
+		o := opposite[d]
+		fwd := make([]map[int]struct{}, n)
+		bwd := make([]map[int]struct{}, n)
+		for s := 0; s < n; s++ {
+			fwd[s] = map[int]struct{}{}
+			bwd[s] = map[int]struct{}{}
+		}
+		for a := 0; a < n; a++ {
+			for _, b := range m.Propagator[d][a] {
+				fwd[a][b] = struct{}{}
+				bwd[b][a] = struct{}{}
+			}
+			for _, b := range m.Propagator[o][a] {
+				bwd[a][b] = struct{}{}
+				fwd[b][a] = struct{}{}
+			}
+		}
+		for s := 0; s < n; s++ {
+			m.Propagator[d][s] = sortedInts(fwd[s])
+			m.Propagator[o][s] = sortedInts(bwd[s])
+		}
+	}
+}
+
+func sortedInts(set map[int]struct{}) []int {
+	out := make([]int, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Ints(out)
+	return out
+}
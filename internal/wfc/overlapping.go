@@ -0,0 +1,232 @@
+package wfc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Overlapping is a WFC model whose states are NxN windows of tile IDs taken
+// from a sample grid. Neighbouring patterns must agree where they overlap so
+// structures larger than a single tile are carried over from the sample.
+type Overlapping struct {
+	N        int
+	Patterns [][]int
+	Model    *Model
+}
+
+// NewOverlapping extracts every NxN window from sample and counts how often
+// each occurs. When periodicInput is set windows wrap around the sample
+// edges. Windows containing negative IDs are skipped.
+//
+// augment (1-8) adds rotated and reflected variants of every window, in the
+// order of Variants. Turning a window also turns the tiles in it, so each
+// tile ID is replaced by its counterpart: counterparts[v-1] maps a tile ID
+// to the ID of the tile that looks like it transformed by variant v. A
+// variant is left out for windows holding a tile without a counterpart.
+func NewOverlapping(sample [][]int, n int, periodicInput bool, augment int, counterparts []map[int]int) (*Overlapping, error) {
+	if n < 2 || n > 4 {
+		return nil, fmt.Errorf("pattern size must be 2, 3 or 4, got %d", n)
+	}
+	if augment < 1 || augment > 8 {
+		return nil, fmt.Errorf("augment must be between 1 and 8, got %d", augment)
+	}
+	if len(counterparts) < augment-1 {
+		return nil, fmt.Errorf("augment %d needs tile counterparts for %d variants, got %d", augment, augment-1, len(counterparts))
+	}
+	sh := len(sample)
+	if sh == 0 {
+		return nil, fmt.Errorf("sample mapping is empty")
+	}
+	sw := len(sample[0])
+
+	maxX, maxY := sw-n+1, sh-n+1
+	if periodicInput {
+		maxX, maxY = sw, sh
+	}
+	if maxX <= 0 || maxY <= 0 {
+		return nil, fmt.Errorf("sample %dx%d is smaller than pattern size %d", sw, sh, n)
+	}
+
+	index := make(map[string]int)
+	var patterns [][]int
+	var weights []float64
+	for y := 0; y < maxY; y++ {
+		for x := 0; x < maxX; x++ {
+			p, ok := window(sample, x, y, n)
+			if !ok {
+				continue
+			}
+			for i, v := range variants(p, n)[:augment] {
+				if i > 0 {
+					if v, ok = mapTiles(v, counterparts[i-1]); !ok {
+						continue
+					}
+				}
+				key := patternKey(v)
+				if i, seen := index[key]; seen {
+					weights[i]++
+					continue
+				}
+				index[key] = len(patterns)
+				patterns = append(patterns, v)
+				weights = append(weights, 1)
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no complete %dx%d patterns in sample", n, n)
+	}
+
+	m := &Model{Weights: weights, Propagator: make([][][]int, 4)}
+	for d := range m.Propagator {
+		m.Propagator[d] = make([][]int, len(patterns))
+		for a, p1 := range patterns {
+			for b, p2 := range patterns {
+				if agrees(p1, p2, DX[d], DY[d], n) {
+					m.Propagator[d][a] = append(m.Propagator[d][a], b)
+				}
+			}
+		}
+	}
+
+	return &Overlapping{N: n, Patterns: patterns, Model: m}, nil
+}
+
+// GridSize returns the solver grid needed for a width x height output.
+func (o *Overlapping) GridSize(width, height int, periodic bool) (int, int) {
+	if periodic {
+		return width, height
+	}
+	return width - o.N + 1, height - o.N + 1
+}
+
+// Decode turns a grid of pattern states into a width x height grid of tile
+// IDs. Undecided cells decode to -1.
+func (o *Overlapping) Decode(states [][]int, width, height int, periodic bool) [][]int {
+	gh := len(states)
+	gw := 0
+	if gh > 0 {
+		gw = len(states[0])
+	}
+	out := make([][]int, height)
+	for y := range out {
+		out[y] = make([]int, width)
+		for x := range out[y] {
+			px, py, ox, oy := x, y, 0, 0
+			if !periodic {
+				if px >= gw {
+					ox, px = px-gw+1, gw-1
+				}
+				if py >= gh {
+					oy, py = py-gh+1, gh-1
+				}
+			}
+			s := states[py][px]
+			if s < 0 {
+				out[y][x] = -1
+				continue
+			}
+			out[y][x] = o.Patterns[s][oy*o.N+ox]
+		}
+	}
+	return out
+}
+
+func window(sample [][]int, x, y, n int) ([]int, bool) {
+	sh, sw := len(sample), len(sample[0])
+	p := make([]int, n*n)
+	for dy := 0; dy < n; dy++ {
+		for dx := 0; dx < n; dx++ {
+			v := sample[(y+dy)%sh][(x+dx)%sw]
+			if v < 0 {
+				return nil, false
+			}
+			p[dy*n+dx] = v
+		}
+	}
+	return p, true
+}
+
+// Variants is the number of rotations and reflections of a window. Variant
+// v turns the window v/2 quarter turns clockwise and then, for odd v,
+// mirrors it left to right.
+const Variants = 8
+
+// variants returns the eight rotations and reflections of p in the order
+// original, reflected, rotated, rotated+reflected, and so on.
+func variants(p []int, n int) [][]int {
+	out := make([][]int, Variants)
+	out[0] = p
+	for i := 1; i < Variants; i++ {
+		if i%2 == 1 {
+			out[i] = reflect(out[i-1], n)
+		} else {
+			out[i] = rotate(out[i-2], n)
+		}
+	}
+	return out
+}
+
+func rotate(p []int, n int) []int {
+	out := make([]int, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			out[y*n+x] = p[(n-1-x)*n+y]
+		}
+	}
+	return out
+}
+
+func reflect(p []int, n int) []int {
+	out := make([]int, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			out[y*n+x] = p[y*n+n-1-x]
+		}
+	}
+	return out
+}
+
+// mapTiles replaces every tile ID in p by its counterpart. It reports false
+// if a tile has none.
+func mapTiles(p []int, counterparts map[int]int) ([]int, bool) {
+	out := make([]int, len(p))
+	for i, id := range p {
+		cp, ok := counterparts[id]
+		if !ok {
+			return nil, false
+		}
+		out[i] = cp
+	}
+	return out, true
+}
+
+// agrees reports whether p2 placed at offset (dx, dy) from p1 matches p1 on
+// every overlapping cell.
+func agrees(p1, p2 []int, dx, dy, n int) bool {
+	xmin, xmax := dx, n
+	if dx < 0 {
+		xmin, xmax = 0, dx+n
+	}
+	ymin, ymax := dy, n
+	if dy < 0 {
+		ymin, ymax = 0, dy+n
+	}
+	for y := ymin; y < ymax; y++ {
+		for x := xmin; x < xmax; x++ {
+			if p1[y*n+x] != p2[(y-dy)*n+x-dx] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func patternKey(p []int) string {
+	parts := make([]string, len(p))
+	for i, v := range p {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,144 @@
+package wfc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// patternWeights returns the weight of every pattern of o by its key.
+func patternWeights(o *Overlapping) map[string]float64 {
+	out := make(map[string]float64)
+	for i, p := range o.Patterns {
+		out[patternKey(p)] = o.Model.Weights[i]
+	}
+	return out
+}
+
+func TestOverlappingPatterns(t *testing.T) {
+	stripes := [][]int{
+		{0, 0, 0},
+		{0, 0, 0},
+		{1, 1, 1},
+	}
+	holed := [][]int{
+		{0, 0, 0},
+		{0, 0, 0},
+		{1, 1, -1},
+	}
+	tests := []struct {
+		name     string
+		sample   [][]int
+		periodic bool
+		want     map[string]float64
+	}{
+		{"non-periodic", stripes, false, map[string]float64{"0,0,0,0": 2, "0,0,1,1": 2}},
+		// Wrapping adds the windows across the bottom and right edges.
+		{"periodic", stripes, true, map[string]float64{"0,0,0,0": 3, "0,0,1,1": 3, "1,1,0,0": 3}},
+		{"negative IDs", holed, false, map[string]float64{"0,0,0,0": 2, "0,0,1,1": 1}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := NewOverlapping(tc.sample, 2, tc.periodic, 1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := patternWeights(o); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("patterns = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOverlappingAugment(t *testing.T) {
+	sample := [][]int{{1, 2}, {3, 4}}
+	mirrored := map[int]int{1: 11, 2: 12, 3: 13, 4: 14}
+	turned := map[int]int{1: 21, 2: 22, 3: 23, 4: 24}
+	tests := []struct {
+		name         string
+		augment      int
+		counterparts []map[int]int
+		want         map[string]float64
+	}{
+		{
+			name:         "mirror",
+			augment:      2,
+			counterparts: []map[int]int{mirrored},
+			want:         map[string]float64{"1,2,3,4": 1, "12,11,14,13": 1},
+		},
+		{
+			// The window turns clockwise and so does every tile in it.
+			name:         "quarter turn",
+			augment:      3,
+			counterparts: []map[int]int{mirrored, turned},
+			want:         map[string]float64{"1,2,3,4": 1, "12,11,14,13": 1, "23,21,24,22": 1},
+		},
+		{
+			name:         "missing counterpart",
+			augment:      3,
+			counterparts: []map[int]int{mirrored, {1: 21, 2: 22, 3: 23}},
+			want:         map[string]float64{"1,2,3,4": 1, "12,11,14,13": 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := NewOverlapping(sample, 2, false, tc.augment, tc.counterparts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := patternWeights(o); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("patterns = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := NewOverlapping(sample, 2, false, 4, []map[int]int{mirrored}); err == nil || !strings.Contains(err.Error(), "needs tile counterparts") {
+		t.Errorf("NewOverlapping error = %v, want missing counterparts", err)
+	}
+}
+
+func TestOverlappingStructure(t *testing.T) {
+	// Two 2x2 houses (1 2 over 3 4) on grass (0). Generated maps may hold any
+	// number of houses, but never a partial one.
+	sample := [][]int{
+		{0, 0, 0, 0, 0, 0},
+		{0, 1, 2, 0, 0, 0},
+		{0, 3, 4, 0, 0, 0},
+		{0, 0, 0, 0, 1, 2},
+		{0, 0, 0, 0, 3, 4},
+		{0, 0, 0, 0, 0, 0},
+	}
+	o, err := NewOverlapping(sample, 3, true, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 12
+	houses := 0
+	for seed := int64(0); seed < 10; seed++ {
+		s := NewSolver(o.Model, size, size, true)
+		if _, err := s.Solve(seed, 10); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		out := o.Decode(s.Result(), size, size, true)
+		at := func(x, y int) int { return out[(y+size)%size][(x+size)%size] }
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				tile := at(x, y)
+				if tile == 0 {
+					continue
+				}
+				// Find the house's top-left tile from this tile's place in it.
+				hx, hy := x-(tile-1)%2, y-(tile-1)/2
+				if at(hx, hy) != 1 || at(hx+1, hy) != 2 || at(hx, hy+1) != 3 || at(hx+1, hy+1) != 4 {
+					t.Fatalf("seed %d: tile %d at (%d, %d) is not part of a whole house", seed, tile, x, y)
+				}
+				if tile == 1 {
+					houses++
+				}
+			}
+		}
+	}
+	if houses == 0 {
+		t.Error("no house in any generated map")
+	}
+}
@@ -0,0 +1,404 @@
+package wfc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// ContradictionError reports the cell whose domain was emptied during
+// propagation.
+type ContradictionError struct {
+	X, Y int
+}
+
+func (e *ContradictionError) Error() string {
+	return fmt.Sprintf("contradiction at cell (%d, %d)", e.X, e.Y)
+}
+
+type ban struct {
+	cell, state int
+	event       int
+}
+
+// Solver runs Wave Function Collapse over a Width x Height grid of cells whose
+// possible states are described by a Model.
+type Solver struct {
+	Width, Height int
+	Periodic      bool
+	// Trace records every removed state so a contradiction can be explained
+	// with Diagnose.
+	Trace bool
+	// Hook, if set, runs after every propagation of an attempt. It may ban
+	// further states, in which case it must call Propagate itself. Returning
+	// an error ends the attempt with that error.
+	Hook func(s *Solver) error
+
+	model *Model
+	rng   *rand.Rand
+
+	wave       [][]bool
+	compatible [][][]int
+	remaining  []int
+	sumW       []float64
+	sumWLogW   []float64
+	entropy    []float64
+
+	weightLogWeights []float64
+	totalW           float64
+	totalWLogW       float64
+	startingEntropy  float64
+
+	stack         []ban
+	contradiction int
+
+	restrictions [][]bool
+	events       []Event
+}
+
+// NewSolver prepares a solver for the given model and grid size.
+func NewSolver(m *Model, width, height int, periodic bool) *Solver {
+	s := &Solver{
+		Width:    width,
+		Height:   height,
+		Periodic: periodic,
+		model:    m,
+	}
+	n := m.States()
+	cells := width * height
+	s.wave = make([][]bool, cells)
+	s.compatible = make([][][]int, cells)
+	for i := 0; i < cells; i++ {
+		s.wave[i] = make([]bool, n)
+		s.compatible[i] = make([][]int, n)
+		for t := 0; t < n; t++ {
+			s.compatible[i][t] = make([]int, len(m.Propagator))
+		}
+	}
+	s.restrictions = make([][]bool, cells)
+	s.remaining = make([]int, cells)
+	s.sumW = make([]float64, cells)
+	s.sumWLogW = make([]float64, cells)
+	s.entropy = make([]float64, cells)
+
+	s.weightLogWeights = make([]float64, n)
+	for t, w := range m.Weights {
+		s.weightLogWeights[t] = w * math.Log(w)
+		s.totalW += w
+		s.totalWLogW += s.weightLogWeights[t]
+	}
+	s.startingEntropy = math.Log(s.totalW) - s.totalWLogW/s.totalW
+	return s
+}
+
+// Solve runs up to attempts independent attempts, restarting with a new
+// derived seed whenever a contradiction is hit. It returns the number of
+// attempts used.
+func (s *Solver) Solve(seed int64, attempts int) (int, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for a := 0; a < attempts; a++ {
+		if err = s.Run(seed + int64(a)); err == nil {
+			return a + 1, nil
+		}
+	}
+	return attempts, err
+}
+
+// Run performs a single generation attempt with the given seed.
+func (s *Solver) Run(seed int64) error {
+	s.rng = rand.New(rand.NewSource(seed))
+	s.Clear()
+	for {
+		if !s.Propagate() {
+			return s.contradictionError()
+		}
+		if s.Hook != nil {
+			if err := s.Hook(s); err != nil {
+				return err
+			}
+		}
+		done, err := s.Observe()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// Clear resets every cell to the full domain, bans states that have no
+// support from an in-bounds neighbour and applies the restrictions.
+func (s *Solver) Clear() {
+	m := s.model
+	s.stack = s.stack[:0]
+	s.events = s.events[:0]
+	s.contradiction = -1
+	for i := range s.wave {
+		for t := range s.wave[i] {
+			s.wave[i][t] = true
+			for d := range m.Propagator {
+				s.compatible[i][t][d] = len(m.Propagator[opposite[d]][t])
+			}
+		}
+		s.remaining[i] = len(m.Weights)
+		s.sumW[i] = s.totalW
+		s.sumWLogW[i] = s.totalWLogW
+		s.entropy[i] = s.startingEntropy
+	}
+	for i := range s.wave {
+		x, y := i%s.Width, i/s.Width
+		for t := range s.wave[i] {
+			for d := range m.Propagator {
+				if _, ok := s.neighbour(x, y, Opposite(d)); !ok {
+					continue
+				}
+				if s.compatible[i][t][d] == 0 && s.wave[i][t] {
+					s.ban(i, t, -1, CauseUnsupported)
+				}
+			}
+		}
+	}
+	for i, allowed := range s.restrictions {
+		if allowed == nil {
+			continue
+		}
+		for t, ok := range allowed {
+			if !ok {
+				s.ban(i, t, -1, CauseRestricted)
+			}
+		}
+	}
+}
+
+// Restrict limits cell (x, y) to the given states on every subsequent attempt.
+func (s *Solver) Restrict(x, y int, states []int) {
+	allowed := make([]bool, s.model.States())
+	for _, t := range states {
+		allowed[t] = true
+	}
+	s.restrictions[y*s.Width+x] = allowed
+}
+
+// Restriction returns the states cell (x, y) is restricted to, or nil if it
+// is unrestricted.
+func (s *Solver) Restriction(x, y int) []int {
+	allowed := s.restrictions[y*s.Width+x]
+	if allowed == nil {
+		return nil
+	}
+	var out []int
+	for t, ok := range allowed {
+		if ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ClearRestrictions removes every restriction added with Restrict.
+func (s *Solver) ClearRestrictions() {
+	for i := range s.restrictions {
+		s.restrictions[i] = nil
+	}
+}
+
+// Check resets the grid, applies the restrictions and propagates them
+// without observing any cell. It reports a contradiction if the
+// restrictions cannot be satisfied together.
+func (s *Solver) Check() error {
+	s.Clear()
+	if !s.Propagate() {
+		return s.contradictionError()
+	}
+	return nil
+}
+
+// Observe collapses the undecided cell with the lowest entropy. It reports
+// done when every cell has exactly one state left.
+func (s *Solver) Observe() (bool, error) {
+	best := -1
+	min := math.Inf(1)
+	for i, r := range s.remaining {
+		if r == 0 {
+			s.contradiction = i
+			return false, s.contradictionError()
+		}
+		if r == 1 {
+			continue
+		}
+		e := s.entropy[i] + 1e-6*s.rng.Float64()
+		if e < min {
+			min = e
+			best = i
+		}
+	}
+	if best < 0 {
+		return true, nil
+	}
+
+	w := s.wave[best]
+	pick := s.rng.Float64() * s.sumW[best]
+	chosen := -1
+	for t, ok := range w {
+		if !ok {
+			continue
+		}
+		chosen = t
+		pick -= s.model.Weights[t]
+		if pick <= 0 {
+			break
+		}
+	}
+	s.Collapse(best, chosen)
+	return false, nil
+}
+
+// Collapse bans every state of cell except state.
+func (s *Solver) Collapse(cell, state int) {
+	for t, ok := range s.wave[cell] {
+		if ok && t != state {
+			s.ban(cell, t, -1, CauseObserved)
+		}
+	}
+}
+
+// Ban removes state from the domain of cell and queues it for propagation.
+func (s *Solver) Ban(cell, state int) {
+	s.ban(cell, state, -1, CauseRestricted)
+}
+
+// ban removes state from cell. trigger is the index of the event whose
+// propagation caused the removal, or -1 for origin events.
+func (s *Solver) ban(cell, state, trigger int, cause Cause) {
+	if !s.wave[cell][state] {
+		return
+	}
+	s.wave[cell][state] = false
+	comp := s.compatible[cell][state]
+	for d := range comp {
+		comp[d] = 0
+	}
+	event := -1
+	if s.Trace {
+		event = len(s.events)
+		s.events = append(s.events, Event{Cell: cell, State: state, Trigger: trigger, Cause: cause})
+	}
+	s.stack = append(s.stack, ban{cell, state, event})
+
+	w := s.model.Weights[state]
+	s.remaining[cell]--
+	s.sumW[cell] -= w
+	s.sumWLogW[cell] -= s.weightLogWeights[state]
+	if s.remaining[cell] == 0 {
+		if s.contradiction < 0 {
+			s.contradiction = cell
+		}
+		return
+	}
+	sum := s.sumW[cell]
+	s.entropy[cell] = math.Log(sum) - s.sumWLogW[cell]/sum
+}
+
+// Propagate removes states that lost all support in some direction. It
+// stops and returns false as soon as a cell ends up with an empty domain.
+func (s *Solver) Propagate() bool {
+	p := s.model.Propagator
+	for len(s.stack) > 0 && s.contradiction < 0 {
+		b := s.stack[len(s.stack)-1]
+		s.stack = s.stack[:len(s.stack)-1]
+		x, y := b.cell%s.Width, b.cell/s.Width
+		for d := range p {
+			n, ok := s.neighbour(x, y, d)
+			if !ok {
+				continue
+			}
+			for _, t := range p[d][b.state] {
+				comp := s.compatible[n][t]
+				comp[d]--
+				if comp[d] == 0 {
+					s.ban(n, t, b.event, CausePropagated)
+				}
+			}
+		}
+	}
+	return s.contradiction < 0
+}
+
+// Err returns the contradiction reached by the current attempt, or nil.
+func (s *Solver) Err() error {
+	if s.contradiction < 0 {
+		return nil
+	}
+	return s.contradictionError()
+}
+
+// Cells returns the number of cells in the grid.
+func (s *Solver) Cells() int {
+	return len(s.wave)
+}
+
+// Allowed reports whether state is still possible in cell.
+func (s *Solver) Allowed(cell, state int) bool {
+	return s.wave[cell][state]
+}
+
+// Remaining returns how many states are still possible in cell.
+func (s *Solver) Remaining(cell int) int {
+	return s.remaining[cell]
+}
+
+// Entropy returns the weighted Shannon entropy of the states still possible
+// in cell. It is 0 once the cell is decided.
+func (s *Solver) Entropy(cell int) float64 {
+	if s.remaining[cell] <= 1 {
+		return 0
+	}
+	return s.entropy[cell]
+}
+
+// State returns the only state left in cell, or -1 if it is undecided.
+func (s *Solver) State(cell int) int {
+	if s.remaining[cell] != 1 {
+		return -1
+	}
+	for t, ok := range s.wave[cell] {
+		if ok {
+			return t
+		}
+	}
+	return -1
+}
+
+// neighbour returns the index of the cell at direction d from (x, y).
+func (s *Solver) neighbour(x, y, d int) (int, bool) {
+	nx, ny := x+DX[d], y+DY[d]
+	if s.Periodic {
+		nx = (nx + s.Width) % s.Width
+		ny = (ny + s.Height) % s.Height
+	} else if nx < 0 || ny < 0 || nx >= s.Width || ny >= s.Height {
+		return 0, false
+	}
+	return ny*s.Width + nx, true
+}
+
+func (s *Solver) contradictionError() error {
+	c := s.contradiction
+	return &ContradictionError{X: c % s.Width, Y: c / s.Width}
+}
+
+// Result returns the chosen state of each cell, or -1 where a cell is not
+// yet decided.
+func (s *Solver) Result() [][]int {
+	out := make([][]int, s.Height)
+	for y := range out {
+		out[y] = make([]int, s.Width)
+		for x := range out[y] {
+			out[y][x] = s.State(y*s.Width + x)
+		}
+	}
+	return out
+}
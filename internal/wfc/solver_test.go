@@ -0,0 +1,170 @@
+package wfc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// terrainModel has water (0), shore (1) and grass (2): each may touch itself
+// and shore may sit between water and grass, in every direction.
+func terrainModel(dirs int) *Model {
+	allowed := [][]int{{0, 1}, {0, 1, 2}, {1, 2}}
+	m := &Model{Weights: []float64{3, 1, 5}, Propagator: make([][][]int, dirs)}
+	for d := range m.Propagator {
+		m.Propagator[d] = allowed
+	}
+	return m
+}
+
+// stripeModel has two states that must alternate horizontally and repeat
+// vertically, so a row of odd length cannot wrap around.
+func stripeModel() *Model {
+	return &Model{
+		Weights: []float64{1, 1},
+		Propagator: [][][]int{
+			Up:    {{0}, {1}},
+			Down:  {{0}, {1}},
+			Left:  {{1}, {0}},
+			Right: {{1}, {0}},
+		},
+	}
+}
+
+// checkAdjacency fails t if any two neighbouring cells of result, in any
+// direction of the model, hold states the model does not allow together.
+func checkAdjacency(t *testing.T, m *Model, result [][]int, periodic bool) {
+	t.Helper()
+	h, w := len(result), len(result[0])
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := result[y][x]
+			if a < 0 {
+				t.Fatalf("cell (%d, %d) is undecided", x, y)
+			}
+			for d := range m.Propagator {
+				nx, ny := x+DX[d], y+DY[d]
+				if periodic {
+					nx, ny = (nx+w)%w, (ny+h)%h
+				} else if nx < 0 || ny < 0 || nx >= w || ny >= h {
+					continue
+				}
+				b := result[ny][nx]
+				ok := false
+				for _, s := range m.Propagator[d][a] {
+					ok = ok || s == b
+				}
+				if !ok {
+					t.Fatalf("cell (%d, %d) = %d has %d in direction %d, which the model forbids", x, y, a, b, d)
+				}
+			}
+		}
+	}
+}
+
+func TestSolverSeedReproducesOutput(t *testing.T) {
+	m := terrainModel(4)
+	first := NewSolver(m, 12, 9, false)
+	if _, err := first.Solve(42, 5); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprint(first.Result())
+
+	second := NewSolver(m, 12, 9, false)
+	if _, err := second.Solve(42, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(second.Result()); got != want {
+		t.Errorf("second solver with the same seed gave\n%s\nwant\n%s", got, want)
+	}
+
+	// Reusing a solver must not carry state over from the previous run.
+	if _, err := second.Solve(7, 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Solve(42, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(second.Result()); got != want {
+		t.Errorf("rerunning seed 42 gave\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSolverUnsatisfiable(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    *Model
+		w, h     int
+		periodic bool
+	}{
+		// Alternating states cannot wrap around a row of three cells.
+		{"odd periodic stripes", stripeModel(), 3, 2, true},
+		// A state with no allowed right neighbour cannot fill a row.
+		{"no right neighbour", &Model{
+			Weights:    []float64{1},
+			Propagator: [][][]int{Up: {{0}}, Down: {{0}}, Left: {{}}, Right: {{}}},
+		}, 2, 1, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewSolver(tc.model, tc.w, tc.h, tc.periodic)
+			attempts, err := s.Solve(1, 3)
+			var ce *ContradictionError
+			if !errors.As(err, &ce) {
+				t.Fatalf("Solve returned %v, want a contradiction", err)
+			}
+			if attempts != 3 {
+				t.Errorf("Solve used %d attempts, want 3", attempts)
+			}
+			if ce.X < 0 || ce.X >= tc.w || ce.Y < 0 || ce.Y >= tc.h {
+				t.Errorf("contradiction at (%d, %d) is outside the %dx%d grid", ce.X, ce.Y, tc.w, tc.h)
+			}
+		})
+	}
+}
+
+func TestSolverRestrictionConflict(t *testing.T) {
+	// Water and grass two cells apart leave shore as the only state between
+	// them; pinning that cell to grass as well cannot be satisfied.
+	s := NewSolver(terrainModel(4), 3, 1, false)
+	s.Restrict(0, 0, []int{0})
+	s.Restrict(2, 0, []int{2})
+	if err := s.Check(); err != nil {
+		t.Fatalf("Check = %v, want nil", err)
+	}
+	s.Restrict(1, 0, []int{2})
+	var ce *ContradictionError
+	if err := s.Check(); !errors.As(err, &ce) {
+		t.Fatalf("Check = %v, want a contradiction", err)
+	}
+}
+
+func TestSolverAdjacency(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    *Model
+		w, h     int
+		periodic bool
+	}{
+		{"cardinal", terrainModel(4), 16, 10, false},
+		{"diagonal", terrainModel(8), 16, 10, false},
+		{"periodic", terrainModel(4), 10, 10, true},
+		{"even periodic stripes", stripeModel(), 4, 3, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				s := NewSolver(tc.model, tc.w, tc.h, tc.periodic)
+				s.Restrict(0, 0, []int{0})
+				if _, err := s.Solve(seed, 10); err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				result := s.Result()
+				if result[0][0] != 0 {
+					t.Fatalf("seed %d: restricted cell holds %d, want 0", seed, result[0][0])
+				}
+				checkAdjacency(t, tc.model, result, tc.periodic)
+			}
+		})
+	}
+}
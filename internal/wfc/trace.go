@@ -0,0 +1,153 @@
+package wfc
+
+// Cause explains why a state was removed from a cell.
+type Cause int
+
+const (
+	// CauseUnsupported means no neighbour state could ever sit next to it.
+	CauseUnsupported Cause = iota
+	// CauseRestricted means the cell was restricted or pinned by the caller.
+	CauseRestricted
+	// CauseObserved means another state was chosen for the cell.
+	CauseObserved
+	// CausePropagated means a neighbour lost every state supporting it.
+	CausePropagated
+)
+
+func (c Cause) String() string {
+	switch c {
+	case CauseUnsupported:
+		return "unsupported"
+	case CauseRestricted:
+		return "pinned"
+	case CauseObserved:
+		return "observed"
+	default:
+		return "propagated"
+	}
+}
+
+// Event records the removal of one state from one cell. Trigger is the
+// index of the event whose propagation caused it, or -1 for events that
+// start a chain.
+type Event struct {
+	Cell    int
+	State   int
+	Trigger int
+	Cause   Cause
+}
+
+// Diagnosis explains why a cell's domain was emptied.
+type Diagnosis struct {
+	// Cell is the index of the contradiction cell.
+	Cell int
+	// Chain is the propagation chain that removed the cell's last state,
+	// from the originating event to the contradiction.
+	Chain []Event
+	// Origins are the events that started any chain ending in the cell.
+	Origins []Event
+	// Neighbours holds, per direction, the decided state of the neighbour or
+	// -1 if it is undecided or outside the grid.
+	Neighbours []int
+	// MissingTransition is true when no state at all is compatible with the
+	// decided neighbours, meaning the model lacks a suitable tile rather than
+	// propagation having removed one.
+	MissingTransition bool
+	// States is the grid as it was when the contradiction occurred.
+	States [][]int
+}
+
+// Diagnose explains the contradiction hit by the last attempt. It returns
+// nil if there was no contradiction or Trace was not enabled.
+func (s *Solver) Diagnose() *Diagnosis {
+	c := s.contradiction
+	if c < 0 || !s.Trace {
+		return nil
+	}
+	d := &Diagnosis{Cell: c, States: s.Result()}
+
+	last := -1
+	for i, e := range s.events {
+		if e.Cell == c {
+			last = i
+		}
+	}
+	for i := last; i >= 0; i = s.events[i].Trigger {
+		d.Chain = append([]Event{s.events[i]}, d.Chain...)
+	}
+
+	seen := make(map[int]bool)
+	for i, e := range s.events {
+		if e.Cell != c {
+			continue
+		}
+		j := i
+		for s.events[j].Trigger >= 0 && !seen[j] {
+			seen[j] = true
+			j = s.events[j].Trigger
+		}
+		if seen[j] {
+			continue
+		}
+		seen[j] = true
+		if o := s.events[j]; o.Cause == CauseObserved || o.Cause == CauseRestricted {
+			d.Origins = append(d.Origins, o)
+		}
+	}
+	d.Origins = uniqueOrigins(d.Origins)
+
+	x, y := c%s.Width, c/s.Width
+	p := s.model.Propagator
+	candidates := make([]bool, s.model.States())
+	for t := range candidates {
+		candidates[t] = true
+	}
+	fixed := 0
+	d.Neighbours = make([]int, len(p))
+	for dir := range p {
+		d.Neighbours[dir] = -1
+		n, ok := s.neighbour(x, y, dir)
+		if !ok || s.remaining[n] != 1 {
+			continue
+		}
+		u := d.States[n/s.Width][n%s.Width]
+		d.Neighbours[dir] = u
+		fixed++
+		allowed := make([]bool, len(candidates))
+		for _, t := range p[Opposite(dir)][u] {
+			allowed[t] = true
+		}
+		for t := range candidates {
+			candidates[t] = candidates[t] && allowed[t]
+		}
+	}
+	if fixed > 0 {
+		d.MissingTransition = true
+		for _, ok := range candidates {
+			if ok {
+				d.MissingTransition = false
+				break
+			}
+		}
+	}
+	return d
+}
+
+// uniqueOrigins keeps one origin event per cell and cause.
+func uniqueOrigins(events []Event) []Event {
+	type key struct {
+		cell  int
+		cause Cause
+	}
+	seen := make(map[key]bool)
+	var out []Event
+	for _, e := range events {
+		k := key{e.Cell, e.Cause}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, e)
+	}
+	return out
+}